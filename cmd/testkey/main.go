@@ -27,51 +27,54 @@ func main() {
 	kittyMode := flag.Bool("kitty", false, "Enable Kitty keyboard protocol")
 	kittyFull := flag.Bool("kitty-full", false, "Enable Kitty keyboard protocol with all flags")
 	mouseMode := flag.Bool("mouse", false, "Enable mouse reporting (SGR mode)")
+	hoverMode := flag.Bool("hover", false, "Also report mouse motion with no button held (implies -mouse)")
 	flag.Parse()
 
-	handler := keyboard.New(keyboard.Options{
-		InputReader: os.Stdin,
-		EchoWriter:  nil, // No echo for raw key testing
-	})
-
-	// Enable terminal modes before starting
+	// Protocol modes are pushed by the handler when it enters raw mode and
+	// popped when it leaves, so they also survive a Ctrl+Z suspend/resume.
+	var enterModes, exitModes string
 	if *kittyMode || *kittyFull {
 		if *kittyFull {
-			fmt.Print(kittyEnhance)
+			enterModes += kittyEnhance
 			fmt.Println("Kitty keyboard protocol enabled (full mode - all flags)")
 		} else {
-			fmt.Print(kittyEnable)
+			enterModes += kittyEnable
 			fmt.Println("Kitty keyboard protocol enabled (basic mode)")
 		}
+		exitModes += kittyDisable
 	}
 
-	if *mouseMode {
-		fmt.Print(mouseEnableBasic + mouseEnableMotion + mouseEnableSGR)
+	if *mouseMode || *hoverMode {
+		enterModes += mouseEnableBasic + mouseEnableMotion + mouseEnableSGR
+		exitModes += mouseDisable
 		fmt.Println("Mouse reporting enabled (SGR mode)")
 	}
+	if *hoverMode {
+		enterModes += keyboard.MouseAnyMotionOn
+		exitModes = keyboard.MouseAnyMotionOff + exitModes
+		fmt.Println("Mouse hover reporting enabled (any-motion mode)")
+	}
+
+	handler := keyboard.New(keyboard.Options{
+		InputReader:     os.Stdin,
+		EchoWriter:      nil, // No echo for raw key testing
+		ModeWriter:      os.Stdout,
+		EnterModes:      enterModes,
+		ExitModes:       exitModes,
+		HandleSuspend:   true,
+		RestoreOnSignal: true,
+	})
+	handler.OnResume = func() {
+		fmt.Print("Resumed\r\n")
+	}
 
 	if err := handler.Start(); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to start: %v\n", err)
-		// Clean up terminal modes on error
-		if *kittyMode || *kittyFull {
-			fmt.Print(kittyDisable)
-		}
-		if *mouseMode {
-			fmt.Print(mouseDisable)
-		}
 		os.Exit(1)
 	}
 
 	// Ensure cleanup on exit
-	defer func() {
-		handler.Stop()
-		if *kittyMode || *kittyFull {
-			fmt.Print(kittyDisable)
-		}
-		if *mouseMode {
-			fmt.Print(mouseDisable)
-		}
-	}()
+	defer handler.Stop()
 
 	fmt.Println("Press keys (Ctrl+C to exit):")
 
@@ -0,0 +1,185 @@
+// Package expect drives a terminal program for automated tests, in the
+// spirit of Tcl's expect: run it on a pty, send keys by name, and wait for
+// its output to match.
+//
+//	s, err := expect.Spawn(exec.Command("./mytui"))
+//	defer s.Close()
+//	s.Expect(`Name: `)
+//	s.Send("Ada")
+//	s.SendKeys("Enter", "F2")
+//	if _, err := s.Expect(`Saved`); err != nil {
+//		t.Fatal(err)
+//	}
+//	s.SendKeys("C-c")
+//
+// Output is matched raw, escape sequences included.
+package expect
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/phroun/direct-key-handler/keyboard"
+	"github.com/phroun/direct-key-handler/ptyfwd"
+)
+
+// DefaultTimeout is how long Expect waits unless Session.Timeout is set.
+const DefaultTimeout = 5 * time.Second
+
+// ErrTimeout is returned (wrapped) when the output doesn't match in time.
+var ErrTimeout = errors.New("expect: timed out")
+
+// ErrExited is returned (wrapped) when the program exits without the
+// output matching.
+var ErrExited = errors.New("expect: program exited")
+
+// Session is a program under test.
+type Session struct {
+	// Timeout bounds each Expect call (default: DefaultTimeout)
+	Timeout time.Duration
+
+	pty *ptyfwd.Session
+
+	mu      sync.Mutex
+	out     bytes.Buffer  // Everything the program has written
+	pos     int           // Start of the output not yet consumed by Expect
+	changed chan struct{} // Closed and replaced on each write
+}
+
+// Spawn starts cmd on a pty.
+func Spawn(cmd *exec.Cmd) (*Session, error) {
+	s := &Session{changed: make(chan struct{})}
+	fwd, err := ptyfwd.Start(cmd, ptyfwd.Options{Output: (*sessionOutput)(s)})
+	if err != nil {
+		return nil, err
+	}
+	s.pty = fwd
+	return s, nil
+}
+
+// sessionOutput is the io.Writer the program's output is relayed to
+type sessionOutput Session
+
+func (w *sessionOutput) Write(p []byte) (int, error) {
+	s := (*Session)(w)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.out.Write(p)
+	close(s.changed)
+	s.changed = make(chan struct{})
+	return len(p), nil
+}
+
+// Send types text into the program as-is.
+func (s *Session) Send(text string) error {
+	_, err := s.pty.Write([]byte(text))
+	return err
+}
+
+// SendKeys types keys by name (see keyboard.EncodeKey): "a", "Enter",
+// "C-c", "M-x", "F2", "S-Up".
+func (s *Session) SendKeys(names ...string) error {
+	var buf []byte
+	for _, name := range names {
+		b, err := keyboard.EncodeKey(name)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, b...)
+	}
+	_, err := s.pty.Write(buf)
+	return err
+}
+
+// Expect waits for the output not yet consumed to match the regular
+// expression pattern and consumes it through the end of the match. It
+// returns the matched text.
+func (s *Session) Expect(pattern string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", err
+	}
+	return s.ExpectRegexp(re)
+}
+
+// ExpectString is Expect for a literal string.
+func (s *Session) ExpectString(text string) error {
+	_, err := s.ExpectRegexp(regexp.MustCompile(regexp.QuoteMeta(text)))
+	return err
+}
+
+// ExpectRegexp is Expect for a compiled regular expression.
+func (s *Session) ExpectRegexp(re *regexp.Regexp) (string, error) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		pending := s.out.Bytes()[s.pos:]
+		if loc := re.FindIndex(pending); loc != nil {
+			match := string(pending[loc[0]:loc[1]])
+			s.pos += loc[1]
+			s.mu.Unlock()
+			return match, nil
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return "", fmt.Errorf("%w waiting for %q; output: %q", ErrTimeout, re, s.Pending())
+		case <-s.pty.Done():
+			// All output has been relayed; try once more
+			s.mu.Lock()
+			pending := s.out.Bytes()[s.pos:]
+			loc := re.FindIndex(pending)
+			if loc != nil {
+				match := string(pending[loc[0]:loc[1]])
+				s.pos += loc[1]
+				s.mu.Unlock()
+				return match, nil
+			}
+			s.mu.Unlock()
+			return "", fmt.Errorf("%w waiting for %q; output: %q", ErrExited, re, s.Pending())
+		}
+	}
+}
+
+// Pending returns the output not yet consumed by Expect.
+func (s *Session) Pending() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return string(s.out.Bytes()[s.pos:])
+}
+
+// Output returns everything the program has written.
+func (s *Session) Output() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.out.String()
+}
+
+// Resize sets the program's terminal size.
+func (s *Session) Resize(cols, rows int) error {
+	return s.pty.Resize(cols, rows)
+}
+
+// Wait waits for the program to exit.
+func (s *Session) Wait() error {
+	return s.pty.Wait()
+}
+
+// Close hangs up the program's terminal.
+func (s *Session) Close() error {
+	return s.pty.Close()
+}
@@ -0,0 +1,60 @@
+package expect
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/phroun/direct-key-handler/ptyfwd"
+)
+
+// spawn starts cmd or skips the test where ptys are unsupported.
+func spawn(t *testing.T, cmd *exec.Cmd) *Session {
+	t.Helper()
+	s, err := Spawn(cmd)
+	if errors.Is(err, ptyfwd.ErrUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// TestExpect: sent text and keys show up in the output (echoed by the pty
+// and again by cat), and a missing pattern times out.
+func TestExpect(t *testing.T) {
+	s := spawn(t, exec.Command("cat"))
+	s.Timeout = 2 * time.Second
+
+	if err := s.Send("first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SendKeys("Enter", "x", "y", "Enter"); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := s.Expect(`f\w+`); err != nil || m != "first" {
+		t.Fatalf("Expect = %q, %v; want \"first\"", m, err)
+	}
+	if err := s.ExpectString("xy"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Timeout = 50 * time.Millisecond
+	if _, err := s.Expect("absent"); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Expect of missing output: err = %v, want ErrTimeout", err)
+	}
+}
+
+// TestExpectExited: waiting on a program that has exited fails promptly.
+func TestExpectExited(t *testing.T) {
+	s := spawn(t, exec.Command("echo", "bye"))
+	if err := s.ExpectString("bye"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Expect("never"); !errors.Is(err, ErrExited) {
+		t.Errorf("err = %v, want ErrExited", err)
+	}
+}
@@ -2,6 +2,7 @@ module github.com/phroun/direct-key-handler
 
 go 1.21
 
-require golang.org/x/term v0.15.0
-
-require golang.org/x/sys v0.15.0 // indirect
+require (
+	golang.org/x/sys v0.15.0
+	golang.org/x/term v0.15.0
+)
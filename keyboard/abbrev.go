@@ -0,0 +1,63 @@
+package keyboard
+
+import "unicode/utf8"
+
+// Abbreviate registers an abbreviation for line mode: when trigger is
+// typed as a word on its own and followed by Space or Enter, it is replaced
+// by expansion, like a shell abbreviation. An empty expansion removes the
+// abbreviation.
+func (h *Handler) Abbreviate(trigger, expansion string) {
+	if expansion == "" {
+		h.AbbreviateFunc(trigger, nil)
+		return
+	}
+	h.AbbreviateFunc(trigger, func() string { return expansion })
+}
+
+// AbbreviateFunc is Abbreviate with the expansion produced by fn each time
+// the abbreviation is used, e.g. a timestamp or the current directory. fn
+// runs on the processing goroutine. A nil fn removes the abbreviation.
+func (h *Handler) AbbreviateFunc(trigger string, fn func() string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if fn == nil {
+		delete(h.abbrevs, trigger)
+		return
+	}
+	if h.abbrevs == nil {
+		h.abbrevs = make(map[string]func() string)
+	}
+	h.abbrevs[trigger] = fn
+}
+
+// expandAbbrevLocked replaces the word before the cursor with its
+// expansion, if it is an abbreviation - call only while holding h.mu
+func (h *Handler) expandAbbrevLocked() {
+	if len(h.abbrevs) == 0 || h.secret.Load() {
+		return
+	}
+	// The word is everything back to a space or the start of the line
+	end := h.lineOffsetLocked(h.lineCursor)
+	start, chars := end, 0
+	for start > 0 {
+		r, size := utf8.DecodeLastRune(h.currentLine[:start])
+		if r == ' ' || r == '\t' {
+			break
+		}
+		start -= size
+		chars++
+	}
+	fn, ok := h.abbrevs[string(h.currentLine[start:end])]
+	if !ok {
+		return
+	}
+
+	h.mu.Unlock()
+	expansion := fn()
+	h.mu.Lock()
+
+	for ; chars > 0; chars-- {
+		h.backspaceLocked()
+	}
+	h.insertLocked(expansion)
+}
@@ -0,0 +1,47 @@
+package keyboard
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// accelKeys are the keys Options.HoldAccel applies to
+var accelKeys = map[string]bool{
+	"Up": true, "Down": true, "Left": true, "Right": true,
+	"PageUp": true, "PageDown": true,
+}
+
+// DefaultHoldAccel is a HoldAccel curve that doubles the step count for
+// every half second a key is held, up to 16.
+func DefaultHoldAccel(held time.Duration) int {
+	return 1 << min(held/(500*time.Millisecond), 4)
+}
+
+// accelerate tracks how long a navigation key has been held, through the
+// terminal's auto-repeat or :Repeat events, and returns key with the step
+// count from HoldAccel as a ":N" suffix when N > 1
+func (h *Handler) accelerate(key string) string {
+	now := time.Now()
+	base := strings.TrimSuffix(key, ":Repeat")
+	if strings.Contains(base, ":") || !accelKeys[keyClass(base)] {
+		h.holdKey = ""
+		return key
+	}
+
+	gap := repeatFirstGap
+	if h.holdRepeating {
+		gap = repeatGap
+	}
+	repeat := base != key || now.Sub(h.holdLast) <= gap
+	if base != h.holdKey || !repeat {
+		// A new press
+		h.holdKey, h.holdStart, h.holdLast, h.holdRepeating = base, now, now, false
+		return key
+	}
+	h.holdLast, h.holdRepeating = now, true
+	if n := h.holdAccel(now.Sub(h.holdStart)); n > 1 {
+		return base + ":" + strconv.Itoa(n)
+	}
+	return key
+}
@@ -0,0 +1,45 @@
+package keyboard
+
+import (
+	"testing"
+	"time"
+)
+
+// TestHoldAccel: repeats of a held navigation key carry HoldAccel's step
+// count; the first press, other keys, and a fresh press don't.
+func TestHoldAccel(t *testing.T) {
+	var helds []time.Duration
+	h, pw, cleanup := newPipedHandlerWith(t, Options{HoldAccel: func(held time.Duration) int {
+		helds = append(helds, held)
+		return 3
+	}})
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("\x1b[B\x1b[B\x1b[1;1:2Bx\x1b[A\x1b[A")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "Down", "Down:3", "Down:3", "x", "Up", "Up:3")
+	if len(helds) != 3 {
+		t.Errorf("HoldAccel called %d times, want 3", len(helds))
+	}
+
+	time.Sleep(repeatFirstGap + 50*time.Millisecond)
+	if _, err := pw.Write([]byte("\x1b[A")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "Up")
+}
+
+func TestDefaultHoldAccel(t *testing.T) {
+	for held, want := range map[time.Duration]int{
+		0:                       1,
+		400 * time.Millisecond:  1,
+		600 * time.Millisecond:  2,
+		1200 * time.Millisecond: 4,
+		time.Minute:             16,
+	} {
+		if got := DefaultHoldAccel(held); got != want {
+			t.Errorf("DefaultHoldAccel(%v) = %d, want %d", held, got, want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package keyboard
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// AuditRecord is one line of the audit log (Options.AuditLog), written as
+// a JSON object.
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	Kind string    `json:"kind"` // "key", "line", "paste", or "mouse"
+
+	// Data is the key name, or the line or paste text
+	Data  string      `json:"data,omitempty"`
+	Mouse *MouseEvent `json:"mouse,omitempty"`
+
+	// Secret marks input typed while secret (SetSecret), which the default
+	// policy redacts
+	Secret bool `json:"secret,omitempty"`
+
+	// Redacted marks a record whose Data was removed
+	Redacted bool `json:"redacted,omitempty"`
+
+	// TimedOut marks a line cut short by the line timeout
+	TimedOut bool `json:"timed_out,omitempty"`
+}
+
+// Redact removes the record's content, keeping its time and kind.
+func (r *AuditRecord) Redact() {
+	r.Data = ""
+	r.Mouse = nil
+	r.Redacted = true
+}
+
+// auditLog writes AuditRecords as JSON lines
+type auditLog struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	redact func(*AuditRecord) bool
+}
+
+func newAuditLog(w io.Writer, redact func(*AuditRecord) bool) *auditLog {
+	if redact == nil {
+		redact = redactSecret
+	}
+	return &auditLog{enc: json.NewEncoder(w), redact: redact}
+}
+
+// redactSecret is the default audit policy: secret input is redacted
+func redactSecret(r *AuditRecord) bool {
+	if r.Secret {
+		r.Redact()
+	}
+	return true
+}
+
+// audit writes ev to the audit log, if there is one. secret says whether
+// it was typed as secret input.
+func (h *Handler) audit(ev Event, secret bool) {
+	if h.auditLog == nil {
+		return
+	}
+	rec := AuditRecord{Time: ev.Time, Secret: secret, TimedOut: ev.TimedOut}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	switch ev.Kind {
+	case EventKey:
+		rec.Kind, rec.Data = "key", ev.Key
+	case EventLine:
+		rec.Kind, rec.Data = "line", string(ev.Line)
+	case EventPaste:
+		rec.Kind, rec.Data = "paste", string(ev.Paste)
+	case EventMouse:
+		mouse := ev.Mouse
+		rec.Kind, rec.Mouse = "mouse", &mouse
+	}
+
+	a := h.auditLog
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.redact(&rec) {
+		return
+	}
+	if err := a.enc.Encode(&rec); err != nil {
+		h.logAt(slog.LevelWarn, "AuditLog failed", "err", err)
+	}
+}
@@ -0,0 +1,95 @@
+package keyboard
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// auditRecords decodes the JSON lines written to an audit log
+func auditRecords(t *testing.T, log *echoBuffer) []AuditRecord {
+	t.Helper()
+	var recs []AuditRecord
+	dec := json.NewDecoder(strings.NewReader(log.take()))
+	for dec.More() {
+		var rec AuditRecord
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+// TestAuditLog: keys and lines are logged, and secret input is redacted.
+func TestAuditLog(t *testing.T) {
+	log := &echoBuffer{}
+	h, pw, cleanup := newPipedHandlerWith(t, Options{AuditLog: log})
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("a\x1b[A")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "a", "Up")
+
+	h.SetSecret(true, 0)
+	h.SetLineMode(true)
+	if _, err := pw.Write([]byte("pw\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "pw")
+
+	recs := auditRecords(t, log)
+	want := []AuditRecord{
+		{Kind: "key", Data: "a"},
+		{Kind: "key", Data: "Up"},
+		{Kind: "key", Secret: true, Redacted: true},
+		{Kind: "key", Secret: true, Redacted: true},
+		{Kind: "key", Secret: true, Redacted: true},
+		{Kind: "line", Secret: true, Redacted: true},
+	}
+	if len(recs) != len(want) {
+		t.Fatalf("got %d records %+v, want %d", len(recs), recs, len(want))
+	}
+	for i, rec := range recs {
+		if rec.Time.IsZero() {
+			t.Errorf("record %d has no time", i)
+		}
+		rec.Time = want[i].Time
+		if rec != want[i] {
+			t.Errorf("record %d = %+v, want %+v", i, rec, want[i])
+		}
+	}
+}
+
+// TestAuditRedact: a custom policy can drop records and redact others.
+func TestAuditRedact(t *testing.T) {
+	log := &echoBuffer{}
+	h, pw, cleanup := newPipedHandlerWith(t, Options{
+		AuditLog: log,
+		AuditRedact: func(rec *AuditRecord) bool {
+			if rec.Data == "x" {
+				return false
+			}
+			if rec.Kind == "line" {
+				rec.Redact()
+			}
+			return true
+		},
+	})
+	defer cleanup()
+	h.SetLineMode(true)
+
+	if _, err := pw.Write([]byte("xy\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "xy")
+
+	var got []string
+	for _, rec := range auditRecords(t, log) {
+		got = append(got, rec.Kind+":"+rec.Data)
+	}
+	if want := "key:y key:Enter line:"; strings.Join(got, " ") != want {
+		t.Errorf("logged %q, want %q", strings.Join(got, " "), want)
+	}
+}
@@ -0,0 +1,90 @@
+package keyboard
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// benchmarkInput runs input through the parser b.N times. The handler is
+// never started: keys land in a Keys channel nobody reads, and once it is
+// full they are dropped, so only parsing and delivery are measured.
+func benchmarkInput(b *testing.B, opts Options, input []byte) {
+	h := New(opts)
+	escTimeout := time.NewTimer(time.Hour)
+	defer escTimeout.Stop()
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.feed(input, escTimeout)
+	}
+}
+
+func BenchmarkTyping(b *testing.B) {
+	input := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog\r"), 100)
+	benchmarkInput(b, Options{KeyOverflow: OverflowDropNewest}, input)
+}
+
+func BenchmarkTypingUTF8(b *testing.B) {
+	input := bytes.Repeat([]byte("naïve café déjà vu 世界 "), 100)
+	benchmarkInput(b, Options{KeyOverflow: OverflowDropNewest}, input)
+}
+
+func BenchmarkEscapeFlood(b *testing.B) {
+	seqs := []string{"\x1b[A", "\x1b[B", "\x1b[1;5C", "\x1bOP", "\x1b[15~", "\x1b[3;2~", "\x1bx"}
+	var buf bytes.Buffer
+	for i := 0; i < 500; i++ {
+		buf.WriteString(seqs[i%len(seqs)])
+	}
+	benchmarkInput(b, Options{KeyOverflow: OverflowDropNewest}, buf.Bytes())
+}
+
+func BenchmarkMouseDrag(b *testing.B) {
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[<0;1;1M")
+	for i := 2; i < 500; i++ {
+		fmt.Fprintf(&buf, "\x1b[<32;%d;%dM", i%200+1, i%50+1)
+	}
+	buf.WriteString("\x1b[<0;1;1m")
+	benchmarkInput(b, Options{KeyOverflow: OverflowDropNewest}, buf.Bytes())
+}
+
+func BenchmarkPasteMegabyte(b *testing.B) {
+	content := bytes.Repeat([]byte("pasted text, line after line\n"), 1<<20/30)
+	input := append(append([]byte(bracketedPasteStart), content...), bracketedPasteEnd...)
+	noKeys := false
+	benchmarkInput(b, Options{EmitPasteKeys: &noKeys}, input)
+}
+
+// TestStageTiming: OnStageTiming reports each parsed chunk and each key.
+func TestStageTiming(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+	timings := make(chan StageTiming, 16)
+	h.OnStageTiming = func(st StageTiming) { timings <- st }
+
+	if _, err := pw.Write([]byte("ab")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "a", "b")
+
+	var parsed, delivered int
+	for parsed == 0 {
+		select {
+		case st := <-timings:
+			switch st.Stage {
+			case StageParse:
+				parsed += st.Bytes
+			case StageDeliver:
+				delivered++
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("no parse timing reported")
+		}
+	}
+	if parsed != 2 || delivered != 2 {
+		t.Errorf("timings covered %d parsed bytes and %d keys, want 2 and 2", parsed, delivered)
+	}
+}
@@ -0,0 +1,193 @@
+package keyboard
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// CastWriter records input in asciinema v2 format (a header line, then one
+// [time, "i", data] event per write), so sessions can be replayed with
+// ReadCast and Replay or inspected with standard tooling. It is an
+// io.Writer, meant for Options.TeeWriter:
+//
+//	cast, err := keyboard.NewCastWriter(f, 80, 24)
+//	h := keyboard.New(keyboard.Options{InputReader: os.Stdin, TeeWriter: cast})
+//
+// The format holds text, so bytes that are not valid UTF-8 are recorded as
+// U+FFFD.
+type CastWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	start   time.Time
+	partial []byte // Incomplete UTF-8 sequence held for the next write
+}
+
+// castHeader is the first line of an asciinema v2 file
+type castHeader struct {
+	Version   int   `json:"version"`
+	Width     int   `json:"width"`
+	Height    int   `json:"height"`
+	Timestamp int64 `json:"timestamp,omitempty"`
+}
+
+// NewCastWriter writes the header for a terminal of the given size to w
+// and returns a CastWriter whose event times count from now.
+func NewCastWriter(w io.Writer, width, height int) (*CastWriter, error) {
+	start := time.Now()
+	header, err := json.Marshal(castHeader{Version: 2, Width: width, Height: height, Timestamp: start.Unix()})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(header, '\n')); err != nil {
+		return nil, err
+	}
+	return &CastWriter{w: w, start: start}, nil
+}
+
+// Write records p as one input event.
+func (c *CastWriter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data := append(c.partial, p...)
+	c.partial = nil
+	// Keep a rune split across reads whole
+	if i := lastRuneStart(data); i >= 0 && !utf8.FullRune(data[i:]) {
+		c.partial = append([]byte(nil), data[i:]...)
+		data = data[:i]
+	}
+	if len(data) == 0 {
+		return len(p), nil
+	}
+	elapsed := time.Since(c.start).Seconds()
+	event, err := json.Marshal([]any{elapsed, "i", string(data)})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := c.w.Write(append(event, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// lastRuneStart returns the index of the start of the last rune in b if it
+// is multi-byte, or -1
+func lastRuneStart(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if b[i] < utf8.RuneSelf {
+				return -1
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// CastEvent is one input event from a recording: the bytes and when they
+// arrived, relative to the start of the recording.
+type CastEvent struct {
+	Time time.Duration
+	Data []byte
+}
+
+// ReadCast reads the input ("i") events of an asciinema v2 recording,
+// skipping output and other events.
+func ReadCast(r io.Reader) ([]CastEvent, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("cast: %w", err)
+		}
+		return nil, errors.New("cast: empty recording")
+	}
+	var header castHeader
+	if err := json.Unmarshal(sc.Bytes(), &header); err != nil {
+		return nil, fmt.Errorf("cast: header: %w", err)
+	}
+	if header.Version != 2 {
+		return nil, fmt.Errorf("cast: unsupported version %d", header.Version)
+	}
+
+	var events []CastEvent
+	for line := 2; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var (
+			elapsed float64
+			code    string
+			data    string
+		)
+		event := []any{&elapsed, &code, &data}
+		if err := json.Unmarshal(sc.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("cast: line %d: %w", line, err)
+		}
+		if code != "i" {
+			continue
+		}
+		events = append(events, CastEvent{
+			Time: time.Duration(elapsed * float64(time.Second)),
+			Data: []byte(data),
+		})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("cast: %w", err)
+	}
+	return events, nil
+}
+
+// Replay feeds recorded input to the handler as fast as it will take it
+// (see Feed), so events recorded apart may be parsed together. It returns
+// false if the handler stopped first. ReplayTimed keeps the original
+// timing.
+func (h *Handler) Replay(events []CastEvent) bool {
+	for _, ev := range events {
+		if !h.Feed(ev.Data) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReplayTimed feeds recorded input to the handler at the recorded times,
+// counted from the call, so escape timeouts and paste chunking come out as
+// they did in the original session. speed scales the pace: 2 replays twice
+// as fast, and 0 or less means 1. It returns ctx.Err() if ctx is done
+// first, or ErrStopped if the handler stops.
+func (h *Handler) ReplayTimed(ctx context.Context, events []CastEvent, speed float64) error {
+	if speed <= 0 {
+		speed = 1
+	}
+	start := time.Now()
+	for _, ev := range events {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Wait for each event's time from the start, so delays don't add up
+		due := start.Add(time.Duration(float64(ev.Time) / speed))
+		if d := time.Until(due); d > 0 {
+			timer := time.NewTimer(d)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-h.stopChan:
+				timer.Stop()
+				return ErrStopped
+			}
+		}
+		if !h.Feed(ev.Data) {
+			return ErrStopped
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package keyboard
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCastRoundTrip: input recorded through TeeWriter replays as the same
+// keys, with runes split across reads kept whole.
+func TestCastRoundTrip(t *testing.T) {
+	var rec bytes.Buffer
+	cast, err := NewCastWriter(&rec, 80, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, pw, cleanup := newPipedHandlerWith(t, Options{TeeWriter: cast})
+	defer cleanup()
+
+	for _, chunk := range []string{"a\x1b[A", "\xc3", "\xa9"} {
+		if _, err := pw.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectKeys(t, h, "a", "Up", "é")
+	cleanup()
+
+	events, err := ReadCast(&rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events %q, want 2", len(events), events)
+	}
+	if events[1].Time < events[0].Time {
+		t.Errorf("event times out of order: %v, %v", events[0].Time, events[1].Time)
+	}
+
+	h2 := New(Options{})
+	if err := h2.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer h2.Stop()
+	if !h2.Replay(events) {
+		t.Fatal("Replay returned false on a running handler")
+	}
+	expectKeys(t, h2, "a", "Up", "é")
+}
+
+// TestReadCast: output and marker events are skipped, and bad input is
+// reported.
+func TestReadCast(t *testing.T) {
+	events, err := ReadCast(strings.NewReader(`{"version": 2, "width": 80, "height": 24}
+[0.5, "o", "$ "]
+[1.25, "i", "ls\r"]
+[1.5, "m", ""]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || string(events[0].Data) != "ls\r" || events[0].Time.Seconds() != 1.25 {
+		t.Errorf("got %+v", events)
+	}
+
+	for _, bad := range []string{
+		"",
+		`{"version": 1}`,
+		"{\"version\": 2}\n[1.0, \"i\"",
+	} {
+		if _, err := ReadCast(strings.NewReader(bad)); err == nil {
+			t.Errorf("ReadCast(%q) succeeded", bad)
+		}
+	}
+}
+
+// TestReplayTimed: recorded gaps are kept, so a lone Escape times out as it
+// did when recorded, and speed scales the gaps: fast enough, Escape and x
+// arrive together as M-x.
+func TestReplayTimed(t *testing.T) {
+	events := []CastEvent{
+		{Time: 0, Data: []byte("\x1b")},
+		{Time: 200 * time.Millisecond, Data: []byte("x")},
+	}
+	h := New(Options{})
+	if err := h.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Stop()
+
+	start := time.Now()
+	if err := h.ReplayTimed(context.Background(), events, 1); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("replay took %v, want at least 200ms", elapsed)
+	}
+	expectKeys(t, h, "Escape", "x")
+
+	start = time.Now()
+	if err := h.ReplayTimed(context.Background(), events, 2); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("replay at 2x took %v, want at least 100ms", elapsed)
+	}
+	expectKeys(t, h, "Escape", "x")
+
+	if err := h.ReplayTimed(context.Background(), events, 100); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "M-x")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := h.ReplayTimed(ctx, events, 1); err != context.Canceled {
+		t.Errorf("canceled replay returned %v", err)
+	}
+	h.Stop()
+	if err := h.ReplayTimed(context.Background(), events, 1); err != ErrStopped {
+		t.Errorf("replay after Stop returned %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package keyboard
+
+import "strings"
+
+// CharClass is the set of characters line mode accepts (see SetCharClass),
+// as a predicate
+type CharClass func(r rune) bool
+
+var (
+	// ClassDigits accepts 0-9, e.g. for PIN entry
+	ClassDigits CharClass = func(r rune) bool { return r >= '0' && r <= '9' }
+
+	// ClassHex accepts hexadecimal digits, in either case
+	ClassHex CharClass = func(r rune) bool {
+		return r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F'
+	}
+)
+
+// ClassSet accepts exactly the characters in chars
+func ClassSet(chars string) CharClass {
+	return func(r rune) bool { return strings.ContainsRune(chars, r) }
+}
+
+// SetCharClass restricts line mode to the characters in class (nil for
+// any), overriding Options.CharClass. Other characters, typed or pasted,
+// are refused with the bell and reported on OnReject.
+func (h *Handler) SetCharClass(class CharClass) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.charClass = class
+}
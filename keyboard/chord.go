@@ -0,0 +1,82 @@
+package keyboard
+
+import "time"
+
+// defaultChordWindow is the chord window when Options.ChordWindow is unset
+const defaultChordWindow = 100 * time.Millisecond
+
+// Chord makes first followed by second within the chord window
+// (Options.ChordWindow) deliver key in their place, e.g. Chord("j", "k",
+// "Escape") for the classic way out of insert mode. first is held back for
+// the window and delivered as usual if second doesn't follow in time. For
+// a chord pressed in either order, register both. An empty key removes the
+// chord.
+func (h *Handler) Chord(first, second, key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if key == "" {
+		delete(h.chords[first], second)
+		if len(h.chords[first]) == 0 {
+			delete(h.chords, first)
+		}
+		return
+	}
+	if h.chords == nil {
+		h.chords = make(map[string]map[string]string)
+	}
+	if h.chords[first] == nil {
+		h.chords[first] = make(map[string]string)
+	}
+	h.chords[first][second] = key
+}
+
+// chordKey completes or starts a chord with key, and reports whether key
+// was taken: as the second key of the pending chord, or held back as the
+// first key of one. Otherwise the pending first key, if any, has been
+// delivered and key goes on as usual.
+func (h *Handler) chordKey(key string) bool {
+	h.mu.Lock()
+	chorded, completes := h.chords[h.chordFirst][key]
+	_, starts := h.chords[key]
+	h.mu.Unlock()
+
+	if h.chordFirst != "" {
+		if completes {
+			h.chordFirst = ""
+			h.chordGen++
+			h.emitChordKey(chorded)
+			return true
+		}
+		h.flushChord()
+	}
+	if !starts {
+		return false
+	}
+	h.chordFirst = key
+	h.chordGen++
+	gen := h.chordGen
+	h.after(h.chordWindow, func() {
+		if h.chordGen == gen {
+			h.flushChord()
+		}
+	})
+	return true
+}
+
+// flushChord delivers the held first key of a chord that didn't complete
+func (h *Handler) flushChord() {
+	if h.chordFirst == "" {
+		return
+	}
+	first := h.chordFirst
+	h.chordFirst = ""
+	h.chordGen++
+	h.emitChordKey(first)
+}
+
+// emitChordKey emits key past chord detection
+func (h *Handler) emitChordKey(key string) {
+	h.chordFlushing = true
+	h.emitKey(key)
+	h.chordFlushing = false
+}
@@ -0,0 +1,54 @@
+package keyboard
+
+import (
+	"testing"
+	"time"
+)
+
+// TestChord: the second key within the window completes the chord; a late
+// or different second key gets the first delivered as usual.
+func TestChord(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{ChordWindow: 50 * time.Millisecond})
+	defer cleanup()
+	h.Chord("j", "k", "Escape")
+
+	if _, err := pw.Write([]byte("ajkjxk")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "a", "Escape", "j", "x", "k")
+
+	// Too slow: j goes out when the window closes
+	if _, err := pw.Write([]byte("j")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "j")
+	if _, err := pw.Write([]byte("k")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "k")
+
+	// jj: the second j may still start the chord
+	if _, err := pw.Write([]byte("jjk")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "j", "Escape")
+
+	h.Chord("j", "k", "")
+	if _, err := pw.Write([]byte("jk")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "j", "k")
+}
+
+// TestChordLineMode: a chord works in line mode too.
+func TestChordLineMode(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{})
+	defer cleanup()
+	h.Chord("j", "k", "Enter")
+	h.SetLineMode(true)
+
+	if _, err := pw.Write([]byte("ajjk")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "aj")
+}
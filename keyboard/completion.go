@@ -0,0 +1,137 @@
+package keyboard
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// completionMenuRows is the most candidates the completion menu shows at
+// once; it scrolls to keep the selection in view
+const completionMenuRows = 8
+
+// completionMenu is the open candidate menu (Options.CompletionMenu)
+type completionMenu struct {
+	items []string
+	sel   int
+	from  int // byte offset of the text the selection replaces
+	col   int // cursor column (1-based) on the line being edited
+}
+
+// completeLocked completes the word before the cursor with OnComplete, on
+// Tab: a single candidate replaces it, several are narrowed to their
+// common prefix, and if that adds nothing the menu opens (or the bell
+// rings) - call only while holding h.mu
+func (h *Handler) completeLocked() {
+	if h.OnComplete == nil || h.secret.Load() {
+		return
+	}
+	off := h.lineOffsetLocked(h.lineCursor)
+	line := append([]byte(nil), h.currentLine[:off]...)
+	h.mu.Unlock()
+	candidates, from := h.OnComplete(line, off)
+	h.mu.Lock()
+	if from < 0 || from > off || off != h.lineOffsetLocked(h.lineCursor) {
+		return
+	}
+
+	word := string(h.currentLine[from:off])
+	switch len(candidates) {
+	case 0:
+		h.echoLocked("\a")
+	case 1:
+		h.replaceBeforeCursorLocked(from, candidates[0])
+	default:
+		if prefix := commonPrefix(candidates); len(prefix) > len(word) && strings.HasPrefix(prefix, word) {
+			h.replaceBeforeCursorLocked(from, prefix)
+		} else if h.completionMenu {
+			h.menu = &completionMenu{items: candidates, from: from}
+			h.drawMenuLocked()
+		} else {
+			h.echoLocked("\a")
+		}
+	}
+}
+
+// replaceBeforeCursorLocked replaces the line from byte offset from up to
+// the cursor with text - call only while holding h.mu
+func (h *Handler) replaceBeforeCursorLocked(from int, text string) {
+	for n := utf8.RuneCount(h.currentLine[from:h.lineOffsetLocked(h.lineCursor)]); n > 0; n-- {
+		h.backspaceLocked()
+	}
+	h.insertLocked(text)
+}
+
+// menuKeyLocked handles a key while the completion menu is open: Tab/Down
+// and S-Tab/Up move the selection, Enter takes it, Escape closes the menu.
+// Any other key closes it and returns false to be handled as usual - call
+// only while holding h.mu
+func (h *Handler) menuKeyLocked(key string) bool {
+	m := h.menu
+	switch key {
+	case "Tab", "^I", "Down", "^N":
+		m.sel = (m.sel + 1) % len(m.items)
+		h.drawMenuLocked()
+	case "S-Tab", "Up", "^P":
+		m.sel = (m.sel + len(m.items) - 1) % len(m.items)
+		h.drawMenuLocked()
+	case "Enter":
+		h.closeMenuLocked()
+		h.replaceBeforeCursorLocked(m.from, m.items[m.sel])
+	case "Escape":
+		h.closeMenuLocked()
+	default:
+		h.closeMenuLocked()
+		return false
+	}
+	return true
+}
+
+// drawMenuLocked renders the completion menu on the rows below the line
+// being edited, the selection in reverse video, and puts the cursor back -
+// call only while holding h.mu
+func (h *Handler) drawMenuLocked() {
+	m := h.menu
+	m.col = textWidth([]byte(h.prompt)) + textWidth(h.shownLocked(h.currentLine[:h.lineOffsetLocked(h.lineCursor)]))
+	if h.termCols > 0 {
+		m.col %= h.termCols
+	}
+	m.col++
+
+	first := max(0, m.sel-completionMenuRows+1)
+	last := min(len(m.items), first+completionMenuRows)
+	var b strings.Builder
+	for i := first; i < last; i++ {
+		b.WriteString("\r\n\x1b[K")
+		if i == m.sel {
+			b.WriteString("\x1b[7m" + m.items[i] + "\x1b[m")
+		} else {
+			b.WriteString(m.items[i])
+		}
+	}
+	fmt.Fprintf(&b, "\x1b[J\x1b[%dA\x1b[%dG", last-first, m.col)
+	h.echoLocked(b.String())
+}
+
+// closeMenuLocked erases the completion menu - call only while holding h.mu
+func (h *Handler) closeMenuLocked() {
+	h.echoLocked(fmt.Sprintf("\x1b[1B\r\x1b[J\x1b[1A\x1b[%dG", h.menu.col))
+	h.menu = nil
+}
+
+// commonPrefix returns the longest prefix the strings share, whole
+// characters only
+func commonPrefix(items []string) string {
+	prefix := items[0]
+	for _, s := range items[1:] {
+		n := 0
+		for n < len(prefix) && n < len(s) && prefix[n] == s[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	for len(prefix) > 0 && !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix
+}
@@ -0,0 +1,57 @@
+package keyboard
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Confirm asks a yes/no question: it shows prompt with a [Y/n] or [y/N]
+// hint and waits for y or n; Enter takes def, and Escape or ^C cancels
+// with ErrCanceled. Other keys are ignored. The answer is echoed as yes or
+// no. Like Select, it draws on the EchoWriter (or ModeWriter), reads keys
+// with ReadKey, and switches line mode off while it runs.
+func (h *Handler) Confirm(prompt string, def bool) (bool, error) {
+	return h.ConfirmContext(context.Background(), prompt, def)
+}
+
+// ConfirmContext is Confirm, giving up with ctx.Err() when ctx is done.
+func (h *Handler) ConfirmContext(ctx context.Context, prompt string, def bool) (bool, error) {
+	if h.IsLineMode() {
+		h.SetLineMode(false)
+		defer h.SetLineMode(true)
+	}
+	w := h.interactiveWriter()
+
+	hint := "[y/N]"
+	if def {
+		hint = "[Y/n]"
+	}
+	fmt.Fprintf(w, "%s %s ", prompt, hint)
+	for {
+		key, err := h.ReadKeyContext(ctx)
+		if err != nil {
+			io.WriteString(w, "\r\n")
+			return false, err
+		}
+		answer := def
+		switch key {
+		case "y", "Y":
+			answer = true
+		case "n", "N":
+			answer = false
+		case "Enter":
+		case "Escape", "^C":
+			io.WriteString(w, "\r\n")
+			return false, ErrCanceled
+		default:
+			continue
+		}
+		if answer {
+			io.WriteString(w, "yes\r\n")
+		} else {
+			io.WriteString(w, "no\r\n")
+		}
+		return answer, nil
+	}
+}
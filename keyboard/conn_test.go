@@ -0,0 +1,143 @@
+package keyboard
+
+import (
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestReconnect: when the connection closes, OnDisconnect fires, input
+// continues from the reconnected one, and the modes are pushed onto it.
+func TestReconnect(t *testing.T) {
+	local1, remote1 := net.Pipe()
+	local2, remote2 := net.Pipe()
+	defer remote2.Close()
+
+	// Drain what the handler writes to the first connection
+	go io.Copy(io.Discard, remote1)
+
+	disconnected := make(chan error, 1)
+	h := New(Options{
+		InputReader: local1,
+		ModeWriter:  local1,
+		EnterModes:  "<enter>",
+		ReadTimeout: 50 * time.Millisecond,
+		Reconnect:   func() (io.Reader, error) { return local2, nil },
+	})
+	h.OnDisconnect = func(err error) { disconnected <- err }
+	if err := h.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer h.Stop()
+
+	remote1.Write([]byte("a"))
+	expectKeys(t, h, "a")
+	remote1.Close()
+
+	select {
+	case err := <-disconnected:
+		if err != io.EOF {
+			t.Errorf("OnDisconnect err = %v, want io.EOF", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnDisconnect never called")
+	}
+
+	modes := make([]byte, len("<enter>"))
+	if _, err := io.ReadFull(remote2, modes); err != nil || string(modes) != "<enter>" {
+		t.Errorf("modes on new connection = %q, %v; want \"<enter>\"", modes, err)
+	}
+	remote2.Write([]byte("b"))
+	expectKeys(t, h, "b")
+}
+
+// TestStopInterruptsConnRead: Stop unblocks a Read waiting on a connection,
+// and leaves the connection readable afterwards.
+func TestStopInterruptsConnRead(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+	h := New(Options{InputReader: local})
+	disconnected := make(chan struct{}, 1)
+	h.OnDisconnect = func(error) { disconnected <- struct{}{} }
+	if err := h.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	h.Stop()
+
+	// The interrupted read must not be reported as a disconnect
+	select {
+	case <-disconnected:
+		t.Error("OnDisconnect called for Stop")
+	case <-time.After(100 * time.Millisecond):
+	}
+	remote.SetWriteDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, err := remote.Write([]byte("x")); err == nil {
+		t.Error("handler still reading after Stop")
+	}
+
+	remote.SetWriteDeadline(time.Time{})
+	go remote.Write([]byte("y"))
+	buf := make([]byte, 1)
+	if _, err := local.Read(buf); err != nil || buf[0] != 'y' {
+		t.Errorf("Read after Stop = %q, %v", buf, err)
+	}
+}
+
+// TestStopLeavesReaderDeadline: a reader that is not a connection the
+// handler times out or reconnects gets no deadline from Stop.
+func TestStopLeavesReaderDeadline(t *testing.T) {
+	r := &deadlineRecorder{Reader: strings.NewReader("")}
+	h := New(Options{InputReader: r})
+	if err := h.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	h.Stop()
+	if r.deadline.Load() {
+		t.Error("Stop set a read deadline")
+	}
+}
+
+// deadlineRecorder is a reader that records whether a deadline was set
+type deadlineRecorder struct {
+	io.Reader
+	deadline atomic.Bool
+}
+
+func (r *deadlineRecorder) SetReadDeadline(t time.Time) error {
+	if !t.IsZero() {
+		r.deadline.Store(true)
+	}
+	return nil
+}
+
+// sizeRecorder is an input that reports the buffer size it was read with
+type sizeRecorder struct {
+	sizes chan int
+}
+
+func (r *sizeRecorder) Read(p []byte) (int, error) {
+	select {
+	case r.sizes <- len(p):
+	default:
+	}
+	time.Sleep(time.Millisecond)
+	return copy(p, "x"), nil
+}
+
+// TestReadBufferSize: reads use the configured buffer size.
+func TestReadBufferSize(t *testing.T) {
+	noManage := false
+	r := &sizeRecorder{sizes: make(chan int, 1)}
+	h := New(Options{InputReader: r, ManageTerminal: &noManage, ReadBufferSize: 16})
+	if err := h.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer h.Stop()
+	if n := <-r.sizes; n != 16 {
+		t.Errorf("read buffer = %d bytes, want 16", n)
+	}
+}
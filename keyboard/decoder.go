@@ -0,0 +1,92 @@
+package keyboard
+
+import (
+	"io"
+	"time"
+)
+
+// Decoder reads events from an input stream on the caller's goroutine,
+// like encoding/json's Decoder, for synchronous programs that want no
+// background goroutines or channels:
+//
+//	dec := keyboard.NewDecoder(os.Stdin, keyboard.Options{})
+//	for {
+//		ev, err := dec.Next()
+//		if err != nil {
+//			break
+//		}
+//		...
+//	}
+//
+// The caller puts the terminal in raw mode. A lone ESC is told apart from
+// the start of a sequence by the escape timeout when r has read deadlines
+// (a net.Conn, or an *os.File that supports them); otherwise an escape
+// sequence left unfinished at the end of a read is resolved at once, since
+// terminals send each sequence in one write.
+type Decoder struct {
+	r      io.Reader
+	p      *Parser
+	buf    []byte
+	queue  []Event
+	err    error
+	noWait bool // r has no read deadlines
+}
+
+// NewDecoder returns a decoder reading from r that parses input as a
+// Handler with opts would (see NewParser). Options.ReadBufferSize sets the
+// size of its reads.
+func NewDecoder(r io.Reader, opts Options) *Decoder {
+	size := opts.ReadBufferSize
+	if size <= 0 {
+		size = DefaultReadBufferSize
+	}
+	_, deadlines := r.(readDeadliner)
+	return &Decoder{r: r, p: NewParser(opts), buf: make([]byte, size), noWait: !deadlines}
+}
+
+// Next returns the next event, reading as much input as it takes. After
+// the input ends, or a read fails, it returns the events still buffered and
+// then the error (io.EOF at the end of the input).
+func (d *Decoder) Next() (Event, error) {
+	for len(d.queue) == 0 {
+		if d.err != nil {
+			return Event{}, d.err
+		}
+		d.fill()
+	}
+	ev := d.queue[0]
+	d.queue = d.queue[1:]
+	return ev, nil
+}
+
+// fill reads once and parses what it read, or records the read error
+func (d *Decoder) fill() {
+	waiting := d.p.pending() && !d.noWait
+	if waiting {
+		if err := d.r.(readDeadliner).SetReadDeadline(time.Now().Add(escapeTimeout)); err != nil {
+			// Deadlines not supported after all (e.g. a blocking file)
+			d.noWait = true
+			waiting = false
+			d.queue = append(d.queue, d.p.Flush()...)
+			return
+		}
+	}
+	n, err := d.r.Read(d.buf)
+	if waiting {
+		d.r.(readDeadliner).SetReadDeadline(time.Time{})
+	}
+	if n > 0 {
+		d.queue = append(d.queue, d.p.Feed(d.buf[:n])...)
+		if d.noWait {
+			d.queue = append(d.queue, d.p.Flush()...)
+		}
+	}
+	switch {
+	case err == nil:
+	case waiting && isTimeout(err):
+		d.queue = append(d.queue, d.p.Flush()...)
+	default:
+		d.queue = append(d.queue, d.p.Flush()...)
+		d.err = err
+	}
+}
@@ -0,0 +1,67 @@
+package keyboard
+
+import (
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// decodeAll reads events until an error
+func decodeAll(dec *Decoder) ([]string, error) {
+	var events []Event
+	for {
+		ev, err := dec.Next()
+		if err != nil {
+			return parsedKeys(events), err
+		}
+		events = append(events, ev)
+	}
+}
+
+// TestDecoder: events are read from the stream in order, a trailing ESC is
+// resolved, and the end of input is io.EOF.
+func TestDecoder(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("a\x1b[A\x1b[200~hi\x1b[201~\x1b"), Options{})
+	got, err := decodeAll(dec)
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("err = %v, want io.EOF", err)
+	}
+	if want := []string{"a", "Up", "paste:hi", "Escape"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded %q, want %q", got, want)
+	}
+	if _, err := dec.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Next after EOF = %v", err)
+	}
+}
+
+// TestDecoderEscapeTimeout: with read deadlines, a lone ESC is Escape once
+// the escape timeout passes, and ESC x in one write is M-x.
+func TestDecoderEscapeTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	dec := NewDecoder(server, Options{})
+
+	go func() {
+		client.Write([]byte("\x1b"))
+	}()
+	ev, err := dec.Next()
+	if err != nil || ev.Key != "Escape" {
+		t.Fatalf("Next = %+v, %v, want Escape", ev, err)
+	}
+
+	go func() {
+		client.Write([]byte("\x1bx"))
+		client.Close()
+	}()
+	got, err := decodeAll(dec)
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("err = %v, want io.EOF", err)
+	}
+	if want := []string{"M-x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,131 @@
+package keyboard
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// encodeCSILetter holds the final byte of keys sent as CSI 1;mod X when
+// modified (and CSI X, or SS3 X for F1-F4, when not)
+var encodeCSILetter = map[string]byte{
+	"Up": 'A', "Down": 'B', "Right": 'C', "Left": 'D',
+	"Home": 'H', "End": 'F',
+	"F1": 'P', "F2": 'Q', "F3": 'R', "F4": 'S',
+}
+
+// encodeCSITilde holds the number of keys sent as CSI n;mod ~
+var encodeCSITilde = map[string]int{
+	"Insert": 2, "Delete": 3, "PageUp": 5, "PageDown": 6,
+	"F5": 15, "F6": 17, "F7": 18, "F8": 19, "F9": 20, "F10": 21, "F11": 23, "F12": 24,
+}
+
+// encodeSimple holds keys sent as a fixed byte
+var encodeSimple = map[string]byte{
+	"Enter": '\r', "Tab": '\t', "Backspace": 0x7f, "Escape": 0x1b, "Space": ' ',
+}
+
+// EncodeKey returns the bytes an xterm-compatible terminal sends for a key
+// name as produced by the handler ("a", "^C", "M-x", "C-Up", "S-F5",
+// "Enter"), e.g. to inject keys into a child program or drive a TUI under
+// test. "C-" on a letter is accepted as Ctrl+letter ("C-c" is "^C"), and
+// "S-" on a letter as the capital ("S-a" is "A").
+func EncodeKey(name string) ([]byte, error) {
+	shift, alt, ctrl, super := false, false, false, false
+	base := name
+	for len(base) > 2 && base[1] == '-' && strings.IndexByte("SMCs", base[0]) >= 0 {
+		switch base[0] {
+		case 'S':
+			shift = true
+		case 'M':
+			alt = true
+		case 'C':
+			ctrl = true
+		case 's':
+			super = true
+		}
+		base = base[2:]
+	}
+
+	// Keys with an xterm modifier parameter
+	mod := 1
+	if shift {
+		mod++
+	}
+	if alt {
+		mod += 2
+	}
+	if ctrl {
+		mod += 4
+	}
+	if super {
+		mod += 8
+	}
+	if c, ok := encodeCSILetter[base]; ok {
+		switch {
+		case mod > 1:
+			return []byte(fmt.Sprintf("\x1b[1;%d%c", mod, c)), nil
+		case c >= 'P':
+			return []byte{0x1b, 'O', c}, nil
+		}
+		return []byte{0x1b, '[', c}, nil
+	}
+	if n, ok := encodeCSITilde[base]; ok {
+		if mod > 1 {
+			return []byte(fmt.Sprintf("\x1b[%d;%d~", n, mod)), nil
+		}
+		return []byte(fmt.Sprintf("\x1b[%d~", n)), nil
+	}
+	if base == "Tab" && shift && !ctrl && !super {
+		return withAlt(alt, []byte("\x1b[Z")), nil
+	}
+
+	// Shift on a letter makes it a capital (M-S-a is ESC A), and Shift or
+	// Super on a key sent as a fixed byte is the kitty form CSI code;mod u
+	// (S-Space is CSI 32;2u)
+	if shift && !ctrl && !super && len(base) == 1 && base[0] >= 'a' && base[0] <= 'z' {
+		return withAlt(alt, []byte{base[0] - 'a' + 'A'}), nil
+	}
+	if b, ok := encodeSimple[base]; ok && (shift || super) {
+		return []byte(fmt.Sprintf("\x1b[%d;%du", b, mod)), nil
+	}
+
+	// Everything else: Shift and Super can't be expressed, Alt is an ESC
+	// prefix, and Ctrl makes a control character
+	if shift || super {
+		return nil, fmt.Errorf("keyboard: cannot encode key %q", name)
+	}
+	if b, ok := encodeSimple[base]; ok && !ctrl {
+		return withAlt(alt, []byte{b}), nil
+	}
+	if len(base) == 2 && base[0] == '^' && !ctrl {
+		ctrl, base = true, base[1:]
+	}
+	if ctrl {
+		if len(base) != 1 {
+			return nil, fmt.Errorf("keyboard: cannot encode key %q", name)
+		}
+		c := base[0]
+		switch {
+		case c == '?':
+			return withAlt(alt, []byte{0x7f}), nil
+		case c >= 'a' && c <= 'z':
+			return withAlt(alt, []byte{c - 'a' + 1}), nil
+		case c >= '@' && c <= '_':
+			return withAlt(alt, []byte{c - '@'}), nil
+		}
+		return nil, fmt.Errorf("keyboard: cannot encode key %q", name)
+	}
+	if r, size := utf8.DecodeRuneInString(base); size == len(base) && r != utf8.RuneError {
+		return withAlt(alt, []byte(base)), nil
+	}
+	return nil, fmt.Errorf("keyboard: cannot encode key %q", name)
+}
+
+// withAlt prefixes b with ESC if alt is set
+func withAlt(alt bool, b []byte) []byte {
+	if alt {
+		return append([]byte{0x1b}, b...)
+	}
+	return b
+}
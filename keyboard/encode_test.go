@@ -0,0 +1,44 @@
+package keyboard
+
+import "testing"
+
+// TestEncodeKeyRoundTrip: encoded keys parse back to the same name.
+func TestEncodeKeyRoundTrip(t *testing.T) {
+	names := []string{
+		"a", "Z", "é", "^C", "^A", "M-x", "M-Enter", "Enter", "Tab", "S-Tab",
+		"Backspace", "Up", "C-Up", "S-Left", "M-Right", "S-C-Up", "Home", "End",
+		"F1", "F4", "S-F1", "F5", "C-F5", "F12", "Delete", "PageUp", "S-PageDown",
+		"M-S-a", "M-S-z", "S-Space", "S-M-Space", "S-Enter", "s-Space", "S-Backspace",
+	}
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+	for _, name := range names {
+		b, err := EncodeKey(name)
+		if err != nil {
+			t.Errorf("EncodeKey(%q): %v", name, err)
+			continue
+		}
+		if _, err := pw.Write(b); err != nil {
+			t.Fatal(err)
+		}
+		expectKeys(t, h, name)
+	}
+}
+
+// TestEncodeKeyForms: alternative spellings and unencodable keys.
+func TestEncodeKeyForms(t *testing.T) {
+	if b, err := EncodeKey("C-c"); err != nil || string(b) != "\x03" {
+		t.Errorf("EncodeKey(\"C-c\") = %q, %v; want \"\\x03\"", b, err)
+	}
+	if b, err := EncodeKey("Escape"); err != nil || string(b) != "\x1b" {
+		t.Errorf("EncodeKey(\"Escape\") = %q, %v", b, err)
+	}
+	if b, err := EncodeKey("S-a"); err != nil || string(b) != "A" {
+		t.Errorf("EncodeKey(\"S-a\") = %q, %v; want \"A\"", b, err)
+	}
+	for _, name := range []string{"C-S-a", "S-1", "Nonsense", "C-Enter"} {
+		if _, err := EncodeKey(name); err == nil {
+			t.Errorf("EncodeKey(%q) succeeded, want an error", name)
+		}
+	}
+}
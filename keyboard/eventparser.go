@@ -0,0 +1,76 @@
+package keyboard
+
+import "time"
+
+// Parser turns input bytes into events with no I/O, goroutines, or
+// channels: the byte-to-event half of a Handler, for fuzzing, for tests,
+// and for programs that read input themselves.
+//
+//	p := keyboard.NewParser(keyboard.Options{})
+//	for _, ev := range p.Feed(buf[:n]) { ... }
+//
+// Feed returns keys (EventKey), bracketed pastes (EventPaste), and mouse
+// reports (EventMouse); there is no line assembly. A lone ESC, or a
+// sequence cut short, stays pending until more input arrives or Flush is
+// called - call it once input has been idle for a moment, as a Handler does
+// after 50ms (Decoder does this for an io.Reader). A Parser is not safe for
+// concurrent use.
+type Parser struct {
+	h      *Handler
+	timer  *time.Timer // Never read: pending escapes end with Flush
+	events []Event
+}
+
+// NewParser returns a parser that parses input as a Handler with opts
+// would: the options that shape parsing (Terminal, DoubleEscape,
+// SpecialMarker, NormalizeKeypad, MouseZeroBased, DecodeMacOSOption,
+// ModifierTap, Simple, Logger) apply. The rest, including the timed ones
+// (RepeatCoalesce, ScrollCoalesce, MotionRate, LongPress,
+// SynthesizeRepeat, LineTimeout), are ignored.
+func NewParser(opts Options) *Parser {
+	// The parser's handler is never started: it has no input buffer or
+	// channels, and its stop channel is closed so nothing can wait on it
+	h := &Handler{terminalFd: -1, stopChan: make(chan struct{})}
+	close(h.stopChan)
+	h.configureParsing(opts)
+	p := &Parser{h: h, timer: time.NewTimer(time.Hour)}
+	p.timer.Stop()
+	h.sink = func(ev Event) {
+		ev.Time = time.Now()
+		p.events = append(p.events, ev)
+	}
+	return p
+}
+
+// Feed parses data and returns the events it completes.
+func (p *Parser) Feed(data []byte) []Event {
+	p.h.feed(data, p.timer)
+	return p.take()
+}
+
+// Flush ends a pending escape sequence or control string, as the Handler's
+// escape timeout does, and returns the events that produces: Escape for a
+// lone ESC, an Alt key, nothing for an unknown sequence, or an Alt key and
+// the keys typed after it for an unfinished control string.
+func (p *Parser) Flush() []Event {
+	switch {
+	case p.h.state == stateEscape && len(p.h.escBuffer) > 0:
+		p.h.resolveEscape()
+	case p.h.state == stateString:
+		p.h.abandonString(p.timer)
+	}
+	return p.take()
+}
+
+// pending reports whether an escape sequence or control string is waiting
+// for more bytes
+func (p *Parser) pending() bool {
+	return p.h.state == stateEscape && len(p.h.escBuffer) > 0 || p.h.state == stateString
+}
+
+// take returns the events gathered so far
+func (p *Parser) take() []Event {
+	events := p.events
+	p.events = nil
+	return events
+}
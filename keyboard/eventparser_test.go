@@ -0,0 +1,114 @@
+package keyboard
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parsedKeys describes events compactly: key names, "paste:<text>", and
+// "mouse"
+func parsedKeys(events []Event) []string {
+	var out []string
+	for _, ev := range events {
+		switch ev.Kind {
+		case EventKey:
+			out = append(out, ev.Key)
+		case EventPaste:
+			out = append(out, "paste:"+string(ev.Paste))
+		case EventMouse:
+			out = append(out, "mouse")
+		}
+	}
+	return out
+}
+
+// TestParser: keys, pastes, and mouse reports come back from Feed, and a
+// lone ESC waits for Flush.
+func TestParser(t *testing.T) {
+	p := NewParser(Options{})
+	got := parsedKeys(p.Feed([]byte("a\x1b[A\x1b[200~hi\x1b[201~\x1b[<0;5;7M")))
+	if want := []string{"a", "Up", "paste:hi", "mouse"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Feed = %q, want %q", got, want)
+	}
+
+	if got := p.Feed([]byte("\x1b")); len(got) != 0 {
+		t.Errorf("lone ESC gave %q before Flush", parsedKeys(got))
+	}
+	if got := parsedKeys(p.Flush()); !reflect.DeepEqual(got, []string{"Escape"}) {
+		t.Errorf("Flush = %q, want [Escape]", got)
+	}
+	if got := p.Flush(); len(got) != 0 {
+		t.Errorf("second Flush = %q", parsedKeys(got))
+	}
+
+	if got := parsedKeys(p.Feed([]byte("\x1b[1;5"))); len(got) != 0 {
+		t.Errorf("partial sequence gave %q", got)
+	}
+	if got := parsedKeys(p.Feed([]byte("C"))); !reflect.DeepEqual(got, []string{"C-Right"}) {
+		t.Errorf("completed sequence = %q, want [C-Right]", got)
+	}
+}
+
+// TestParserControlString: an unfinished control string is given back as
+// keys by Flush, or as soon as it is too long to be a reply.
+func TestParserControlString(t *testing.T) {
+	p := NewParser(Options{})
+	if got := p.Feed([]byte("\x1bP")); len(got) != 0 {
+		t.Errorf("ESC P gave %q", parsedKeys(got))
+	}
+	if got := p.Feed([]byte("qhi")); len(got) != 0 {
+		t.Errorf("string body gave %q before Flush", parsedKeys(got))
+	}
+	if got, want := parsedKeys(p.Flush()), []string{"M-S-p", "q", "h", "i"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Flush = %q, want %q", got, want)
+	}
+
+	long := strings.Repeat("a", maxControlString+1)
+	if got := p.Feed([]byte("\x1b_" + long)); len(got) != maxControlString+2 || got[0].Key != "M-_" {
+		t.Errorf("overlong string gave %d events, want M-_ and %d keys", len(got), maxControlString+1)
+	}
+}
+
+// TestParserIgnoresTimedOptions: options that would hold events back for a
+// timer are off in a Parser, so every event comes out of the Feed that
+// completes it.
+func TestParserIgnoresTimedOptions(t *testing.T) {
+	noKeys := false
+	p := NewParser(Options{
+		RepeatCoalesce: 10 * time.Millisecond,
+		ScrollCoalesce: 10 * time.Millisecond,
+		MotionRate:     10,
+		LongPress:      10 * time.Millisecond,
+		EmitPasteKeys:  &noKeys,
+	})
+	got := parsedKeys(p.Feed([]byte("aaab\x1b[200~hi\x1b[201~")))
+	if want := []string{"a", "a", "a", "b", "paste:hi"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Feed = %q, want %q", got, want)
+	}
+}
+
+// FuzzParser: any input parses without panicking, and splitting it between
+// two Feeds gives the same events as feeding it whole.
+func FuzzParser(f *testing.F) {
+	for _, seed := range []string{
+		"abc", "\x1b[A", "\x1b[1;5C", "\x1bx", "\x1b[200~paste\x1b[201~",
+		"\x1b[<0;5;7M", "\x1b[M !!", "\x1b]52;c;aGk=\x07", "é\xff", "\x1bP1$r0m\x1b\\",
+	} {
+		f.Add([]byte(seed), uint8(len(seed)/2))
+	}
+	f.Fuzz(func(t *testing.T, data []byte, cut uint8) {
+		whole := NewParser(Options{})
+		want := parsedKeys(append(whole.Feed(data), whole.Flush()...))
+
+		i := min(int(cut), len(data))
+		split := NewParser(Options{})
+		events := split.Feed(data[:i])
+		events = append(events, split.Feed(data[i:])...)
+		got := parsedKeys(append(events, split.Flush()...))
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q split at %d = %q, whole = %q", data, i, got, want)
+		}
+	})
+}
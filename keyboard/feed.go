@@ -0,0 +1,32 @@
+package keyboard
+
+import "log/slog"
+
+// Feed supplies input bytes directly, for frontends that receive input as
+// events rather than from an io.Reader - e.g. a WebAssembly build (GOOS=js)
+// fed from xterm.js's onData. Create the handler with a nil InputReader to
+// make Feed the only input. It may be called from any goroutine, blocks
+// while the input buffer is full, and returns false once the handler is
+// stopped.
+func (h *Handler) Feed(data []byte) bool {
+	select {
+	case <-h.stopChan:
+		return false
+	default:
+	}
+	if len(data) == 0 {
+		return true
+	}
+	h.stats.bytesRead.Add(uint64(len(data)))
+	if h.teeWriter != nil {
+		h.tee(data)
+	}
+	return h.rawBytes.write(data, h.stopChan)
+}
+
+// tee copies input to Options.TeeWriter
+func (h *Handler) tee(data []byte) {
+	if _, err := h.teeWriter.Write(data); err != nil {
+		h.logAt(slog.LevelWarn, "TeeWriter failed", "err", err)
+	}
+}
@@ -0,0 +1,38 @@
+package keyboard
+
+import "testing"
+
+// TestFeed: with no InputReader, fed bytes are parsed like read ones.
+func TestFeed(t *testing.T) {
+	h := New(Options{})
+	if err := h.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer h.Stop()
+
+	if !h.Feed([]byte("a\x1b[A")) {
+		t.Fatal("Feed returned false on a running handler")
+	}
+	expectKeys(t, h, "a", "Up")
+
+	h.Stop()
+	if h.Feed([]byte("b")) {
+		t.Error("Feed returned true after Stop")
+	}
+}
+
+// TestTeeWriter: read and fed input is copied to the TeeWriter as is.
+func TestTeeWriter(t *testing.T) {
+	tee := &echoBuffer{}
+	h, pw, cleanup := newPipedHandlerWith(t, Options{TeeWriter: tee})
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("a\x1b[A")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "a", "Up")
+	h.Feed([]byte("\x1b[200~x"))
+	if got := tee.take(); got != "a\x1b[A\x1b[200~x" {
+		t.Errorf("tee = %q", got)
+	}
+}
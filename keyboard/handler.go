@@ -5,12 +5,18 @@ package keyboard
 
 import (
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
+	"os"
+	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode/utf8"
 
@@ -23,6 +29,18 @@ type PasteChunk struct {
 	IsFinal bool   // True if this is the final chunk
 }
 
+// PasteEvent is a bracketed paste delivered on the Pastes channel. Exactly
+// one of Content and Chunks is set.
+type PasteEvent struct {
+	Content []byte // The complete paste content
+
+	// Chunks streams the content of a paste still in progress (with
+	// Options.StreamPastes), in chunks of up to PasteChunkSize bytes, and is
+	// closed when the paste ends. It must be drained: input processing
+	// waits while it is full.
+	Chunks <-chan []byte
+}
+
 // Handler handles raw keyboard input, parsing escape sequences
 // and providing both key events and line assembly.
 type Handler struct {
@@ -30,19 +48,47 @@ type Handler struct {
 
 	// Input source
 	inputReader io.Reader     // Raw input source (any io.Reader)
-	rawBytes    chan []byte   // Channel for raw byte chunks
+	rawBytes    *byteRing     // Raw input queued for processLoop
 	stopChan    chan struct{} // Signal to stop reading
+	readDone    chan struct{} // Closed when readLoop exits
 
 	// Output channels (plain Go channels)
 	Keys  chan string  // Parsed key events ("a", "M-a", "F1", etc.)
 	Lines chan []byte  // Assembled lines
 
+	// RawEvents carries the exact input bytes behind every parsed event
+	// (nil unless Options.EmitRawEvents is set)
+	RawEvents chan RawEvent
+
+	// Pastes carries bracketed pastes (nil unless Options.PasteChannel is set)
+	Pastes chan PasteEvent
+
+	// Mouse carries mouse reports as typed events instead of Mouse* keys on
+	// Keys (nil unless Options.MouseChannel is set)
+	Mouse chan MouseEvent
+
+	// Events carries keys as KeyEvent values, alongside Keys unless
+	// Options.LegacyKeys is false (nil unless Options.EventChannel is set)
+	Events chan KeyEvent
+
 	// Callbacks (optional, called in addition to channel sends)
 	OnKey        func(key string)     // Called on each key event
 	OnLine       func(line []byte)    // Called on each completed line
 	OnPaste      func(content []byte) // Called on bracketed paste content (complete)
 	OnPasteChunk func(chunk PasteChunk) // Called on incremental paste chunks
 
+	// OnResize is called by Resize with the new terminal size in cells
+	OnResize func(cols, rows int)
+
+	// OnDisconnect is called when reading input fails, e.g. with io.EOF when
+	// a connection closes (not when Stop ends reading)
+	OnDisconnect func(err error)
+
+	// OnStageTiming, if set, is called with the time spent in each pipeline
+	// stage (see Stage), for profiling parser performance. It runs on the
+	// processing goroutine, so keep it cheap.
+	OnStageTiming func(t StageTiming)
+
 	// OnClipboard is called with an OSC 52 clipboard *response*
 	// (ESC ] 52 ; <selection> ; <base64> BEL/ST) - the terminal's answer to a
 	// clipboard-read query. selection is the target byte ('c', 'p', ...) and
@@ -52,71 +98,608 @@ type Handler struct {
 	// text. It reuses the same buffering mechanism as bracketed paste.
 	OnClipboard func(selection byte, data []byte)
 
+	// OnKeyFilter is called with every parsed key before middleware, OnKey,
+	// line assembly, or the Keys channel see it. Return the key to deliver
+	// (rewritten if desired) and emit=false to swallow it, e.g. to map
+	// CapsLock to Escape or drop noisy events.
+	OnKeyFilter func(key string) (string, bool)
+
+	// OnUnknownSequence is called with the raw bytes of an escape sequence
+	// the parser could not decode (starting with ESC). Return true to consume
+	// it; return false to fall back to emitting it as Escape followed by the
+	// individual characters.
+	OnUnknownSequence func(seq []byte) bool
+
+	// OnKeyDropped and OnLineDropped are called with any key or line that
+	// could not be delivered because its channel was full (see
+	// Options.KeyOverflow and Options.LineOverflow). They are never called
+	// under OverflowBlock, except for an item abandoned by Stop.
+	OnKeyDropped  func(key string)
+	OnLineDropped func(line []byte)
+
+	// OnOSC is called with OSC strings (ESC ] code ; payload BEL/ST), such as
+	// color query replies, that have no handler registered with HandleOSC.
+	// OSC 52 goes to OnClipboard instead. OSC strings are never emitted as keys.
+	OnOSC func(code int, payload []byte)
+
+	// OnControlString is called with DCS, APC, PM, and SOS strings
+	// (ESC intro ... ST) such as XTGETTCAP replies or kitty graphics
+	// acknowledgements. intro is 'P' (DCS), '_' (APC), '^' (PM), or 'X'
+	// (SOS); payload is everything between the introducer and ST. These
+	// strings are never emitted as keys.
+	OnControlString func(intro byte, payload []byte)
+
+	// OnSuspend and OnResume are called around a job-control suspend (see
+	// Options.HandleSuspend). OnSuspend runs after the terminal has been
+	// restored and just before the process stops; OnResume runs after raw
+	// mode and protocol modes have been re-established, and is the place to
+	// redraw the screen.
+	OnSuspend func()
+	OnResume  func()
+
+	// OnValidateChar and OnValidateLine check input in line mode, e.g. for
+	// structured prompts like IP addresses. OnValidateChar is called with
+	// each character typed or pasted and the line as it would be with it
+	// inserted; OnValidateLine is called with the line when Enter submits
+	// it. Returning false rejects the character (it is not inserted) or the
+	// line (it stays being edited), and rings the bell on the echo output.
+	OnValidateChar func(line []byte, r rune) bool
+	OnValidateLine func(line []byte) bool
+
+	// OnComplete, if set, completes the word before the cursor in line
+	// mode when Tab is pressed. It gets the line up to the cursor and
+	// returns the candidates for the text from byte offset from to the
+	// cursor. One candidate replaces that text; several are narrowed to
+	// their common prefix and then shown in a menu (Options.CompletionMenu)
+	// or met with the bell.
+	OnComplete func(line []byte, cursor int) (candidates []string, from int)
+
+	// OnHighlight, if set, styles line mode's echo, e.g. for live syntax
+	// highlighting in a REPL: it gets the whole line after every edit and
+	// returns it as it should be shown (with SGR color escapes, say, but the
+	// same visible text). The handler re-renders the line with it and puts
+	// the cursor back. It runs on the processing goroutine with the
+	// handler's lock held, so it must not call Handler methods. Not used
+	// for secret input.
+	OnHighlight func(line []byte) string
+
+	// OnLineTimeout is called with the partial line when the line timeout
+	// (Options.LineTimeout) expires. The partial line is also delivered on
+	// Lines, and to subscribers as an EventLine with TimedOut set, but
+	// OnLine is not called for it.
+	OnLineTimeout func(partial []byte)
+
+	// OnReject is called with each character line mode refuses because it
+	// is not in the character class (Options.CharClass)
+	OnReject func(r rune)
+
+	// OnLineFull is called with each character line mode refuses because
+	// the line already has MaxLineLength characters
+	OnLineFull func(r rune)
+
 	// Terminal handling (only used if input is os.Stdin and is a terminal)
 	terminalFd        int         // File descriptor if we're managing terminal mode
 	originalTermState *term.State // Original state to restore
+	// Copy of originalTermState for restoreAfterPanic when it can't get h.mu
+	panicRestore atomic.Pointer[term.State]
 	managesTerminal   bool        // True if we put terminal in raw mode
 
-	// State
+	// Connection input (ReadTimeout, Reconnect): inputReader is swapped
+	// under h.mu on reconnect, and so is modeWriter if it was the input
+	readTimeout       time.Duration
+	reconnect         func() (io.Reader, error)
+	modeWriterIsInput bool
+	readBufferSize    int // size of readLoop's read buffer
+
+	// Protocol modes (kitty keyboard, mouse, bracketed paste, ...) written to
+	// modeWriter whenever raw mode is entered or left, so they are popped on
+	// suspend and re-pushed on resume along with raw mode.
+	modeWriter io.Writer
+	enterModes string
+	exitModes  string
+
+	terminalActive bool // True while raw mode and EnterModes are in effect
+
+	// Mouse reporting modes, written with the protocol modes while the mouse
+	// is enabled; mouseOff suppresses mouse events (SetMouseEnabled)
+	mouseEnterModes string
+	mouseExitModes  string
+	mouseOff        bool
+
+	// Terminal release (ReleaseTerminal/AcquireTerminal). While released the
+	// read loop issues no reads; acquiredChan is closed on reacquire.
+	released     bool
+	acquiredChan chan struct{}
+
+	// SGR-Pixels mouse mode (1016): reports carry pixel coordinates, turned
+	// into cells using the cell size (0 if unknown)
+	mousePixels bool
+	cellWidth   int
+	cellHeight  int
+
+	// Mouse coordinate origin (0 or 1) and the terminal size in cells used
+	// to clamp mouse coordinates (0 if unknown). sizeExplicit is set once
+	// the application calls SetTerminalSize, which then wins over the size
+	// read from the terminal.
+	mouseOrigin  int
+	termCols     int
+	termRows     int
+	sizeExplicit bool
+
+	// Scroll coalescing (processing goroutine only): the pending burst's
+	// last position, action, and event count, and a generation number that
+	// invalidates a window timer once its burst has been flushed
+	scrollCoalesce time.Duration
+	scrollAccel    func(count int) int
+	scrollPos      string
+	scrollEvent    MouseEvent
+	scrollAction   string
+	scrollCount    int
+	scrollGen      int
+
+	// Repeat coalescing (processing goroutine only, see repeat.go): the
+	// pending burst's key and count, its window timer's generation, and
+	// whether the burst is being emitted
+	repeatCoalesce time.Duration
+	repeatKey      string
+	repeatCount    int
+	repeatGen      int
+	repeatFlushing bool
+
+	// Repeat synthesis (processing goroutine only, see synthrepeat.go): the
+	// keys it applies to, the held key, when it was pressed and last
+	// auto-repeated, the auto-repeat period seen, and the repeat timer's
+	// generation
+	keyRepeat      KeyRepeat
+	repeatKeys     map[string]bool
+	synthKey       string
+	synthPress     time.Time
+	synthLast      time.Time
+	synthPeriod    time.Duration
+	synthRepeating bool
+	synthGen       int
+
+	// Hold acceleration (processing goroutine only, see accel.go): the
+	// curve, and the held key, when it was pressed and last repeated
+	holdAccel     func(held time.Duration) int
+	holdKey       string
+	holdStart     time.Time
+	holdLast      time.Time
+	holdRepeating bool
+
+	// Chords (see chord.go): first key to chord key by second key, guarded
+	// by mu; and, on the processing goroutine only, the window, the held
+	// first key, its timer's generation, and whether a key is being emitted
+	// past chord detection
+	chords        map[string]map[string]string
+	chordWindow   time.Duration
+	chordFirst    string
+	chordGen      int
+	chordFlushing bool
+
+	// Hover throttling (processing goroutine only): the minimum interval
+	// between MouseMotion keys, when the last one went out, the latest
+	// position held back, and a generation number for the flush timer
+	motionInterval time.Duration
+	motionLast     time.Time
+	motionPending  string
+	motionEvent    MouseEvent
+	motionGen      int
+
+	// Drag tracking (processing goroutine only): the last button press,
+	// whether that button is still held, and whether motion since then has
+	// started a drag
+	pressButton    int
+	pressX, pressY int
+	pressed        bool
+	dragging       bool
+
+	// Modifier taps (ModifierTap): the modifier pressed with no other key
+	// yet, and the last completed tap (for double taps)
+	modifierTap time.Duration
+	tapMod      string
+	tapStart    time.Time
+	lastTapMod  string
+	lastTapTime time.Time
+	tapEmitting bool
+
+	// Caps Lock / Num Lock as last reported by a kitty key (see LockState)
+	locks atomic.Uint32
+
+	// Subscribers (Subscribe), each with its own channel. numSubs mirrors
+	// len(subs) so publish can skip h.mu when there are none
+	subs    []*subscription
+	numSubs atomic.Int32
+
+	// Hit regions (SetRegion), in registration order
+	regions []Region
+
+	// Long-press detection (processing goroutine only): the hold duration,
+	// and a generation number that cancels the armed timer
+	longPress    time.Duration
+	longPressGen int
+
+	// Per-handler escape sequence bindings (BindSequence), consulted before
+	// the built-in escBindings table. bindings is guarded by mu; the parser
+	// reads the copy published in bound, without the lock.
+	bindings map[string]string
+	bound    atomic.Pointer[bindingTable]
+
+	// Terminal profile (quirks) in use, and whether the application set
+	// DecodeMacOSOption itself (which then wins over the profile)
+	profile             TerminalProfile
+	macOSOptionExplicit bool
+	autoProfile         bool
+
+	// Terminal query replies awaited by the Query* methods
+	queryTimeout time.Duration
+	queryWaiters []*queryWaiter
+
+	// Job-control suspend (Ctrl+Z / SIGTSTP) handling
+	handleSuspend   bool
+	restoreOnSignal bool
+	suspendSigs     chan os.Signal // SIGTSTP interception channel (Unix only)
+
+	// State. inLineReadMode is read for every key, so it is an atomic
+	// rather than guarded by h.mu
 	running        bool
-	inLineReadMode bool // True when line assembly is active
+	inLineReadMode atomic.Bool // True when line assembly is active
+	secret         atomic.Bool // True for secret line input (SetSecret)
+
+	// Pause state. While paused no events are emitted; input is either left
+	// unread (PauseBuffer) or read and dropped (PauseDiscard).
+	paused    bool
+	pauseMode PauseMode
+	wakeChan  chan struct{} // Nudges processLoop when the pause state changes
+
+	// Work scheduled by timers to run on the processing goroutine
+	tasks chan func()
+
+	// Keys pushed back by UnreadKey, the next one to read last
+	unread []string
+
+	// Verbatim input channel (StartVerbatim), nil when parsing
+	verbatim chan []byte
+
+	// Copy of all input (Options.TeeWriter), nil for none
+	teeWriter io.Writer
+
+	// Input audit trail (Options.AuditLog), nil for none
+	auditLog *auditLog
+
+	// Where a Parser's handler sends events instead of delivering them,
+	// nil for a running handler
+	sink func(Event)
 
 	// Line assembly state - stores raw bytes for proper I/O semantics
 	currentLine []byte
 	// Track UTF-8 character boundaries for backspace (number of bytes per char)
 	charByteLengths []int
+	// Characters line mode accepts (SetCharClass), nil for any
+	charClass CharClass
+	// Completion candidate menu: whether to use it, and the open one
+	completionMenu bool
+	menu           *completionMenu
+	// Abbreviations expanded in line mode (Abbreviate)
+	abbrevs map[string]func() string
+	// Mask echoed for secret input (SetSecret), 0 for none
+	secretMask rune
+	// Prompt echoed before each line (SetPrompt), and the line length limit
+	prompt        string
+	maxLineLength int
+	// Keys that end a line besides Enter, and whether the line keeps them
+	lineDelimiters map[string]bool
+	keepDelimiter  bool
+	// Line timeout; bumping lineTimeoutGen cancels a pending one
+	lineTimeout    time.Duration
+	lineTimeoutGen int
+	// Cursor position in characters, and whether keys may move it
+	lineCursor    int
+	cursorEditing bool
+	// Multi-line paste handling, and the pasted lines held for later reads
+	pasteLines PasteLinePolicy
+	pasteJoin  string
+	// How pasted newlines are emitted as keys
+	pasteNewlines PasteNewlineMode
+	pasteRest  []byte
 
 	// Escape sequence buffer
-	escBuffer []byte
-	inEscape  bool
+	escBuffer    []byte
+	doubleEscape DoubleEscapeMode
+
+	// macOS Terminal Option+arrow keys: the optional "Special" marker, and
+	// the flag for the key being emitted (KeyEvent.MacOSOption)
+	specialMarker  bool
+	macOSOptionKey bool
+
+	// Report keypad keys under their main keyboard names
+	normalizeKeypad bool
+
+	// No line assembly, paste handling, or mouse parsing (Options.Simple)
+	simple bool
+
+	// Typing statistics (Options.TypingStats), nil when off
+	typing *typingStats
+
+	// Debouncing and rate caps (see ratelimit.go): the last key delivered
+	// and when, and when each capped class was last delivered
+	debounce     time.Duration
+	debounceKey  string
+	debounceTime time.Time
+	rateLimits   map[string]time.Duration
+	rateLast     map[string]time.Time
+
+	state        parserState // see parser.go
 
 	// UTF-8 multi-byte character buffer
 	utf8Buffer    []byte
 	utf8Remaining int // bytes remaining to complete current UTF-8 char
 
 	// Bracketed paste state
-	inPaste          bool
-	pasteBuffer      []byte // Buffer for detecting end sequence (kept small for chunking)
-	fullPasteContent []byte // Accumulator for full paste content (for OnPaste callback)
-	pasteChunkSize   int    // Size of chunks to emit during paste (default: 1024)
-
-	// OSC 52 clipboard-response state - the same accumulate-into-a-buffer idea
-	// as bracketed paste, but with an OSC terminator (BEL or ST) and base64
-	// content, emitted on OnClipboard instead of OnPaste. Kept in its own small
-	// buffer (not fullPasteContent) so the well-tested paste path is untouched;
-	// the two are never in flight at the same time.
-	inClipboard     bool
-	clipboardBuffer []byte // accumulates "<selection>;<base64>"
-	clipboardEsc    bool   // last byte was ESC (a possible ST terminator start)
-
-	// macOS Option key decoding
-	decodeMacOSOption bool // When true, decode macOS Option+key chars to M-key notation
+	paste          pasteBuf // Content so far, the end sequence included once it arrives
+	pasteEmitted   int      // Bytes of it already emitted as chunks
+	pasteDiscard   bool     // Only chunks are wanted: emitted content is released
+	pasteChunkSize int      // Size of chunks to emit during paste (default: 1024)
+
+	// Streamed pastes (StreamPastes): the chunk stream of the paste in
+	// progress, nil when not streaming
+	streamPastes bool
+	pasteStream  chan []byte
+
+	// OSC string state (ESC ] Ps ; Pt BEL/ST) - the same accumulate-into-a-
+	// buffer idea as bracketed paste, but with an OSC terminator (BEL or ST).
+	// Kept in its own small buffer (not the paste buffer) so the well-tested
+	// paste path is untouched; the two are never in flight at the same time.
+	// OSC 52 clipboard responses are decoded and emitted on OnClipboard.
+	oscCode   int    // numeric Ps selecting the OSC command
+	oscBuffer []byte // accumulates Pt (e.g. "<selection>;<base64>" for 52)
+	oscEsc    bool   // last byte was ESC (a possible ST terminator start)
+	oscRoutes map[int]func(payload []byte)
+
+	// DCS/APC/PM/SOS string state (ESC P|_|^|X ... ST). Same shape as OSC;
+	// the body is delivered on OnControlString.
+	stringIntro  byte   // 'P' (DCS), '_' (APC), '^' (PM), or 'X' (SOS)
+	stringBuffer []byte // accumulates the body
+	stringEsc    bool   // last byte was ESC (a possible ST terminator start)
+
+	// macOS Option key decoding (atomic: read for every key)
+	decodeMacOSOption atomic.Bool // When true, decode macOS Option+key chars to M-key notation
 
 	// Paste key echo. When false, bracketed-paste content is delivered only via
 	// OnPaste/OnPasteChunk and is NOT also re-emitted as individual key events.
 	emitPasteKeys bool
 
+	// Keys are sent on Keys (always, unless Options.LegacyKeys is false)
+	legacyKeys bool
+
+	// Middleware chain between parsing and delivery (see Use). Replaced,
+	// never modified, so emitKey can load it without h.mu
+	middleware atomic.Pointer[[]Middleware]
+
+	// Raw event tracking (only when RawEvents is enabled): the input bytes
+	// and keys of the event currently being parsed
+	rawPending []byte
+	rawKeys    []string
+	rawUnknown bool
+
+	// Channel overflow policies
+	keyOverflow  OverflowPolicy
+	lineOverflow OverflowPolicy
+
 	// Echo output (where to echo typed characters)
 	echoWriter io.Writer
 
-	// Debug callback (optional)
-	debugFn func(string)
+	// Structured logger (optional). DebugFn, if set, is wrapped as one.
+	logger *slog.Logger
+
+	// Counters reported by Stats
+	stats handlerStats
 }
 
+// OverflowPolicy selects what happens when an output channel is full.
+// Dropped items are reported via OnKeyDropped / OnLineDropped.
+type OverflowPolicy int
+
+const (
+	// OverflowDropOldest discards the oldest buffered item to make room
+	OverflowDropOldest OverflowPolicy = iota
+	// OverflowDropNewest discards the item being sent
+	OverflowDropNewest
+	// OverflowBlock waits until the consumer makes room (or Stop is called)
+	OverflowBlock
+)
+
+// DoubleEscapeMode selects how two ESC bytes in a row - a quick double tap
+// of Escape - are reported.
+type DoubleEscapeMode int
+
+const (
+	// DoubleEscapeMeta waits for the escape timeout, so ESC ESC can still
+	// start a macOS Option+arrow sequence (ESC ESC [ A), and then reports
+	// M-Escape
+	DoubleEscapeMeta DoubleEscapeMode = iota
+	// DoubleEscapeKeys reports two Escape keys as soon as the second ESC
+	// arrives
+	DoubleEscapeKeys
+	// DoubleEscapeKey reports one "DoubleEscape" key as soon as the second
+	// ESC arrives
+	DoubleEscapeKey
+)
+
 // Options configures the Handler
 type Options struct {
-	// InputReader is the source of raw bytes (required)
+	// InputReader is the source of raw bytes. If nil, input is supplied by
+	// calling Feed instead.
 	InputReader io.Reader
 
+	// ReadTimeout, for an InputReader with read deadlines (a net.Conn),
+	// bounds each Read; a read that times out is simply retried. Stop
+	// always interrupts a blocked Read on such a reader. Default: 0 (no
+	// deadline)
+	ReadTimeout time.Duration
+
+	// Reconnect, if set, is called after OnDisconnect when reading fails,
+	// and returns the reader to continue from, e.g. a redialed connection.
+	// It does its own retrying and backoff; returning an error ends input.
+	// If the ModeWriter was the old connection, the new one (if writable)
+	// takes its place and is sent the enter modes.
+	Reconnect func() (io.Reader, error)
+
 	// EchoWriter is where to echo typed characters during line mode (optional)
 	EchoWriter io.Writer
 
+	// TeeWriter, if set, receives a copy of all input bytes as they are
+	// read (or fed), before parsing, e.g. to log a session or forward it to
+	// another process. Secret input is included. A slow writer slows input.
+	TeeWriter io.Writer
+
+	// AuditLog, if set, receives a record of every parsed event (keys,
+	// lines, pastes, and mouse reports) as JSON lines (see AuditRecord),
+	// for consoles that must keep an input audit trail. Secret input is
+	// redacted.
+	AuditLog io.Writer
+
+	// AuditRedact, if set, replaces the default redaction policy: it is
+	// called with each audit record, may edit it (Redact removes its
+	// content), and returns false to leave it out of the log.
+	AuditRedact func(rec *AuditRecord) bool
+
+	// Simple turns off line assembly, paste handling, and mouse parsing for
+	// applications that only want named keys, as fast as possible.
+	// SetLineMode(true) is ignored and ReadLine returns ErrSimple; bracketed
+	// paste markers are dropped, so pasted text arrives as plain keys; and
+	// mouse reports are discarded, with MouseEnterModes never sent.
+	// Default: false
+	Simple bool
+
 	// KeyBufferSize is the size of the Keys channel buffer (default: 64)
 	KeyBufferSize int
 
 	// LineBufferSize is the size of the Lines channel buffer (default: 16)
 	LineBufferSize int
 
+	// KeyOverflow selects what happens when a key is emitted while the Keys
+	// channel is full (default: OverflowDropOldest)
+	KeyOverflow OverflowPolicy
+
+	// LineOverflow selects what happens when a line is completed while the
+	// Lines channel is full (default: OverflowDropOldest)
+	LineOverflow OverflowPolicy
+
+	// EmitRawEvents creates the RawEvents channel, which receives the exact
+	// input bytes behind each parsed event (and each unparseable sequence),
+	// for multiplexers that must forward input downstream unchanged. Uses
+	// KeyBufferSize and KeyOverflow. Default: false
+	EmitRawEvents bool
+
+	// Backpressure makes the whole pipeline block instead of dropping input
+	// when consumers fall behind: both channels use OverflowBlock and the
+	// read loop stops reading ahead, so unread input stays in the terminal's
+	// own buffer until the application catches up. Use it when input must
+	// never be lost. Overrides KeyOverflow and LineOverflow. Default: false
+	Backpressure bool
+
+	// ScrollCoalesce, if positive, gathers bursts of identical scroll events
+	// (same direction and modifiers) arriving within this window into one
+	// event: the last position, then the action with a ":N" count suffix
+	// when N > 1, e.g. "MouseScrollDown:7". Default: 0 (every step emitted)
+	ScrollCoalesce time.Duration
+
+	// ScrollAccel optionally maps a coalesced burst's raw event count to the
+	// count reported, e.g. to scroll further on fast flicks. Returning 0
+	// keeps the raw count.
+	ScrollAccel func(count int) int
+
+	// RepeatCoalesce, if positive, gathers bursts of the same key arriving
+	// within this window (a held key's auto-repeat, or a press and the
+	// kitty protocol's :Repeat events) into one key with a ":N" count
+	// suffix when N > 1, e.g. "Down:5", so slow consumers keep up. Line
+	// mode, mouse keys, and releases are left alone. KeyEvent.Count has the
+	// count. Default: 0 (every key emitted)
+	RepeatCoalesce time.Duration
+
+	// HoldAccel, if set, speeds up held arrow and PageUp/PageDown keys so
+	// lists scroll faster the longer a key is held: each repeat (the
+	// terminal's auto-repeat, or :Repeat events) gets the step count HoldAccel
+	// returns for how long the key has been held, as a ":N" suffix when
+	// N > 1, e.g. "Down:4". KeyEvent.Count has the count. See
+	// DefaultHoldAccel. Default: nil (off)
+	HoldAccel func(held time.Duration) int
+
+	// ChordWindow is how long a chord's first key waits for its second
+	// (see Chord). Default: 100ms
+	ChordWindow time.Duration
+
+	// SynthesizeRepeat, if its Interval is positive, gives held navigation
+	// keys a uniform repeat model on terminals that don't report repeats:
+	// the terminal's own auto-repeat copies are swallowed, and while they
+	// keep coming the key repeats as "Down:Repeat" (as under the kitty
+	// protocol) after Delay and then every Interval. Default: off
+	SynthesizeRepeat KeyRepeat
+
+	// LongPress, if positive, emits a MouseLeftLongPress@x,y key (or
+	// Middle/Right, with modifier prefixes) when a button is held this long
+	// without moving more than one cell, e.g. to open a context menu. The
+	// release still follows as usual. Default: 0 (off)
+	LongPress time.Duration
+
+	// ModifierTap, if positive, reports a modifier pressed and released on
+	// its own within this time as a tap key (S-Tap, C-Tap, A-Tap, s-Tap,
+	// ...), and a second tap of it within this time after the first as
+	// S-DoubleTap etc. too, for bindings like double-Shift. Needs the kitty
+	// keyboard protocol with event types and all keys reported (flags 2|8,
+	// ESC [ > 10 u). Default: 0 (off)
+	ModifierTap time.Duration
+
+	// MouseChannel creates the Mouse channel and delivers mouse reports
+	// there as MouseEvent values instead of as Mouse@x,y / MouseLeftPress
+	// style keys on Keys. Uses KeyBufferSize and KeyOverflow. Default:
+	// false (legacy keys)
+	MouseChannel bool
+
+	// SpecialMarker emits a "Special" key before each macOS Terminal
+	// Option+arrow key (M-Up etc. sent as ESC ESC [ A), as older versions
+	// always did. KeyEvent.MacOSOption marks these keys instead.
+	// Default: false
+	SpecialMarker bool
+
+	// NormalizeKeypad reports numeric keypad keys under their main
+	// keyboard names ("5", "Enter", "Up") for applications that don't tell
+	// them apart. Otherwise keys the terminal marks as keypad keys (kitty
+	// protocol, application keypad mode) are KP-5, KP-Enter, KP-Up, ...
+	// KP-Begin, which has no main keyboard counterpart, is kept.
+	// Default: false
+	NormalizeKeypad bool
+
+	// DoubleEscape selects how a quick ESC ESC is reported. The immediate
+	// modes give up macOS Terminal's Option+arrow sequences, which start
+	// with ESC ESC. Default: DoubleEscapeMeta
+	DoubleEscape DoubleEscapeMode
+
+	// EventChannel creates the Events channel, which receives every key
+	// as a KeyEvent. Uses KeyBufferSize and KeyOverflow. Default: false
+	EventChannel bool
+
+	// LegacyKeys, when EventChannel is set, says whether keys are still
+	// sent on Keys as strings too; set it to false once everything reads
+	// Events. Default: true
+	LegacyKeys *bool
+
+	// MotionRate, if positive, limits hover motion (MouseMotion@x,y keys,
+	// see MouseAnyMotionOn) to at most this many events per second. Motion
+	// in between is collapsed so the latest position is always delivered.
+	// Default: 0 (unthrottled)
+	MotionRate int
+
+	// MouseZeroBased reports mouse coordinates 0-based (top-left cell is
+	// 0,0) instead of the protocol's 1-based values. Either way they are
+	// clamped to the terminal size when it is known. Default: false
+	MouseZeroBased bool
+
+	// ReadBufferSize is the most input read from InputReader in one Read.
+	// Larger buffers take big pastes in fewer reads. Default: 4096
+	ReadBufferSize int
+
 	// PasteChunkSize is the size of chunks emitted during bracketed paste (default: 1024)
 	// Only used when OnPasteChunk callback is set
 	PasteChunkSize int
@@ -125,9 +708,26 @@ type Options struct {
 	// to M-key notation (e.g., ∂ → M-d, Ø → M-O). Default: true on Darwin, false otherwise
 	DecodeMacOSOption *bool
 
-	// DebugFn is called with debug messages (optional)
+	// Logger receives structured, leveled events (optional): raw input and
+	// parsed keys at Debug, state transitions at Info, lost or undecodable
+	// input at Warn, read failures at Error.
+	Logger *slog.Logger
+
+	// DebugFn is called with every log event flattened to a string
+	// (optional). Ignored if Logger is set.
 	DebugFn func(string)
 
+	// PasteChannel creates the Pastes channel, which receives a PasteEvent
+	// for each bracketed paste, alongside OnPaste and (unless EmitPasteKeys
+	// is false) the paste keys. Uses LineBufferSize and LineOverflow.
+	// Default: false
+	PasteChannel bool
+
+	// StreamPastes makes the Pastes channel deliver each paste as soon as it
+	// starts, with its content streamed on PasteEvent.Chunks, instead of
+	// once it is complete. Default: false
+	StreamPastes bool
+
 	// ManageTerminal controls whether to put stdin in raw mode.
 	// Only applies if InputReader is os.Stdin and is a terminal.
 	// Default: true
@@ -140,6 +740,122 @@ type Options struct {
 	// overflow the Keys channel and lose events. Default: true (backward
 	// compatible); set to false to deliver paste only via the callbacks.
 	EmitPasteKeys *bool
+
+	// PasteLines selects what line mode does with a paste that contains
+	// newlines (see PasteLinePolicy). Default: PasteLinesDiscard
+	PasteLines PasteLinePolicy
+
+	// PasteJoin replaces each pasted newline under PasteLinesJoin.
+	// Default: " "
+	PasteJoin string
+
+	// CursorEditing lets line mode move the cursor within the line (Left,
+	// Right, Home, End, ^B, ^F, ^A, ^E) and delete under it (Delete). Edits
+	// in the middle of the line are echoed with cursor movement escapes.
+	// Default: false (editing at the end of the line only)
+	CursorEditing bool
+
+	// LineTimeout, if positive, gives up on a line when the user stops
+	// typing for this long: the partial line is delivered as if submitted,
+	// flagged by OnLineTimeout and Event.TimedOut. The wait starts when line
+	// mode is enabled and restarts with every key. See also SetLineTimeout.
+	// Default: 0 (wait forever)
+	LineTimeout time.Duration
+
+	// Debounce, if positive, drops a key that repeats the previous key
+	// within this long of it, for terminals or keyboards that double-report
+	// keys. Held keys then repeat at most once per Debounce.
+	// Default: 0 (off)
+	Debounce time.Duration
+
+	// RateLimits caps noisy classes of keys at one per interval, dropping
+	// the rest. Classes are key names without modifiers or mouse position:
+	// "MouseScrollUp", "MouseMotion", "Up", ... A capped mouse class drops
+	// the whole event, on the Mouse channel too. See also ScrollCoalesce,
+	// which keeps the count of the scroll steps it merges. Default: nil
+	RateLimits map[string]time.Duration
+
+	// TypingStats collects typing statistics (key counts, per-key
+	// frequency, a WPM estimate), reported by TypingStats, for typing
+	// tutors and telemetry. Default: false
+	TypingStats bool
+
+	// CompletionMenu shows a menu of the candidates below the line when
+	// OnComplete offers several with nothing in common to complete. Tab or
+	// Down and S-Tab or Up move through it, Enter takes the selection, and
+	// Escape closes it. Default: false (the bell rings instead)
+	CompletionMenu bool
+
+	// CharClass, if set, restricts line mode to the characters it accepts,
+	// e.g. ClassDigits for a numeric field. Others are refused with the bell
+	// and reported on OnReject. See also SetCharClass. Default: nil (any)
+	CharClass CharClass
+
+	// MaxLineLength, if positive, is the most characters line mode accepts
+	// on a line, e.g. for a fixed-width form field. Further characters are
+	// refused with the bell and reported on OnLineFull. See also
+	// SetMaxLineLength. Default: 0 (no limit)
+	MaxLineLength int
+
+	// LineDelimiters are keys that end a line in line mode besides Enter,
+	// e.g. ";" for statements or "^D" (EOT) for records. They end lines as
+	// typed; in a paste they are plain text. See also SetLineDelimiters.
+	// Default: nil (Enter only)
+	LineDelimiters []string
+
+	// KeepDelimiter ends each line ended by one of LineDelimiters with the
+	// delimiter's bytes ("stmt;", "record\x04"). Default: false
+	KeepDelimiter bool
+
+	// PasteNewlines selects how newlines in a paste are emitted as keys
+	// (see PasteNewlineMode). Default: PasteNewlinesPreserve
+	PasteNewlines PasteNewlineMode
+
+	// ModeWriter is the terminal's output, e.g. os.Stdout (optional). It
+	// receives EnterModes and ExitModes, and the requests sent by the Query*
+	// methods, which need it set.
+	ModeWriter io.Writer
+
+	// Terminal selects the terminal profile (sequence table and quirks) to
+	// use. If nil and the input is this process's terminal (a tty, such as
+	// os.Stdin), one is detected from TERM_PROGRAM and TERM at Start; see
+	// DetectTerminalProfile. Other input (a pipe, a connection, Feed) gets
+	// no quirks unless a profile is set here. Use &TerminalProfile{} to
+	// apply no quirks.
+	Terminal *TerminalProfile
+
+	// QueryTimeout bounds how long the Query* methods wait for the
+	// terminal's reply (default: 500ms)
+	QueryTimeout time.Duration
+
+	// EnterModes is written to ModeWriter each time the handler enters raw
+	// mode (Start, and on resume after a suspend). Put the enable sequences
+	// for kitty keyboard, mouse reporting, bracketed paste, etc. here.
+	EnterModes string
+
+	// ExitModes is written to ModeWriter each time the handler leaves raw
+	// mode (Stop, and before a suspend). It should undo EnterModes.
+	ExitModes string
+
+	// MouseEnterModes and MouseExitModes are like EnterModes and ExitModes
+	// but for mouse reporting only, so SetMouseEnabled can switch it on and
+	// off at runtime, e.g. MouseTrackingOn+MouseSGROn and
+	// MouseSGROff+MouseTrackingOff.
+	MouseEnterModes string
+	MouseExitModes  string
+
+	// HandleSuspend makes Ctrl+Z (and an external SIGTSTP) suspend the
+	// process the way a cooked-mode terminal would: the terminal is restored
+	// and ExitModes written, the process stops, and on SIGCONT raw mode and
+	// EnterModes are re-applied. The ^Z key is consumed rather than emitted.
+	// Only supported on Unix. Default: false
+	HandleSuspend bool
+
+	// RestoreOnSignal installs SIGINT, SIGTERM, and SIGHUP handlers that stop
+	// the handler (restoring the terminal and writing ExitModes) and then
+	// re-raise the signal, so killing the process doesn't leave the shell in
+	// raw mode. Only supported on Unix. Default: false
+	RestoreOnSignal bool
 }
 
 // New creates a new keyboard Handler.
@@ -156,36 +872,133 @@ func New(opts Options) *Handler {
 	if pasteChunkSize <= 0 {
 		pasteChunkSize = DefaultPasteChunkSize
 	}
+	readBufferSize := opts.ReadBufferSize
+	if readBufferSize <= 0 {
+		readBufferSize = DefaultReadBufferSize
+	}
 
 	manageTerminal := true
 	if opts.ManageTerminal != nil {
 		manageTerminal = *opts.ManageTerminal
 	}
 
-	// Default to true on Darwin (macOS), false otherwise
-	decodeMacOSOption := runtime.GOOS == "darwin"
-	if opts.DecodeMacOSOption != nil {
-		decodeMacOSOption = *opts.DecodeMacOSOption
-	}
-
 	// Default to true (paste is echoed as keys) for backward compatibility.
 	emitPasteKeys := true
 	if opts.EmitPasteKeys != nil {
 		emitPasteKeys = *opts.EmitPasteKeys
 	}
 
+	// Under backpressure the read loop can only get one read ahead, so a
+	// stalled consumer stops reading from the input altogether.
+	rawBufSize := 16 * readBufferSize
+	keyOverflow, lineOverflow := opts.KeyOverflow, opts.LineOverflow
+	if opts.Backpressure {
+		rawBufSize = readBufferSize
+		keyOverflow, lineOverflow = OverflowBlock, OverflowBlock
+	}
+
 	h := &Handler{
 		inputReader:       opts.InputReader,
-		rawBytes:          make(chan []byte, 64),
+		rawBytes:          newByteRing(rawBufSize),
 		stopChan:          make(chan struct{}),
+		wakeChan:          make(chan struct{}, 1),
+		tasks:             make(chan func(), 16),
 		Keys:              make(chan string, keyBufSize),
 		Lines:             make(chan []byte, lineBufSize),
 		echoWriter:        opts.EchoWriter,
-		debugFn:           opts.DebugFn,
 		terminalFd:        -1,
 		pasteChunkSize:    pasteChunkSize,
-		decodeMacOSOption: decodeMacOSOption,
+		readBufferSize:    readBufferSize,
 		emitPasteKeys:     emitPasteKeys,
+		keyOverflow:       keyOverflow,
+		lineOverflow:      lineOverflow,
+		modeWriter:        opts.ModeWriter,
+		enterModes:        opts.EnterModes,
+		exitModes:         opts.ExitModes,
+		handleSuspend:     opts.HandleSuspend,
+		restoreOnSignal:   opts.RestoreOnSignal,
+	}
+	h.configureParsing(opts)
+	h.scrollCoalesce = opts.ScrollCoalesce
+	h.scrollAccel = opts.ScrollAccel
+	h.repeatCoalesce = opts.RepeatCoalesce
+	h.holdAccel = opts.HoldAccel
+	h.chordWindow = opts.ChordWindow
+	if h.chordWindow <= 0 {
+		h.chordWindow = defaultChordWindow
+	}
+	if opts.SynthesizeRepeat.Interval > 0 {
+		h.keyRepeat = opts.SynthesizeRepeat
+		keys := h.keyRepeat.Keys
+		if keys == nil {
+			keys = navigationKeys
+		}
+		h.repeatKeys = make(map[string]bool, len(keys))
+		for _, k := range keys {
+			h.repeatKeys[k] = true
+		}
+	}
+	h.readTimeout = opts.ReadTimeout
+	h.reconnect = opts.Reconnect
+	h.modeWriterIsInput = sameStream(opts.ModeWriter, opts.InputReader)
+	h.teeWriter = opts.TeeWriter
+	if opts.AuditLog != nil {
+		h.auditLog = newAuditLog(opts.AuditLog, opts.AuditRedact)
+	}
+	if !h.simple {
+		h.mouseEnterModes = opts.MouseEnterModes
+	}
+	h.longPress = opts.LongPress
+	if opts.MotionRate > 0 {
+		h.motionInterval = time.Second / time.Duration(opts.MotionRate)
+	}
+	h.mouseExitModes = opts.MouseExitModes
+	h.autoProfile = opts.Terminal == nil && isLocalTerminal(opts.InputReader)
+
+	h.queryTimeout = opts.QueryTimeout
+	if h.queryTimeout <= 0 {
+		h.queryTimeout = DefaultQueryTimeout
+	}
+
+	if opts.EmitRawEvents {
+		h.RawEvents = make(chan RawEvent, keyBufSize)
+	}
+	if opts.MouseChannel {
+		h.Mouse = make(chan MouseEvent, keyBufSize)
+	}
+	h.pasteLines = opts.PasteLines
+	h.pasteJoin = opts.PasteJoin
+	h.pasteNewlines = opts.PasteNewlines
+	h.cursorEditing = opts.CursorEditing
+	h.maxLineLength = opts.MaxLineLength
+	h.setLineDelimitersLocked(opts.LineDelimiters, opts.KeepDelimiter)
+	h.charClass = opts.CharClass
+	h.completionMenu = opts.CompletionMenu
+	h.lineTimeout = opts.LineTimeout
+	if opts.TypingStats {
+		h.typing = &typingStats{}
+	}
+	h.debounce = opts.Debounce
+	if len(opts.RateLimits) > 0 {
+		h.rateLimits = make(map[string]time.Duration, len(opts.RateLimits))
+		for class, interval := range opts.RateLimits {
+			h.rateLimits[class] = interval
+		}
+		h.rateLast = make(map[string]time.Time)
+	}
+	if h.pasteJoin == "" {
+		h.pasteJoin = " "
+	}
+	h.legacyKeys = true
+	if opts.EventChannel {
+		h.Events = make(chan KeyEvent, keyBufSize)
+		if opts.LegacyKeys != nil {
+			h.legacyKeys = *opts.LegacyKeys
+		}
+	}
+	if opts.PasteChannel {
+		h.Pastes = make(chan PasteEvent, lineBufSize)
+		h.streamPastes = opts.StreamPastes
 	}
 
 	// Check if input is a terminal file descriptor
@@ -202,6 +1015,35 @@ func New(opts Options) *Handler {
 	return h
 }
 
+// configureParsing applies the options that shape how input bytes become
+// events, shared by New and NewParser
+func (h *Handler) configureParsing(opts Options) {
+	// Default to true on Darwin (macOS), false otherwise
+	decodeMacOSOption := runtime.GOOS == "darwin"
+	if opts.DecodeMacOSOption != nil {
+		decodeMacOSOption = *opts.DecodeMacOSOption
+	}
+	h.decodeMacOSOption.Store(decodeMacOSOption)
+	h.macOSOptionExplicit = opts.DecodeMacOSOption != nil
+	h.simple = opts.Simple
+	if !opts.MouseZeroBased {
+		h.mouseOrigin = 1
+	}
+
+	h.logger = opts.Logger
+	if h.logger == nil && opts.DebugFn != nil {
+		h.logger = slog.New(&debugFnHandler{fn: opts.DebugFn})
+	}
+
+	if opts.Terminal != nil {
+		h.applyProfile(*opts.Terminal)
+	}
+	h.doubleEscape = opts.DoubleEscape
+	h.specialMarker = opts.SpecialMarker
+	h.normalizeKeypad = opts.NormalizeKeypad
+	h.modifierTap = opts.ModifierTap
+}
+
 // Start begins reading from input and processing keys.
 func (h *Handler) Start() error {
 	h.mu.Lock()
@@ -211,25 +1053,38 @@ func (h *Handler) Start() error {
 		return fmt.Errorf("handler already running")
 	}
 
-	// Put terminal in raw mode only if we're managing it
-	if h.managesTerminal {
-		state, err := term.MakeRaw(h.terminalFd)
-		if err != nil {
-			return fmt.Errorf("failed to enable raw mode: %w", err)
-		}
-		h.originalTermState = state
-		h.debug("Terminal set to raw mode")
+	if h.autoProfile {
+		h.applyProfileLocked(DetectTerminalProfile())
+		h.autoProfile = false
+	}
+
+	if err := h.enterTerminalLocked(); err != nil {
+		return err
 	}
 
 	h.running = true
+	if h.handleSuspend {
+		// Before the goroutines, so a Ctrl+Z never finds it unset
+		h.notifySuspendLocked()
+	}
 
-	// Start the read goroutine
-	go h.readLoop()
+	// Start the read goroutine (without an InputReader, input comes from Feed)
+	if h.inputReader != nil {
+		h.readDone = make(chan struct{})
+		go h.readLoop()
+	}
 
 	// Start the processing goroutine
 	go h.processLoop()
 
-	h.debug("Handler started")
+	if h.handleSuspend {
+		go h.watchSuspend(h.suspendSigs)
+	}
+	if h.restoreOnSignal {
+		go h.watchSignals()
+	}
+
+	h.logAt(slog.LevelInfo, "Handler started")
 	return nil
 }
 
@@ -246,16 +1101,180 @@ func (h *Handler) Stop() error {
 	close(h.stopChan)
 	h.running = false
 
-	// Restore terminal state if we changed it
+	// Interrupt a Read blocked on a connection (a net.Conn input, or one the
+	// handler times out or reconnects), and clear the deadline once
+	// readLoop is out, so the connection can still be read after Stop.
+	// Other readers, such as a terminal file or a session wrapper, are left
+	// alone.
+	if d, ok := h.inputReader.(readDeadliner); ok && h.interruptsRead() {
+		d.SetReadDeadline(time.Now())
+		done := h.readDone
+		go func() {
+			<-done
+			d.SetReadDeadline(time.Time{})
+		}()
+	}
+
+	if err := h.restoreTerminalLocked(); err != nil {
+		return err
+	}
+
+	h.logAt(slog.LevelInfo, "Handler stopped")
+	return nil
+}
+
+// ReleaseTerminal hands the terminal back to cooked mode so the application
+// can run a child program that owns it ($EDITOR, a pager, a shell). Protocol
+// modes are popped and input is no longer read; line mode, pause state, and
+// all other configuration are kept. Call AcquireTerminal when the child exits.
+func (h *Handler) ReleaseTerminal() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.running {
+		return fmt.Errorf("handler not running")
+	}
+	if h.released {
+		return nil
+	}
+	h.released = true
+	h.acquiredChan = make(chan struct{})
+	h.logAt(slog.LevelInfo, "Terminal released")
+	return h.restoreTerminalLocked()
+}
+
+// AcquireTerminal re-enters raw mode, re-pushes protocol modes, and resumes
+// reading input after ReleaseTerminal.
+func (h *Handler) AcquireTerminal() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.released {
+		return nil
+	}
+	if err := h.enterTerminalLocked(); err != nil {
+		return err
+	}
+	h.released = false
+	close(h.acquiredChan)
+	h.acquiredChan = nil
+	h.logAt(slog.LevelInfo, "Terminal acquired")
+	return nil
+}
+
+// IsTerminalReleased returns true between ReleaseTerminal and AcquireTerminal.
+func (h *Handler) IsTerminalReleased() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.released
+}
+
+// RestoreOnPanic restores the terminal (cooked mode, protocol modes popped)
+// if the calling goroutine is panicking, then continues the panic. Defer it
+// at the top of main and of any goroutine that may panic while the handler
+// is running:
+//
+//	defer h.RestoreOnPanic()
+//
+// The handler's own goroutines do this already, so a panic in a callback
+// such as OnKey also leaves the terminal usable.
+func (h *Handler) RestoreOnPanic() {
+	if r := recover(); r != nil {
+		h.restoreAfterPanic()
+		panic(r)
+	}
+}
+
+// panicLockWait bounds how long restoreAfterPanic waits for h.mu
+const panicLockWait = 250 * time.Millisecond
+
+// restoreAfterPanic restores the terminal after a panic. Another goroutine
+// holding h.mu (mid-Stop, say) is waited for; if the lock doesn't come -
+// the panic may have left it held - only raw mode is undone, from a copy of
+// the original state, and no other handler state is touched.
+func (h *Handler) restoreAfterPanic() {
+	deadline := time.Now().Add(panicLockWait)
+	for !h.mu.TryLock() {
+		if time.Now().After(deadline) {
+			if state := h.panicRestore.Swap(nil); state != nil {
+				term.Restore(h.terminalFd, state)
+			}
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	defer h.mu.Unlock()
+	h.restoreTerminalLocked()
+}
+
+// waitAcquired blocks while the terminal is released. Returns false if the
+// handler was stopped in the meantime.
+func (h *Handler) waitAcquired() bool {
+	h.mu.Lock()
+	acquired := h.acquiredChan
+	h.mu.Unlock()
+	if acquired == nil {
+		return true
+	}
+	select {
+	case <-acquired:
+		return true
+	case <-h.stopChan:
+		return false
+	}
+}
+
+// enterTerminalLocked puts the terminal in raw mode (if we manage it) and
+// writes EnterModes - call only while holding h.mu
+func (h *Handler) enterTerminalLocked() error {
+	if h.terminalActive {
+		return nil
+	}
+	if h.managesTerminal && h.originalTermState == nil {
+		state, err := term.MakeRaw(h.terminalFd)
+		if err != nil {
+			return fmt.Errorf("failed to enable raw mode: %w", err)
+		}
+		h.originalTermState = state
+		h.panicRestore.Store(state)
+		h.logAt(slog.LevelInfo, "Terminal set to raw mode", "fd", h.terminalFd)
+	}
+	if h.managesTerminal && !h.sizeExplicit {
+		if cols, rows, err := term.GetSize(h.terminalFd); err == nil {
+			h.termCols, h.termRows = cols, rows
+		}
+	}
+	if h.modeWriter != nil && h.enterModes != "" {
+		h.modeWriter.Write([]byte(h.enterModes))
+	}
+	if h.modeWriter != nil && h.mouseEnterModes != "" && !h.mouseOff {
+		h.modeWriter.Write([]byte(h.mouseEnterModes))
+	}
+	h.terminalActive = true
+	return nil
+}
+
+// restoreTerminalLocked writes ExitModes and restores the terminal to the
+// state it was in before enterTerminalLocked - call only while holding h.mu
+func (h *Handler) restoreTerminalLocked() error {
+	if !h.terminalActive {
+		return nil
+	}
+	h.terminalActive = false
+	if h.modeWriter != nil && h.mouseExitModes != "" && !h.mouseOff {
+		h.modeWriter.Write([]byte(h.mouseExitModes))
+	}
+	if h.modeWriter != nil && h.exitModes != "" {
+		h.modeWriter.Write([]byte(h.exitModes))
+	}
 	if h.managesTerminal && h.originalTermState != nil {
 		if err := term.Restore(h.terminalFd, h.originalTermState); err != nil {
 			return fmt.Errorf("failed to restore terminal: %w", err)
 		}
 		h.originalTermState = nil
-		h.debug("Terminal restored to original mode")
+		h.panicRestore.Store(nil)
+		h.logAt(slog.LevelInfo, "Terminal restored to original mode", "fd", h.terminalFd)
 	}
-
-	h.debug("Handler stopped")
 	return nil
 }
 
@@ -263,20 +1282,86 @@ func (h *Handler) Stop() error {
 // When enabled, keys go to line assembly and completed lines are sent to Lines channel.
 // When disabled, all keys go directly to Keys channel.
 func (h *Handler) SetLineMode(enabled bool) {
+	if enabled && h.simple {
+		h.debug("Line mode unavailable in Simple mode")
+		return
+	}
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	h.inLineReadMode = enabled
+	h.inLineReadMode.Store(enabled)
+	h.lineTimeoutGen++
+	h.menu = nil
 	if enabled {
-		h.currentLine = nil
-		h.charByteLengths = nil
+		h.clearLineLocked()
+		h.echoLocked(h.prompt)
+		h.armLineTimeoutLocked()
+		if len(h.pasteRest) > 0 {
+			h.after(0, h.replayPaste)
+		}
 	}
 }
 
-// IsLineMode returns true if line assembly mode is active.
-func (h *Handler) IsLineMode() bool {
+// PauseMode selects what happens to input that arrives while paused.
+type PauseMode int
+
+const (
+	// PauseBuffer leaves input queued; it is processed after Resume.
+	PauseBuffer PauseMode = iota
+	// PauseDiscard reads and drops input while paused.
+	PauseDiscard
+)
+
+// Pause stops emitting key, line, and paste events without leaving raw mode.
+// Useful while showing modal output during which keystrokes must be ignored.
+// With PauseBuffer, input typed while paused is delivered after Resume; with
+// PauseDiscard it is thrown away.
+func (h *Handler) Pause(mode PauseMode) {
+	h.mu.Lock()
+	h.paused = true
+	h.pauseMode = mode
+	h.mu.Unlock()
+	h.wake()
+}
+
+// Resume restarts event delivery after Pause.
+func (h *Handler) Resume() {
+	h.mu.Lock()
+	h.paused = false
+	h.mu.Unlock()
+	h.wake()
+}
+
+// IsPaused returns true if event delivery is paused.
+func (h *Handler) IsPaused() bool {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	return h.inLineReadMode
+	return h.paused
+}
+
+// after runs fn on the processing goroutine once d has elapsed, so timed
+// events (coalescing windows, long presses, ...) need no locking against the
+// parser. Stop the returned timer to cancel; fn must still tolerate running
+// after a Stop that lost the race.
+func (h *Handler) after(d time.Duration, fn func()) *time.Timer {
+	return time.AfterFunc(d, func() {
+		select {
+		case h.tasks <- fn:
+		case <-h.stopChan:
+		}
+	})
+}
+
+// wake signals processLoop to re-evaluate its input source
+func (h *Handler) wake() {
+	select {
+	case h.wakeChan <- struct{}{}:
+	default:
+	}
+}
+
+// IsLineMode returns true if line assembly mode is active.
+func (h *Handler) IsLineMode() bool {
+	return h.inLineReadMode.Load()
 }
 
 // SetEchoWriter sets the writer for echoing typed characters.
@@ -301,16 +1386,12 @@ func (h *Handler) ManagesTerminal() bool {
 // SetDecodeMacOSOption enables or disables decoding of macOS Option+key
 // Unicode characters to M-key notation (e.g., ∂ → M-d).
 func (h *Handler) SetDecodeMacOSOption(enabled bool) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	h.decodeMacOSOption = enabled
+	h.decodeMacOSOption.Store(enabled)
 }
 
 // DecodeMacOSOption returns true if macOS Option character decoding is enabled.
 func (h *Handler) DecodeMacOSOption() bool {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	return h.decodeMacOSOption
+	return h.decodeMacOSOption.Load()
 }
 
 // Escape sequence bindings - maps escape sequences to key names
@@ -364,6 +1445,82 @@ var escBindings = map[string]string{
 	"\x1bOB": "Down",
 	"\x1bOC": "Right",
 	"\x1bOD": "Left",
+
+	// Keypad in application mode (DECKPAM), and keypad 5 with Num Lock off
+	"\x1bOp": "KP-0",
+	"\x1bOq": "KP-1",
+	"\x1bOr": "KP-2",
+	"\x1bOs": "KP-3",
+	"\x1bOt": "KP-4",
+	"\x1bOu": "KP-5",
+	"\x1bOv": "KP-6",
+	"\x1bOw": "KP-7",
+	"\x1bOx": "KP-8",
+	"\x1bOy": "KP-9",
+	"\x1bOn": "KP-Decimal",
+	"\x1bOo": "KP-Divide",
+	"\x1bOj": "KP-Multiply",
+	"\x1bOm": "KP-Subtract",
+	"\x1bOk": "KP-Add",
+	"\x1bOl": "KP-Separator",
+	"\x1bOM": "KP-Enter",
+	"\x1bOX": "KP-Equal",
+	"\x1bOE": "KP-Begin",
+	"\x1b[E": "KP-Begin",
+}
+
+// BindSequence maps an escape sequence to a key name for this handler,
+// overriding the built-in table. Use it for terminal-specific sequences,
+// e.g. ones learned with ConfigureKeysFromTerminal. seq must start with ESC;
+// an empty key removes the binding.
+func (h *Handler) BindSequence(seq, key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	defer h.publishBindingsLocked()
+	if key == "" {
+		delete(h.bindings, seq)
+		return
+	}
+	if h.bindings == nil {
+		h.bindings = make(map[string]string)
+	}
+	h.bindings[seq] = key
+}
+
+// bindingTable is a snapshot of a handler's bindings with their prefix
+// index. It is never modified once published.
+type bindingTable struct {
+	keys map[string]string
+	trie *seqTrie
+}
+
+// publishBindingsLocked publishes a copy of h.bindings after a change, so
+// the parser sees it without taking h.mu - call only while holding h.mu
+func (h *Handler) publishBindingsLocked() {
+	keys := make(map[string]string, len(h.bindings))
+	for seq, key := range h.bindings {
+		keys[seq] = key
+	}
+	h.bound.Store(&bindingTable{keys: keys, trie: newSeqTrie(keys)})
+}
+
+// lookupBinding finds the key for a complete escape sequence, checking this
+// handler's bindings before the built-in table
+func (h *Handler) lookupBinding(seq string) (string, bool) {
+	if t := h.bound.Load(); t != nil {
+		if key, ok := t.keys[seq]; ok {
+			return key, true
+		}
+	}
+	key, ok := escBindings[seq]
+	return key, ok
+}
+
+// isBindingPrefix reports whether seq is a proper prefix of one of this
+// handler's bindings
+func (h *Handler) isBindingPrefix(seq string) bool {
+	t := h.bound.Load()
+	return t != nil && t.trie.isProperPrefix(seq)
 }
 
 // Control key names
@@ -488,319 +1645,255 @@ var macOSOptionChars = map[rune]string{
 	'`': "M-`",  // Option+backtick (same as backtick on some layouts)
 }
 
+// readDeadliner is an input with read deadlines, such as a net.Conn
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// interruptsRead reports whether Stop should cut short a Read on the input
+// with a deadline
+func (h *Handler) interruptsRead() bool {
+	_, conn := h.inputReader.(net.Conn)
+	return conn || h.readTimeout > 0 || h.reconnect != nil
+}
+
+// isTimeout reports whether err is a read deadline expiring
+func isTimeout(err error) bool {
+	var t interface{ Timeout() bool }
+	return errors.As(err, &t) && t.Timeout()
+}
+
+// sameStream reports whether w and r are the same object, e.g. one
+// net.Conn used as both InputReader and ModeWriter
+func sameStream(w io.Writer, r io.Reader) bool {
+	if w == nil || r == nil {
+		return false
+	}
+	tw, tr := reflect.TypeOf(w), reflect.TypeOf(r)
+	return tw == tr && tw.Comparable() && any(w) == any(r)
+}
+
+// readFailed handles a failed read: it reports the disconnect and, if a
+// Reconnect hook is set, switches to the reader it returns. Returns false
+// if reading should end.
+func (h *Handler) readFailed(err error) bool {
+	select {
+	case <-h.stopChan:
+		return false
+	default:
+	}
+	h.logAt(slog.LevelError, "Read error", "err", err)
+	if h.OnDisconnect != nil {
+		h.OnDisconnect(err)
+	}
+	if h.reconnect == nil {
+		return false
+	}
+	r, err := h.reconnect()
+	if err != nil {
+		h.logAt(slog.LevelError, "Reconnect failed", "err", err)
+		return false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.inputReader = r
+	if w, ok := r.(io.Writer); ok && h.modeWriterIsInput {
+		h.modeWriter = w
+		if h.terminalActive {
+			// Push the modes on the new connection
+			h.terminalActive = false
+			h.enterTerminalLocked()
+		}
+	}
+	h.logAt(slog.LevelInfo, "Reconnected")
+	return true
+}
+
 // readLoop continuously reads raw bytes from input
 func (h *Handler) readLoop() {
-	buf := make([]byte, 256)
+	defer h.RestoreOnPanic()
+	defer close(h.readDone)
+	buf := make([]byte, h.readBufferSize)
+	// Clear the deadline a previous Stop used to interrupt a Read
+	if d, ok := h.inputReader.(readDeadliner); ok && h.readTimeout == 0 {
+		d.SetReadDeadline(time.Time{})
+	}
 	for {
 		select {
 		case <-h.stopChan:
 			return
 		default:
-			n, err := h.inputReader.Read(buf)
-			if err != nil {
-				h.debug(fmt.Sprintf("Read error: %v", err))
+			// Don't start a read while the terminal is released: a child
+			// program owns the input then, and a pending Read would steal
+			// its first keystrokes.
+			if !h.waitReadable() {
 				return
 			}
+			h.mu.Lock()
+			r := h.inputReader
+			h.mu.Unlock()
+			if d, ok := r.(readDeadliner); ok && h.readTimeout > 0 {
+				d.SetReadDeadline(time.Now().Add(h.readTimeout))
+			}
+			n, err := r.Read(buf)
 			if n > 0 {
-				// Make a copy to send
-				data := make([]byte, n)
-				copy(data, buf[:n])
-				select {
-				case h.rawBytes <- data:
-				case <-h.stopChan:
+				h.stats.bytesRead.Add(uint64(n))
+				if h.teeWriter != nil {
+					h.tee(buf[:n])
+				}
+				if !h.rawBytes.write(buf[:n], h.stopChan) {
 					return
 				}
 			}
-		}
-	}
-}
-
-// processLoop processes raw bytes into key events
-func (h *Handler) processLoop() {
-	escTimeout := time.NewTimer(0)
-	if !escTimeout.Stop() {
-		<-escTimeout.C
-	}
-
-	for {
-		select {
-		case <-h.stopChan:
-			return
-
-		case data := <-h.rawBytes:
-			for _, b := range data {
-				h.processByte(b, escTimeout)
-			}
-
-		case <-escTimeout.C:
-			// Escape sequence timeout - try Alt sequence parsing before giving up
-			if h.inEscape && len(h.escBuffer) > 0 {
-				seq := string(h.escBuffer)
-				// Try Alt+key parsing (ESC followed by character)
-				if key, ok := h.parseAltSequence(seq); ok {
-					h.emitKey(key)
-					h.escBuffer = nil
-					h.inEscape = false
-				} else {
-					h.emitEscapeBuffer()
+			if err != nil {
+				if isTimeout(err) && h.readTimeout > 0 {
+					continue
 				}
-			}
-		}
-	}
-}
-
-// Bracketed paste sequences
-const (
-	bracketedPasteStart = "\x1b[200~"
-	bracketedPasteEnd   = "\x1b[201~"
-)
-
-// osc52Start introduces an OSC 52 clipboard response: ESC ] 52 ; . The body
-// (<selection>;<base64>) follows and is terminated by BEL (0x07) or ST (ESC \).
-const osc52Start = "\x1b]52;"
-
-// pasteEndBufferSize is the number of bytes to keep buffered during paste
-// to avoid splitting the end sequence (\x1b[201~ is 6 bytes, we buffer 7 to be safe)
-const pasteEndBufferSize = 7
-
-// DefaultPasteChunkSize is the default size for paste chunks (1KB)
-const DefaultPasteChunkSize = 1024
-
-// processByte handles a single byte of input
-func (h *Handler) processByte(b byte, escTimeout *time.Timer) {
-	// Handle an in-progress OSC 52 clipboard response: accumulate the body
-	// until a BEL (0x07) or ST (ESC \) terminator, then decode and emit it.
-	// base64 never contains ESC, so an ESC always ends the body.
-	if h.inClipboard {
-		if h.clipboardEsc {
-			h.clipboardEsc = false
-			h.finishClipboard() // ESC (\ for ST, or stray) ends the body
-			return
-		}
-		switch b {
-		case 0x07: // BEL terminator
-			h.finishClipboard()
-		case 0x1b: // ESC - possible ST terminator start
-			h.clipboardEsc = true
-		default:
-			h.clipboardBuffer = append(h.clipboardBuffer, b)
-		}
-		return
-	}
-
-	// Handle bracketed paste mode
-	if h.inPaste {
-		h.pasteBuffer = append(h.pasteBuffer, b)
-		h.fullPasteContent = append(h.fullPasteContent, b)
-
-		// Check if paste buffer ends with the end sequence
-		if len(h.pasteBuffer) >= len(bracketedPasteEnd) {
-			tail := string(h.pasteBuffer[len(h.pasteBuffer)-len(bracketedPasteEnd):])
-			if tail == bracketedPasteEnd {
-				// End of paste - extract remaining content (without the end sequence)
-				remainingContent := h.pasteBuffer[:len(h.pasteBuffer)-len(bracketedPasteEnd)]
-				// Full content is everything accumulated minus the end sequence
-				fullContent := h.fullPasteContent[:len(h.fullPasteContent)-len(bracketedPasteEnd)]
-				h.inPaste = false
-				h.pasteBuffer = nil
-				h.fullPasteContent = nil
-				h.debug(fmt.Sprintf("Paste end, %d bytes", len(fullContent)))
-				// Emit final chunk if callback is set (only the remaining buffered content)
-				if h.OnPasteChunk != nil {
-					h.OnPasteChunk(PasteChunk{Content: remainingContent, IsFinal: true})
+				if !h.readFailed(err) {
+					// No more input: end a streamed paste once what was
+					// read before the failure is parsed
+					h.after(0, h.inputEnded)
+					return
 				}
-				// emitPaste receives full content for OnPaste callback and key emission
-				h.emitPaste(fullContent)
-				return
-			}
-		}
-
-		// Emit incremental chunks when we have enough data
-		// We emit when buffer >= chunkSize + pasteEndBufferSize (to keep 7 bytes for end detection)
-		if h.OnPasteChunk != nil && len(h.pasteBuffer) >= h.pasteChunkSize+pasteEndBufferSize {
-			// Emit a full chunk, keeping pasteEndBufferSize bytes buffered
-			chunk := make([]byte, h.pasteChunkSize)
-			copy(chunk, h.pasteBuffer[:h.pasteChunkSize])
-			h.pasteBuffer = h.pasteBuffer[h.pasteChunkSize:]
-			h.OnPasteChunk(PasteChunk{Content: chunk, IsFinal: false})
-		}
-		return
-	}
-
-	if h.inEscape {
-		h.escBuffer = append(h.escBuffer, b)
-
-		// Check if we have a complete escape sequence
-		seq := string(h.escBuffer)
-
-		// Check for bracketed paste start
-		if seq == bracketedPasteStart {
-			h.debug("Bracketed paste start detected")
-			h.inEscape = false
-			h.escBuffer = nil
-			h.inPaste = true
-			h.pasteBuffer = nil
-			h.fullPasteContent = nil
-			escTimeout.Stop()
-			return
-		}
-
-		// Check for an OSC 52 clipboard-response start (ESC ] 52 ;). The body
-		// runs until BEL/ST and is gathered by the h.inClipboard branch above.
-		if seq == osc52Start {
-			h.debug("OSC 52 clipboard response start detected")
-			h.inEscape = false
-			h.escBuffer = nil
-			h.inClipboard = true
-			h.clipboardBuffer = nil
-			h.clipboardEsc = false
-			escTimeout.Stop()
-			return
-		}
-
-		if key, ok := escBindings[seq]; ok {
-			h.emitKey(key)
-			h.escBuffer = nil
-			h.inEscape = false
-			escTimeout.Stop()
-			return
-		}
-
-		// Check if this could be a prefix of a valid sequence
-		if h.couldBeEscapePrefix(seq) {
-			// Reset timeout - wait for more bytes
-			escTimeout.Reset(50 * time.Millisecond)
-			return
-		}
-
-		// Try dynamic parsing for CSI sequences with modifiers
-		if key, ok := h.parseModifiedCSI(seq); ok {
-			// Mouse events return "" but emit keys internally
-			if key != "" {
-				h.emitKey(key)
-			}
-			h.escBuffer = nil
-			h.inEscape = false
-			escTimeout.Stop()
-			return
-		}
-
-		// Try Alt+key parsing (ESC followed by character)
-		if key, ok := h.parseAltSequence(seq); ok {
-			h.emitKey(key)
-			h.escBuffer = nil
-			h.inEscape = false
-			escTimeout.Stop()
-			return
+			}
 		}
-
-		// Not a valid sequence - emit as individual keys
-		h.emitEscapeBuffer()
-		return
 	}
+}
 
-	// Check for escape start
-	if b == 0x1b {
-		h.inEscape = true
-		h.escBuffer = []byte{b}
-		escTimeout.Reset(50 * time.Millisecond)
-		return
+// processLoop processes raw bytes into key events
+func (h *Handler) processLoop() {
+	defer h.RestoreOnPanic()
+	escTimeout := time.NewTimer(0)
+	if !escTimeout.Stop() {
+		<-escTimeout.C
 	}
 
-	// Handle control characters
-	if b < 32 || b == 127 {
-		if key, ok := controlKeys[b]; ok {
-			h.emitKey(key)
-		} else {
-			h.emitKey(fmt.Sprintf("^%c", b+64))
+	for {
+		// While paused in buffer mode, leave input in the queue (and, once
+		// that fills, in the reader) so it is processed after Resume.
+		h.mu.Lock()
+		input := h.rawBytes.ready
+		if h.paused && h.pauseMode == PauseBuffer {
+			input = nil
 		}
-		return
-	}
+		h.mu.Unlock()
 
-	// Regular printable character or start of UTF-8 sequence
-	if b < 128 {
-		h.emitKey(string(b))
-		return
-	}
+		select {
+		case <-h.stopChan:
+			h.endPasteStream()
+			return
+
+		case <-h.wakeChan:
+			// Pause state changed - loop to pick the new input source
 
-	// UTF-8 multi-byte character handling
-	// Check if we're continuing an existing UTF-8 sequence
-	if h.utf8Remaining > 0 {
-		// Continuation byte should be 10xxxxxx (0x80-0xBF)
-		if b >= 0x80 && b <= 0xBF {
-			h.utf8Buffer = append(h.utf8Buffer, b)
-			h.utf8Remaining--
-			if h.utf8Remaining == 0 {
-				// Complete UTF-8 sequence - emit the character
-				h.emitKey(string(h.utf8Buffer))
-				h.utf8Buffer = nil
+		case task := <-h.tasks:
+			// Timed work (see after) runs here, serialized with parsing
+			task()
+
+		case <-input:
+			h.mu.Lock()
+			discard, verbatim := h.paused, h.verbatim
+			h.mu.Unlock()
+			// The queue is parsed in place; it wraps into at most two pieces
+			first, second := h.rawBytes.pending()
+			for _, data := range [][]byte{first, second} {
+				if len(data) == 0 {
+					continue
+				}
+				if discard {
+					h.debug("Paused, input discarded", "bytes", len(data))
+				} else if verbatim != nil {
+					h.debug("Verbatim input", "bytes", len(data))
+					h.sendVerbatim(verbatim, data)
+				} else {
+					if h.secret.Load() && h.inLineReadMode.Load() {
+						h.debug("Raw input", "bytes", len(data))
+					} else {
+						h.debug("Raw input", "bytes", data)
+					}
+					if h.OnStageTiming != nil {
+						start := time.Now()
+						h.feed(data, escTimeout)
+						h.timeStage(StageParse, len(data), start)
+					} else {
+						h.feed(data, escTimeout)
+					}
+				}
 			}
-		} else {
-			// Invalid continuation - emit buffer as-is and reset
-			for _, bb := range h.utf8Buffer {
-				h.emitKey(string(rune(bb)))
+			h.rawBytes.release(len(first) + len(second))
+
+		case <-escTimeout.C:
+			// Escape sequence timeout - try Alt sequence parsing before giving up
+			if h.state == stateEscape && len(h.escBuffer) > 0 {
+				h.stats.escapeTimeouts.Add(1)
+				h.debug("Escape timeout", "seq", string(h.escBuffer))
+				h.resolveEscape()
+				h.flushRawEvent()
+			} else if h.state == stateString {
+				h.abandonString(escTimeout)
+				h.flushRawEvent()
 			}
-			h.utf8Buffer = nil
-			h.utf8Remaining = 0
-			// Process this byte as a new sequence
-			h.processByte(b, escTimeout)
 		}
-		return
-	}
-
-	// Start of new UTF-8 sequence - determine length from lead byte
-	if b >= 0xC0 && b <= 0xDF {
-		// 2-byte sequence: 110xxxxx
-		h.utf8Buffer = []byte{b}
-		h.utf8Remaining = 1
-	} else if b >= 0xE0 && b <= 0xEF {
-		// 3-byte sequence: 1110xxxx
-		h.utf8Buffer = []byte{b}
-		h.utf8Remaining = 2
-	} else if b >= 0xF0 && b <= 0xF7 {
-		// 4-byte sequence: 11110xxx
-		h.utf8Buffer = []byte{b}
-		h.utf8Remaining = 3
-	} else {
-		// Invalid UTF-8 lead byte or bare continuation byte - emit as-is
-		h.emitKey(string(rune(b)))
 	}
 }
 
+// Bracketed paste sequences
+const (
+	bracketedPasteStart = "\x1b[200~"
+	bracketedPasteEnd   = "\x1b[201~"
+)
+
+// oscIntro starts an OSC string: ESC ] Ps ; Pt, terminated by BEL (0x07) or
+// ST (ESC \). Ps is numeric; 52 is a clipboard response.
+const oscIntro = "\x1b]"
+
+// pasteEndBufferSize is the number of bytes to keep buffered during paste
+// to avoid splitting the end sequence (\x1b[201~ is 6 bytes, we buffer 7 to be safe)
+const pasteEndBufferSize = 7
+
+// DefaultPasteChunkSize is the default size for paste chunks (1KB)
+const DefaultPasteChunkSize = 1024
+
+// DefaultReadBufferSize is the default size of readLoop's read buffer (4KB)
+const DefaultReadBufferSize = 4096
+
 // couldBeEscapePrefix checks if seq could be a prefix of a valid escape sequence
 func (h *Handler) couldBeEscapePrefix(seq string) bool {
-	// A partial OSC 52 clipboard-response introducer (ESC ] 5 2 ;): keep
-	// buffering until the full osc52Start is seen (then processByte switches to
-	// clipboard mode). Without this, ESC ] would fall through and be emitted as
-	// stray keys.
-	if len(seq) < len(osc52Start) && osc52Start[:len(seq)] == seq {
+	// A partial OSC introducer (ESC ] digits): keep buffering until the ';'
+	// after Ps is seen (then processByte switches to the OSC state). A lone
+	// ESC ] that times out is still M-]. Without this, ESC ] would fall
+	// through and be emitted as stray keys.
+	if strings.HasPrefix(seq, oscIntro) && isDigits(seq[len(oscIntro):]) {
 		return true
 	}
 
-	for key := range escBindings {
-		if len(seq) < len(key) && key[:len(seq)] == seq {
-			return true
-		}
+	// ESC P / _ / ^ / X introduce DCS, APC, PM, and SOS strings (terminal
+	// replies such as XTGETTCAP); wait for the byte that follows.
+	if len(seq) == 2 && seq[0] == 0x1b && isControlStringIntro(seq[1]) {
+		return true
+	}
+
+	if escTrie.isProperPrefix(seq) {
+		return true
+	}
+	if h.isBindingPrefix(seq) {
+		return true
+	}
+
+	// ESC and the start of a UTF-8 character (Alt+é): wait for the rest
+	if len(seq) >= 2 && seq[0] == 0x1b && seq[1] >= 0xC0 && !utf8.FullRuneInString(seq[1:]) {
+		return true
 	}
 
-	// macOS Option+key sends ESC ESC [ X - wait for the full sequence
+	// ESC ESC: Alt (an ESC prefix) on a sequence, such as macOS Option+key
+	// (ESC ESC [ X) or Alt+Ctrl+arrow (ESC ESC [ 1 ; 5 A) - wait for the
+	// sequence after the first ESC
 	if len(seq) >= 2 && seq[0] == 0x1b && seq[1] == 0x1b {
-		// ESC ESC - could be start of macOS Option+arrow
 		if len(seq) == 2 {
 			return true // Wait for more
 		}
-		// ESC ESC [ - wait for arrow key (need 4 chars total: ESC ESC [ X)
-		if seq[2] == '[' {
-			if len(seq) < 4 {
-				return true // Still waiting for the arrow key letter
-			}
-			// Length >= 4, check if terminated with A/B/C/D/H/F
-			last := seq[len(seq)-1]
-			if last >= 0x40 && last <= 0x7e {
-				return false // Terminated
-			}
-			return true // Still in progress (shouldn't happen for this pattern)
-		}
+		return h.couldBeEscapePrefix(seq[1:])
 	}
 
 	// Also allow CSI sequences in progress: ESC [ ...
@@ -838,6 +1931,25 @@ func (h *Handler) couldBeEscapePrefix(seq string) bool {
 	return false
 }
 
+// unknownSequence handles an escape buffer that could not be parsed: it is
+// offered to OnUnknownSequence and, unless that claims it, exploded into
+// individual keys by emitEscapeBuffer
+func (h *Handler) unknownSequence() {
+	h.stats.unknownSequences.Add(1)
+	h.rawUnknown = true
+	h.logAt(slog.LevelWarn, "Unknown escape sequence", "seq", h.escBuffer)
+	if h.OnUnknownSequence != nil {
+		seq := make([]byte, len(h.escBuffer))
+		copy(seq, h.escBuffer)
+		if h.OnUnknownSequence(seq) {
+			h.escBuffer = nil
+			h.state = stateGround
+			return
+		}
+	}
+	h.emitEscapeBuffer()
+}
+
 // emitEscapeBuffer emits the escape buffer as individual keys
 func (h *Handler) emitEscapeBuffer() {
 	// First byte is ESC
@@ -853,17 +1965,56 @@ func (h *Handler) emitEscapeBuffer() {
 		}
 	}
 	h.escBuffer = nil
-	h.inEscape = false
+	h.state = stateGround
 }
 
 // emitKey sends a key event to either the Keys channel or line assembly
 func (h *Handler) emitKey(key string) {
-	// Decode macOS Option characters if enabled
-	h.mu.Lock()
-	decodeMacOS := h.decodeMacOSOption
-	h.mu.Unlock()
+	if h.modifierTap > 0 {
+		defer h.trackModifierTap(key)
+	}
+	if h.OnStageTiming != nil {
+		defer h.timeStage(StageDeliver, 0, time.Now())
+	}
+
+	// A pending scroll burst or throttled motion goes out before anything
+	// that follows it
+	if h.scrollCount > 0 {
+		h.flushScroll()
+	}
+	if h.motionPending != "" {
+		h.flushMotion()
+	}
+
+	if h.normalizeKeypad {
+		key = normalizeKeypad(key)
+	}
+
+	if !h.chordFlushing && !h.repeatFlushing && h.chordKey(key) {
+		return
+	}
+	if h.repeatKeys != nil && !h.repeatFlushing && h.synthesizeRepeat(key) {
+		return
+	}
+	if h.holdAccel != nil && !h.repeatFlushing {
+		key = h.accelerate(key)
+	}
+	if h.repeatCoalesce > 0 && !h.repeatFlushing && h.coalesceRepeat(key) {
+		return
+	}
+
+	// Debouncing and rate caps; mouse keys were limited by emitMouseEvent
+	if (h.debounce > 0 || h.rateLimits != nil) && !isMouseKey(key) && h.limitKey(key, false) {
+		h.debug("Key rate limited")
+		return
+	}
 
-	if decodeMacOS && len(key) > 0 {
+	if h.RawEvents != nil {
+		h.rawKeys = append(h.rawKeys, key)
+	}
+
+	// Decode macOS Option characters if enabled
+	if h.decodeMacOSOption.Load() && len(key) > 0 {
 		r, size := utf8.DecodeRuneInString(key)
 		if size == len(key) && r != utf8.RuneError {
 			if decoded, ok := macOSOptionChars[r]; ok {
@@ -872,7 +2023,50 @@ func (h *Handler) emitKey(key string) {
 		}
 	}
 
-	h.debug(fmt.Sprintf("Key: %q", key))
+	// Let the application rewrite or swallow the key before anything else
+	if h.OnKeyFilter != nil {
+		var emit bool
+		if key, emit = h.OnKeyFilter(key); !emit {
+			h.debug("Key filtered", "key", key)
+			return
+		}
+	}
+
+	var mws []Middleware
+	if p := h.middleware.Load(); p != nil {
+		mws = *p
+	}
+
+	h.runMiddleware(mws, Event{Key: key, Time: time.Now()})
+}
+
+// deliverKey sends a key that made it through the middleware chain to the
+// OnKey callback and then either line assembly or the Keys channel
+func (h *Handler) deliverKey(key string) {
+	if h.sink != nil {
+		h.sink(Event{Kind: EventKey, Key: key})
+		return
+	}
+
+	// Secret input is kept out of logs and subscriptions
+	secret := h.secret.Load() && h.inLineReadMode.Load()
+	if !secret {
+		h.debug("Key", "key", key)
+	}
+
+	// Ctrl+Z suspends the process instead of being delivered, if requested
+	if key == "^Z" && h.handleSuspend && h.suspend() {
+		return
+	}
+
+	h.stats.keys.Add(1)
+	h.audit(Event{Kind: EventKey, Key: key}, secret)
+	if !secret {
+		h.publish(Event{Kind: EventKey, Key: key})
+		if h.typing != nil {
+			h.typing.record(key, time.Now())
+		}
+	}
 
 	// Call callback if set
 	if h.OnKey != nil {
@@ -880,45 +2074,96 @@ func (h *Handler) emitKey(key string) {
 	}
 
 	// Check if we're in line read mode
-	h.mu.Lock()
-	inLineMode := h.inLineReadMode
-	h.mu.Unlock()
-
-	if inLineMode {
+	if h.inLineReadMode.Load() {
 		// In line read mode: keys go to line assembly
 		h.handleLineAssembly(key)
 	} else {
 		// Normal mode: keys go to Keys channel
+		h.sendKey(key)
+	}
+}
+
+// sendWithPolicy delivers v on ch, applying policy if ch is full. Anything
+// that could not be delivered (v, or an older item evicted for it) is passed
+// to dropped.
+func sendWithPolicy[T any](ch chan T, v T, policy OverflowPolicy, stop <-chan struct{}, dropped func(T)) {
+	select {
+	case ch <- v:
+		return
+	default:
+	}
+
+	switch policy {
+	case OverflowBlock:
+		select {
+		case ch <- v:
+			return
+		case <-stop:
+		}
+	case OverflowDropOldest:
+		// Buffer full - drop oldest item to make room
 		select {
-		case h.Keys <- key:
-			// Sent successfully
+		case old := <-ch:
+			dropped(old)
 		default:
-			// Buffer full - drop oldest key to make room
-			select {
-			case <-h.Keys:
-			default:
-			}
-			// Try again
-			select {
-			case h.Keys <- key:
-			default:
-				// Still can't send, just drop this key
-			}
 		}
+		// Try again
+		select {
+		case ch <- v:
+			return
+		default:
+			// Still can't send, just drop this item
+		}
+	}
+	dropped(v)
+}
+
+// sendKey delivers a key on the Keys channel, and as a KeyEvent on Events,
+// according to the key overflow policy
+func (h *Handler) sendKey(key string) {
+	if h.Events != nil {
+		h.sendKeyEvent(key)
+	}
+	if h.legacyKeys {
+		sendWithPolicy(h.Keys, key, h.keyOverflow, h.stopChan, h.keyDropped)
+	}
+}
+
+// keyDropped reports a key lost to Keys channel overflow
+func (h *Handler) keyDropped(key string) {
+	h.stats.keysDropped.Add(1)
+	h.logAt(slog.LevelWarn, "Keys channel full, key dropped", "key", key)
+	if h.OnKeyDropped != nil {
+		h.OnKeyDropped(key)
+	}
+}
+
+// sendLine delivers a line on the Lines channel according to the line
+// overflow policy
+func (h *Handler) sendLine(line []byte) {
+	h.stats.lines.Add(1)
+	h.audit(Event{Kind: EventLine, Line: line}, h.secret.Load())
+	if !h.secret.Load() {
+		h.publish(Event{Kind: EventLine, Line: line})
+	}
+	sendWithPolicy(h.Lines, line, h.lineOverflow, h.stopChan, h.lineDropped)
+}
+
+// lineDropped reports a line lost to Lines channel overflow
+func (h *Handler) lineDropped(line []byte) {
+	h.stats.linesDropped.Add(1)
+	h.logAt(slog.LevelWarn, "Lines channel full, line dropped", "bytes", len(line))
+	if h.OnLineDropped != nil {
+		h.OnLineDropped(line)
 	}
 }
 
-// finishClipboard ends an OSC 52 clipboard response: the accumulated body is
+// finishClipboard handles an OSC 52 clipboard response: body is
 // "<selection>;<base64>", so it splits off the selection, base64-decodes the
 // payload, and delivers it on OnClipboard. A malformed body is dropped. Unlike
 // a paste, the content is NOT emitted as keys - it is a fetch reply the caller
 // consumes.
-func (h *Handler) finishClipboard() {
-	body := h.clipboardBuffer
-	h.inClipboard = false
-	h.clipboardBuffer = nil
-	h.clipboardEsc = false
-
+func (h *Handler) finishClipboard(body []byte) {
 	var sel byte
 	payload := body
 	if i := strings.IndexByte(string(body), ';'); i >= 0 {
@@ -929,27 +2174,77 @@ func (h *Handler) finishClipboard() {
 	}
 	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(payload)))
 	if err != nil {
-		h.debug(fmt.Sprintf("OSC 52 malformed base64, dropped (%d bytes)", len(payload)))
+		h.logAt(slog.LevelWarn, "OSC 52 malformed base64, dropped", "bytes", len(payload))
 		return
 	}
-	h.debug(fmt.Sprintf("OSC 52 clipboard response, %d bytes", len(data)))
+	h.debug("OSC 52 clipboard response", "selection", string(sel), "bytes", len(data))
 	if h.OnClipboard != nil {
 		h.OnClipboard(sel, data)
 	}
 }
 
+// sendPaste delivers a paste on the Pastes channel according to the line
+// overflow policy
+func (h *Handler) sendPaste(ev PasteEvent) {
+	sendWithPolicy(h.Pastes, ev, h.lineOverflow, h.stopChan, func(dropped PasteEvent) {
+		h.logAt(slog.LevelWarn, "Pastes channel full, paste dropped")
+		if dropped.Chunks != nil && dropped.Chunks == h.pasteStream {
+			// Nobody will read this stream; stop feeding it
+			h.pasteStream = nil
+		}
+	})
+}
+
+// streamChunk sends a chunk of the paste in progress to its stream,
+// waiting for the consumer if the stream is full
+func (h *Handler) streamChunk(chunk []byte) {
+	select {
+	case h.pasteStream <- chunk:
+	case <-h.stopChan:
+	}
+}
+
+// endPasteStream closes the stream of a paste that will get no more input
+// (the handler stopped, or the input failed), so a consumer ranging over
+// its Chunks is not left waiting
+func (h *Handler) endPasteStream() {
+	if h.pasteStream != nil {
+		close(h.pasteStream)
+		h.pasteStream = nil
+	}
+}
+
+// inputEnded runs on the processing goroutine after the input fails for
+// good. It waits for the input already read to be parsed, then ends a
+// streamed paste left open.
+func (h *Handler) inputEnded() {
+	if first, second := h.rawBytes.pending(); len(first)+len(second) > 0 {
+		h.after(10*time.Millisecond, h.inputEnded)
+		return
+	}
+	h.endPasteStream()
+}
+
 // emitPaste handles bracketed paste content
 func (h *Handler) emitPaste(content []byte) {
+	if h.sink != nil {
+		h.sink(Event{Kind: EventPaste, Paste: content})
+		return
+	}
 	// Call callback if set
 	if h.OnPaste != nil {
 		h.OnPaste(content)
 	}
+	secret := h.secret.Load() && h.inLineReadMode.Load()
+	h.audit(Event{Kind: EventPaste, Paste: content}, secret)
+	if !secret {
+		h.publish(Event{Kind: EventPaste, Paste: content})
+	}
+	if h.Pastes != nil && !h.streamPastes {
+		h.sendPaste(PasteEvent{Content: content})
+	}
 
-	h.mu.Lock()
-	inLineMode := h.inLineReadMode
-	h.mu.Unlock()
-
-	if inLineMode {
+	if h.inLineReadMode.Load() {
 		// In line read mode: add pasted content directly to line buffer
 		h.handlePasteLineAssembly(content)
 	} else if h.emitPasteKeys {
@@ -963,10 +2258,10 @@ func (h *Handler) emitPaste(content []byte) {
 				continue
 			}
 			// Handle special characters
-			if r == '\r' {
-				h.emitKey("Enter")
-			} else if r == '\n' {
-				h.emitKey("^J")
+			if r == '\r' || r == '\n' {
+				key, n := h.pasteNewlineKey(content)
+				h.emitKey(key)
+				size = n
 			} else if r == '\t' {
 				h.emitKey("Tab")
 			} else if r == 0x7f {
@@ -988,9 +2283,13 @@ func (h *Handler) handlePasteLineAssembly(content []byte) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if !h.inLineReadMode {
+	if !h.inLineReadMode.Load() {
 		return
 	}
+	h.armLineTimeoutLocked()
+	if h.menu != nil {
+		h.closeMenuLocked()
+	}
 
 	// Process pasted content byte by byte, handling special characters
 	for len(content) > 0 {
@@ -1001,45 +2300,31 @@ func (h *Handler) handlePasteLineAssembly(content []byte) {
 		}
 
 		if r == '\r' || r == '\n' {
-			// Newline in paste - submit the current line
-			lineBytes := make([]byte, len(h.currentLine))
-			copy(lineBytes, h.currentLine)
-			h.currentLine = nil
-			h.charByteLengths = nil
-			echoWriter := h.echoWriter
-			h.mu.Unlock()
-
-			// Send line
-			select {
-			case h.Lines <- lineBytes:
-			default:
-				select {
-				case <-h.Lines:
-				default:
-				}
-				h.Lines <- lineBytes
+			// CRLF is one newline
+			if r == '\r' && len(content) > 1 && content[1] == '\n' {
+				size = 2
 			}
-
-			// Call callback
-			if h.OnLine != nil {
-				h.OnLine(lineBytes)
+			content = content[size:]
+			if h.pasteLines == PasteLinesJoin {
+				h.insertLocked(h.pasteJoin)
+				continue
 			}
 
-			// Echo newline
-			if echoWriter != nil {
-				echoWriter.Write([]byte("\r\n"))
+			// Newline in paste - submit the current line
+			if !h.submitLineLocked(nil) {
+				return // rejected: the rest of the paste is dropped
 			}
-
-			h.mu.Lock()
-			// Skip remaining content after newline (single-line read)
-			return
+			switch h.pasteLines {
+			case PasteLinesDiscard:
+				return
+			case PasteLinesBuffer:
+				h.pasteRest = append(h.pasteRest[:0], content...)
+				return
+			}
+			continue
 		} else if r >= 32 || r == '\t' {
 			// Printable character or tab - add to line
-			charBytes := content[:size]
-			h.currentLine = append(h.currentLine, charBytes...)
-			h.charByteLengths = append(h.charByteLengths, size)
-			// Echo
-			h.echoLocked(string(r))
+			h.insertCharLocked(content[:size])
 		}
 
 		content = content[size:]
@@ -1051,71 +2336,63 @@ func (h *Handler) handleLineAssembly(key string) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if !h.inLineReadMode {
+	if !h.inLineReadMode.Load() {
+		return
+	}
+	h.armLineTimeoutLocked()
+	if h.menu != nil && h.menuKeyLocked(key) {
+		return
+	}
+	if h.lineDelimiters[key] {
+		h.submitDelimitedLocked(key)
 		return
 	}
 
 	switch key {
+	case "Tab":
+		h.completeLocked()
+
 	case "Enter":
 		// Emit the completed line as raw bytes
-		lineBytes := make([]byte, len(h.currentLine))
-		copy(lineBytes, h.currentLine)
-		h.currentLine = nil
-		h.charByteLengths = nil
-		echoWriter := h.echoWriter
-		h.mu.Unlock()
-
-		// Send to Lines channel
-		select {
-		case h.Lines <- lineBytes:
-		default:
-			select {
-			case <-h.Lines:
-			default:
-			}
-			h.Lines <- lineBytes
-		}
-
-		// Call callback
-		if h.OnLine != nil {
-			h.OnLine(lineBytes)
-		}
-
-		// Echo newline
-		if echoWriter != nil {
-			echoWriter.Write([]byte("\r\n"))
-		}
+		h.expandAbbrevLocked()
+		h.submitLineLocked(nil)
 
-		h.mu.Lock() // Re-acquire for deferred unlock
-		return
+	case " ", "Space":
+		h.expandAbbrevLocked()
+		h.insertCharLocked([]byte{' '})
 
 	case "Backspace":
-		if len(h.charByteLengths) > 0 {
-			lastCharLen := h.charByteLengths[len(h.charByteLengths)-1]
-			h.currentLine = h.currentLine[:len(h.currentLine)-lastCharLen]
-			h.charByteLengths = h.charByteLengths[:len(h.charByteLengths)-1]
-			h.echoLocked("\b \b")
-		}
+		h.backspaceLocked()
 
 	case "^U":
 		// Clear line
-		for range h.charByteLengths {
-			h.echoLocked("\b \b")
+		h.killLineLocked()
+
+	case "Left", "^B", "Right", "^F", "Home", "^A", "End", "^E", "Delete":
+		// Cursor movement within the line, if enabled
+		if !h.cursorEditing {
+			return
+		}
+		switch key {
+		case "Left", "^B":
+			h.moveCursorLocked(h.lineCursor - 1)
+		case "Right", "^F":
+			h.moveCursorLocked(h.lineCursor + 1)
+		case "Home", "^A":
+			h.moveCursorLocked(0)
+		case "End", "^E":
+			h.moveCursorLocked(len(h.charByteLengths))
+		case "Delete":
+			h.deleteLocked()
 		}
-		h.currentLine = nil
-		h.charByteLengths = nil
 
 	case "^C":
 		// Interrupt - emit empty line
-		h.echoLocked("^C\r\n")
-		h.currentLine = nil
-		h.charByteLengths = nil
+		h.echoLocked("^C\r\n" + h.prompt)
+		h.clearLineLocked()
 		h.mu.Unlock()
 
-		select {
-		case h.Lines <- []byte{}:
-		default:
-		}
+		h.sendLine([]byte{})
 
 		if h.OnLine != nil {
 			h.OnLine([]byte{})
@@ -1129,9 +2406,7 @@ func (h *Handler) handleLineAssembly(key string) {
 		if len(key) > 0 {
 			r, _ := utf8.DecodeRuneInString(key)
 			if r != utf8.RuneError && len(key) == utf8.RuneLen(r) && r >= 32 {
-				h.currentLine = append(h.currentLine, []byte(key)...)
-				h.charByteLengths = append(h.charByteLengths, len(key))
-				h.echoLocked(key)
+				h.insertCharLocked([]byte(key))
 			}
 		}
 	}
@@ -1144,12 +2419,6 @@ func (h *Handler) echoLocked(s string) {
 	}
 }
 
-func (h *Handler) debug(msg string) {
-	if h.debugFn != nil {
-		h.debugFn(msg)
-	}
-}
-
 // parseAltSequence detects M- prefix for alt combinations
 func (h *Handler) parseAltSequence(seq string) (string, bool) {
 	// ESC followed by a character = Alt+char (Meta prefix)
@@ -1266,8 +2535,9 @@ func (h *Handler) parseAltSequence(seq string) (string, bool) {
 // parseModifiedCSI dynamically parses CSI sequences with modifiers
 // Returns single key, or for mouse events returns "" and handles emission internally
 func (h *Handler) parseModifiedCSI(seq string) (string, bool) {
-	// Check for macOS Option+arrow: ESC ESC [ X
-	// Emit "Special" first to distinguish from xterm-style sequences
+	// Check for macOS Option+arrow: ESC ESC [ X. The key is flagged
+	// MacOSOption on Events; the legacy "Special" marker key before it is
+	// opt-in (Options.SpecialMarker)
 	if len(seq) == 4 && seq[0] == 0x1b && seq[1] == 0x1b && seq[2] == '[' {
 		var key string
 		switch seq[3] {
@@ -1285,8 +2555,10 @@ func (h *Handler) parseModifiedCSI(seq string) (string, bool) {
 			key = "M-End"
 		}
 		if key != "" {
-			// Emit "Special" first, then return the key
-			h.emitKey("Special")
+			if h.specialMarker {
+				h.emitKey("Special")
+			}
+			h.macOSOptionKey = true
 			return key, true
 		}
 	}
@@ -1305,13 +2577,8 @@ func (h *Handler) parseModifiedCSI(seq string) (string, bool) {
 	if len(body) >= 4 && body[0] == '<' {
 		finalByte := body[len(body)-1]
 		if finalByte == 'M' || finalByte == 'm' {
-			if posKey, actionKey, ok := parseMouseSGR(seq); ok {
-				// For drag events, posKey is empty and position is in actionKey
-				// For other events, emit position first, then action
-				if posKey != "" {
-					h.emitKey(posKey)
-				}
-				h.emitKey(actionKey)
+			if cb, cx, cy, isRelease, ok := parseMouseSGR(seq); ok {
+				h.emitMouse(cb, cx, cy, isRelease)
 				return "", true // Signal success but no additional key to emit
 			}
 		}
@@ -1319,17 +2586,19 @@ func (h *Handler) parseModifiedCSI(seq string) (string, bool) {
 
 	// Check for X10 mouse: ESC [ M Cb Cx Cy (exactly 3 bytes after M)
 	if len(body) == 4 && body[0] == 'M' {
-		if posKey, actionKey, ok := parseMouseX10(seq); ok {
-			// For drag events, posKey is empty and position is in actionKey
-			// For other events, emit position first, then action
-			if posKey != "" {
-				h.emitKey(posKey)
-			}
-			h.emitKey(actionKey)
+		if cb, cx, cy, isRelease, ok := parseMouseX10(seq); ok {
+			h.emitMouse(cb, cx, cy, isRelease)
 			return "", true // Signal success but no additional key to emit
 		}
 	}
 
+	// Check for urxvt mouse (mode 1015): ESC [ Cb ; Cx ; Cy M, decimal
+	// parameters with 32 added to Cb as in X10
+	if cb, cx, cy, isRelease, ok := parseMouseURXVT(body); ok {
+		h.emitMouse(cb, cx, cy, isRelease)
+		return "", true
+	}
+
 	// Check for Shift+Tab: ESC [ Z
 	if body == "Z" {
 		return "S-Tab", true
@@ -1354,13 +2623,50 @@ func (h *Handler) parseModifiedCSI(seq string) (string, bool) {
 		// Position Report (DSR reply: ESC[row;colR), not modified F3 —
 		// the legacy F3-with-modifiers form is always "1;mod". Surface it
 		// as a distinct event so the application can consume it.
-		if len(parts) == 2 && parts[0] != "1" && parts[0] != "" {
-			return "CPR:" + parts[0] + ";" + parts[1], true
+		// While QueryCursorPosition is waiting, any row;col R is its reply
+		// (row 1 included), and so is the DECXCPR form CSI ? row;col R.
+		if len(parts) == 2 && (parts[0] != "1" || h.awaitingReply(replyCPR)) && parts[0] != "" {
+			cpr := parts
+			if cpr[0][0] == '?' {
+				cpr = []string{cpr[0][1:], cpr[1]}
+			}
+			if h.handleReply(termReply{kind: replyCPR, params: cpr}) {
+				return "", true
+			}
+			return "CPR:" + cpr[0] + ";" + cpr[1], true
 		}
 		return parseModifiedF1toF4(finalByte, parts)
 	case 'P', 'Q', 'S':
 		return parseModifiedF1toF4(finalByte, parts)
+	case 't':
+		// Cell size report: CSI 6 ; height ; width t
+		if len(parts) == 3 && parts[0] == "6" {
+			h.handleReply(termReply{kind: replyCellSize, params: parts})
+			return "", true
+		}
+	case 'y':
+		// DECRPM mode report: CSI ? mode ; value $ y
+		if strings.HasPrefix(params, "?") && strings.HasSuffix(params, "$") {
+			rpm := splitCSIParams(params[1 : len(params)-1])
+			if len(rpm) == 2 {
+				h.handleReply(termReply{kind: replyDECRPM, params: rpm})
+				return "", true
+			}
+		}
+	case 'c':
+		// Device Attributes reply: CSI ? ... c (DA1) or CSI > ... c (DA2)
+		if len(params) > 0 && (params[0] == '?' || params[0] == '>') {
+			kind := replyDA1
+			if params[0] == '>' {
+				kind = replyDA2
+			}
+			h.handleReply(termReply{kind: kind, params: splitCSIParams(params[1:])})
+			return "", true
+		}
 	case '~':
+		if len(parts) == 3 && parts[0] == "27" {
+			return h.parseModifyOtherKeys(parts)
+		}
 		return parseModifiedTildeKey(parts)
 	case 'u':
 		return h.parseKittyProtocol(parts)
@@ -1369,6 +2675,16 @@ func (h *Handler) parseModifiedCSI(seq string) (string, bool) {
 	return "", false
 }
 
+// parseModifyOtherKeys handles xterm's modifyOtherKeys form
+// CSI 27 ; modifiers ; keycode ~, which carries the same keycode and
+// modifiers as the kitty protocol's CSI keycode ; modifiers u (so Ctrl+Space
+// is C-Space, not ^@). It reports no lock state, so the kitty one is kept.
+func (h *Handler) parseModifyOtherKeys(parts []string) (string, bool) {
+	locks := h.locks.Load()
+	defer h.locks.Store(locks)
+	return h.parseKittyProtocol([]string{parts[2], parts[1]})
+}
+
 // splitCSIParams splits parameter string by semicolons
 func splitCSIParams(params string) []string {
 	if params == "" {
@@ -1541,6 +2857,8 @@ func parseModifiedTildeKey(parts []string) (string, bool) {
 		21: "F10",
 		23: "F11",
 		24: "F12",
+		28: "Help", // VT220 Help: xterm's Help key
+		29: "Menu", // VT220 Do: xterm's Menu key
 	}
 
 	if len(parts) == 0 {
@@ -1602,19 +2920,50 @@ var kittySpecialKeys = map[int]string{
 	57381: "F18",
 	57382: "F19",
 	57383: "F20",
-	// Keypad
-	57414: "Return", // KP_Enter - distinct from Enter (13)
-	// Navigation
-	57417: "Up",
-	57418: "Down",
-	57419: "Left",
-	57420: "Right",
-	57421: "PageUp",
-	57422: "PageDown",
-	57423: "Home",
-	57424: "End",
-	57425: "Insert",
-	57426: "Delete",
+	// Keypad - distinct from the main keyboard (see Options.NormalizeKeypad)
+	57399: "KP-0",
+	57400: "KP-1",
+	57401: "KP-2",
+	57402: "KP-3",
+	57403: "KP-4",
+	57404: "KP-5",
+	57405: "KP-6",
+	57406: "KP-7",
+	57407: "KP-8",
+	57408: "KP-9",
+	57409: "KP-Decimal",
+	57410: "KP-Divide",
+	57411: "KP-Multiply",
+	57412: "KP-Subtract",
+	57413: "KP-Add",
+	57414: "KP-Enter",
+	57415: "KP-Equal",
+	57416: "KP-Separator",
+	57417: "KP-Left",
+	57418: "KP-Right",
+	57419: "KP-Up",
+	57420: "KP-Down",
+	57421: "KP-PageUp",
+	57422: "KP-PageDown",
+	57423: "KP-Home",
+	57424: "KP-End",
+	57425: "KP-Insert",
+	57426: "KP-Delete",
+	57427: "KP-Begin",
+	// Media keys (Media- names keep MediaPause apart from the Pause key)
+	57428: "MediaPlay",
+	57429: "MediaPause",
+	57430: "MediaPlayPause",
+	57431: "MediaReverse",
+	57432: "MediaStop",
+	57433: "MediaFastForward",
+	57434: "MediaRewind",
+	57435: "MediaNext",
+	57436: "MediaPrevious",
+	57437: "MediaRecord",
+	57438: "VolumeDown",
+	57439: "VolumeUp",
+	57440: "Mute",
 }
 
 // modifierKeyInfo holds modifier name and side (Left/Right)
@@ -1670,6 +3019,7 @@ func (h *Handler) parseKittyProtocol(parts []string) (string, bool) {
 			mod = parseModifierParam(modPart)
 		}
 	}
+	h.noteLocks(mod)
 
 	// Check if this is a modifier key press/release
 	if modKeyInfo, ok := kittyModifierKeys[keycode]; ok {
@@ -1746,11 +3096,7 @@ func (h *Handler) parseKittyProtocol(parts []string) (string, bool) {
 
 	// Check for macOS Option character decoding in Kitty protocol
 	// e.g., Ctrl+´ should become M-^E since ´ = Option+e
-	h.mu.Lock()
-	decodeMacOS := h.decodeMacOSOption
-	h.mu.Unlock()
-
-	if decodeMacOS && len(baseName) > 0 {
+	if h.decodeMacOSOption.Load() && len(baseName) > 0 {
 		r, size := utf8.DecodeRuneInString(baseName)
 		if size == len(baseName) && r != utf8.RuneError { // Single rune
 			if decoded, exists := macOSOptionChars[r]; exists {
@@ -1856,6 +3202,9 @@ var numberShiftMap = map[byte]byte{
 
 // isSymbolKey checks if the keycode is a symbol key
 func isSymbolKey(keycode int) bool {
+	if keycode > 0x7f {
+		return false // not truncated to a byte: 57440 is Mute, not '`'
+	}
 	switch byte(keycode) {
 	case '`', ',', '.', '/', ';', '\'', '[', ']', '\\', '-', '=':
 		return true
@@ -1943,73 +3292,90 @@ func formatNumberKey(number byte, mod int) string {
 }
 
 // parseMouseSGR parses SGR mouse sequences: ESC [ < Cb ; Cx ; Cy M/m
-// Returns position key, action key, and success flag
-func parseMouseSGR(seq string) (string, string, bool) {
+// Returns button code, coordinates, release flag, and success flag
+func parseMouseSGR(seq string) (cb, cx, cy int, isRelease, ok bool) {
 	// Must start with ESC [ <
 	if len(seq) < 6 || seq[0] != 0x1b || seq[1] != '[' || seq[2] != '<' {
-		return "", "", false
+		return 0, 0, 0, false, false
 	}
 
 	// Final byte must be M (press) or m (release)
 	finalByte := seq[len(seq)-1]
 	if finalByte != 'M' && finalByte != 'm' {
-		return "", "", false
+		return 0, 0, 0, false, false
 	}
-	isRelease := finalByte == 'm'
+	isRelease = finalByte == 'm'
 
 	// Parse parameters: Cb;Cx;Cy
 	params := seq[3 : len(seq)-1]
 	parts := splitCSIParams(params)
 	if len(parts) != 3 {
-		return "", "", false
+		return 0, 0, 0, false, false
 	}
 
-	cb := parseIntParam(parts[0])
-	cx := parseIntParam(parts[1])
-	cy := parseIntParam(parts[2])
+	cb = parseIntParam(parts[0])
+	cx = parseIntParam(parts[1])
+	cy = parseIntParam(parts[2])
 
-	return formatMouseEvent(cb, cx, cy, isRelease)
+	return cb, cx, cy, isRelease, true
 }
 
 // parseMouseX10 parses X10 mouse sequences: ESC [ M Cb Cx Cy
-// Returns position key, action key, and success flag
-func parseMouseX10(seq string) (string, string, bool) {
+// Returns button code, coordinates, release flag, and success flag
+func parseMouseX10(seq string) (cb, cx, cy int, isRelease, ok bool) {
 	// Must be exactly ESC [ M followed by 3 bytes
 	if len(seq) != 6 || seq[0] != 0x1b || seq[1] != '[' || seq[2] != 'M' {
-		return "", "", false
+		return 0, 0, 0, false, false
 	}
 
 	// Decode button and coordinates (all have 32 added)
-	cb := int(seq[3]) - 32
-	cx := int(seq[4]) - 32
-	cy := int(seq[5]) - 32
+	cb = int(seq[3]) - 32
+	cx = int(seq[4]) - 32
+	cy = int(seq[5]) - 32
 
 	// X10 protocol: button code 3 means release
-	isRelease := (cb & 3) == 3
+	isRelease = (cb & 3) == 3
+
+	return cb, cx, cy, isRelease, true
+}
 
-	return formatMouseEvent(cb, cx, cy, isRelease)
+// parseMouseURXVT parses the body of a urxvt mouse sequence (after ESC [):
+// Cb ; Cx ; Cy M with decimal parameters. Like X10, Cb has 32 added and
+// button code 3 means release.
+// Returns button code, coordinates, release flag, and success flag
+func parseMouseURXVT(body string) (cb, cx, cy int, isRelease, ok bool) {
+	if len(body) < 6 || body[len(body)-1] != 'M' {
+		return 0, 0, 0, false, false
+	}
+	parts := splitCSIParams(body[:len(body)-1])
+	if len(parts) != 3 {
+		return 0, 0, 0, false, false
+	}
+	for _, p := range parts {
+		if p == "" || !isDigits(p) {
+			return 0, 0, 0, false, false
+		}
+	}
+	cb = parseIntParam(parts[0]) - 32
+	if cb < 0 {
+		return 0, 0, 0, false, false
+	}
+	cx = parseIntParam(parts[1])
+	cy = parseIntParam(parts[2])
+	isRelease = (cb & 3) == 3
+	return cb, cx, cy, isRelease, true
 }
 
+// extraMouseButtons names buttons 8-11 by their low button bits
+var extraMouseButtons = [4]string{"Back", "Forward", "Button10", "Button11"}
+
 // formatMouseEvent formats a mouse event into position and action keys
-// For drag events, position is embedded in action key (MouseLeftDrag@x,y)
+// For drag and motion events, position is embedded in action key
+// (MouseLeftDrag@x,y, MouseMotion@x,y)
 // For press/release/scroll, separate posKey and actionKey are returned
 func formatMouseEvent(cb, cx, cy int, isRelease bool) (string, string, bool) {
 	// Decode modifiers from button code
-	hasShift := (cb & 4) != 0
-	hasAlt := (cb & 8) != 0
-	hasCtrl := (cb & 16) != 0
-
-	// Build modifier prefix
-	prefix := ""
-	if hasShift {
-		prefix += "S-"
-	}
-	if hasAlt {
-		prefix += "M-"
-	}
-	if hasCtrl {
-		prefix += "C-"
-	}
+	prefix := mouseModifierPrefix(cb)
 
 	// Decode button and action
 	var action string
@@ -2017,7 +3383,19 @@ func formatMouseEvent(cb, cx, cy int, isRelease bool) (string, string, bool) {
 	isMotion := (cb & 32) != 0
 	isScroll := (cb & 64) != 0
 
-	if isScroll {
+	if cb&128 != 0 {
+		// Extra buttons 8-11 (SGR codes 128-131): 8 and 9 are the Back and
+		// Forward side buttons.
+		name := extraMouseButtons[buttonBits]
+		if isMotion {
+			return "", fmt.Sprintf("%sMouse%sDrag@%d,%d", prefix, name, cx, cy), true
+		}
+		if isRelease {
+			action = "Mouse" + name + "Release"
+		} else {
+			action = "Mouse" + name + "Press"
+		}
+	} else if isScroll {
 		// Scroll wheel. The low two bits select the wheel axis/direction:
 		// 0 = up, 1 = down, 2 = left, 3 = right (SGR buttons 64..67).
 		switch buttonBits {
@@ -2031,7 +3409,9 @@ func formatMouseEvent(cb, cx, cy int, isRelease bool) (string, string, bool) {
 			action = "MouseScrollRight"
 		}
 	} else if isMotion {
-		// Mouse drag - include position in action key
+		// Mouse drag - include position in action key. Motion with no
+		// button held (button bits 3) is hover, reported only under any-motion
+		// tracking (mode 1003).
 		switch buttonBits {
 		case 0:
 			action = "MouseLeftDrag"
@@ -2040,9 +3420,9 @@ func formatMouseEvent(cb, cx, cy int, isRelease bool) (string, string, bool) {
 		case 2:
 			action = "MouseRightDrag"
 		default:
-			action = "MouseDrag"
+			action = "MouseMotion"
 		}
-		// For drag events, embed position in the action key and return empty posKey
+		// For drag/motion events, embed position in the action key and return empty posKey
 		return "", fmt.Sprintf("%s%s@%d,%d", prefix, action, cx, cy), true
 	} else if isRelease {
 		// Button release
@@ -9,13 +9,19 @@ import (
 // newPipedHandler wires a handler to an in-memory pipe (no real terminal) and
 // starts it, returning the write end and a cleanup.
 func newPipedHandler(t *testing.T) (*Handler, *io.PipeWriter, func()) {
+	t.Helper()
+	return newPipedHandlerWith(t, Options{})
+}
+
+// newPipedHandlerWith is newPipedHandler with caller-supplied options;
+// InputReader and ManageTerminal are filled in.
+func newPipedHandlerWith(t *testing.T, opts Options) (*Handler, *io.PipeWriter, func()) {
 	t.Helper()
 	noManage := false
 	pr, pw := io.Pipe()
-	h := New(Options{
-		InputReader:    pr,
-		ManageTerminal: &noManage,
-	})
+	opts.InputReader = pr
+	opts.ManageTerminal = &noManage
+	h := New(opts)
 	if err := h.Start(); err != nil {
 		t.Fatalf("Start: %v", err)
 	}
@@ -107,3 +113,112 @@ func TestOSC52DoesNotDisturbKeys(t *testing.T) {
 		t.Fatal("plain key after clipboard response never arrived")
 	}
 }
+
+// TestOSCRouting: non-52 OSC replies go to a HandleOSC route if one is
+// registered, otherwise to OnOSC, and never leak into the key stream.
+func TestOSCRouting(t *testing.T) {
+	type osc struct {
+		code    int
+		payload string
+	}
+	got := make(chan osc, 2)
+	routed := make(chan string, 1)
+
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+	h.OnOSC = func(code int, payload []byte) { got <- osc{code, string(payload)} }
+	h.HandleOSC(10, func(payload []byte) { routed <- string(payload) })
+
+	if _, err := pw.Write([]byte("\x1b]11;rgb:0000/0000/0000\x1b\\\x1b]10;rgb:ffff/ffff/ffff\x07a")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-got:
+		if r.code != 11 || r.payload != "rgb:0000/0000/0000" {
+			t.Errorf("OnOSC got (%d, %q), want (11, %q)", r.code, r.payload, "rgb:0000/0000/0000")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnOSC was not called")
+	}
+	select {
+	case p := <-routed:
+		if p != "rgb:ffff/ffff/ffff" {
+			t.Errorf("route got %q", p)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("HandleOSC route was not called")
+	}
+	select {
+	case k := <-h.Keys:
+		if k != "a" {
+			t.Errorf("key after OSC replies = %q, want \"a\"", k)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("plain key after OSC replies never arrived")
+	}
+}
+
+// TestAltRightBracketStillParses: a lone ESC ] is not an OSC string; once the
+// escape timeout expires it is Alt+].
+func TestAltRightBracketStillParses(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("\x1b]")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case k := <-h.Keys:
+		if k != "M-]" {
+			t.Errorf("key = %q, want \"M-]\"", k)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("M-] never arrived")
+	}
+}
+
+// TestControlStringConsumed: a DCS reply (here an XTGETTCAP answer) is
+// delivered on OnControlString and not shredded into keys.
+func TestControlStringConsumed(t *testing.T) {
+	type cs struct {
+		intro   byte
+		payload string
+	}
+	got := make(chan cs, 1)
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+	h.OnControlString = func(intro byte, payload []byte) { got <- cs{intro, string(payload)} }
+
+	if _, err := pw.Write([]byte("\x1bP1+r544e=787465726d\x1b\\a")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case r := <-got:
+		if r.intro != 'P' || r.payload != "1+r544e=787465726d" {
+			t.Errorf("OnControlString got (%q, %q)", r.intro, r.payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnControlString was not called")
+	}
+	select {
+	case k := <-h.Keys:
+		if k != "a" {
+			t.Errorf("key after DCS = %q, want \"a\"", k)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("plain key after DCS never arrived")
+	}
+}
+
+// TestControlStringAbandoned: Alt+Shift+P and then typing is not a DCS
+// reply; once input goes idle the keys come through, Enter included.
+func TestControlStringAbandoned(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("\x1bPqhi\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "M-S-p", "q", "h", "i", "Enter")
+}
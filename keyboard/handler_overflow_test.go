@@ -0,0 +1,64 @@
+package keyboard
+
+import (
+	"testing"
+	"time"
+)
+
+// TestKeyOverflowDropNewest: with a full Keys channel, the newest key is the
+// one dropped, and it is reported on OnKeyDropped.
+func TestKeyOverflowDropNewest(t *testing.T) {
+	dropped := make(chan string, 4)
+	h, pw, cleanup := newPipedHandlerWith(t, Options{
+		KeyBufferSize: 1,
+		KeyOverflow:   OverflowDropNewest,
+	})
+	defer cleanup()
+	h.OnKeyDropped = func(key string) { dropped <- key }
+
+	if _, err := pw.Write([]byte("ab")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case k := <-dropped:
+		if k != "b" {
+			t.Errorf("dropped key = %q, want \"b\"", k)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnKeyDropped was not called")
+	}
+	if k := <-h.Keys; k != "a" {
+		t.Errorf("buffered key = %q, want \"a\"", k)
+	}
+	if st := h.Stats(); st.Keys != 2 || st.KeysDropped != 1 || st.BytesRead != 2 {
+		t.Errorf("Stats = %+v, want Keys=2 KeysDropped=1 BytesRead=2", st)
+	}
+}
+
+// TestKeyOverflowBlock: with OverflowBlock nothing is dropped; emission waits
+// for the consumer.
+func TestKeyOverflowBlock(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{
+		KeyBufferSize: 1,
+		KeyOverflow:   OverflowBlock,
+	})
+	defer cleanup()
+	h.OnKeyDropped = func(key string) { t.Errorf("key %q dropped under OverflowBlock", key) }
+
+	if _, err := pw.Write([]byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	for _, want := range []string{"a", "b", "c"} {
+		select {
+		case k := <-h.Keys:
+			if k != want {
+				t.Errorf("key = %q, want %q", k, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("key %q never arrived", want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package keyboard
+
+import (
+	"testing"
+	"time"
+)
+
+// TestPauseDiscard: input that arrives while paused in discard mode is dropped,
+// and keys typed after Resume are delivered normally.
+func TestPauseDiscard(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+
+	h.Pause(PauseDiscard)
+	if _, err := pw.Write([]byte("xyz")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case k := <-h.Keys:
+		t.Fatalf("key %q emitted while paused", k)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	h.Resume()
+	if _, err := pw.Write([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case k := <-h.Keys:
+		if k != "a" {
+			t.Errorf("key after Resume = %q, want \"a\"", k)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("key after Resume never arrived")
+	}
+}
+
+// TestPauseBuffer: input that arrives while paused in buffer mode is held and
+// delivered once the handler resumes.
+func TestPauseBuffer(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+
+	h.Pause(PauseBuffer)
+	if _, err := pw.Write([]byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case k := <-h.Keys:
+		t.Fatalf("key %q emitted while paused", k)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	h.Resume()
+	select {
+	case k := <-h.Keys:
+		if k != "b" {
+			t.Errorf("buffered key = %q, want \"b\"", k)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("buffered key never arrived after Resume")
+	}
+}
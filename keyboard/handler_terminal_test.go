@@ -0,0 +1,97 @@
+package keyboard
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+// TestRestoreOnPanic: a panic in a goroutine that defers RestoreOnPanic pops
+// the protocol modes before the panic continues.
+func TestRestoreOnPanic(t *testing.T) {
+	noManage := false
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	var modes bytes.Buffer
+	h := New(Options{
+		InputReader:    pr,
+		ManageTerminal: &noManage,
+		ModeWriter:     &modes,
+		EnterModes:     "<enter>",
+		ExitModes:      "<exit>",
+	})
+	if err := h.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer h.Stop()
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("recovered %v, want the original panic", r)
+			}
+		}()
+		defer h.RestoreOnPanic()
+		panic("boom")
+	}()
+
+	if got := modes.String(); got != "<enter><exit>" {
+		t.Errorf("mode output = %q, want %q", got, "<enter><exit>")
+	}
+
+	// Stop must not pop the modes a second time.
+	h.Stop()
+	if got := modes.String(); got != "<enter><exit>" {
+		t.Errorf("mode output after Stop = %q, want %q", got, "<enter><exit>")
+	}
+}
+
+// TestRestoreOnPanicLockHeld: a panic while another goroutine holds h.mu
+// waits for it before popping the modes, and one that can't get the lock
+// gives up without touching the handler's state.
+func TestRestoreOnPanicLockHeld(t *testing.T) {
+	noManage := false
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	modes := &echoBuffer{}
+	h := New(Options{
+		InputReader:    pr,
+		ManageTerminal: &noManage,
+		ModeWriter:     modes,
+		EnterModes:     "<enter>",
+		ExitModes:      "<exit>",
+	})
+	if err := h.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer h.Stop()
+
+	panicWith := func() {
+		defer func() { recover() }()
+		defer h.RestoreOnPanic()
+		panic("boom")
+	}
+
+	h.mu.Lock()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		h.mu.Unlock()
+	}()
+	panicWith()
+	if got := modes.take(); got != "<enter><exit>" {
+		t.Errorf("mode output = %q, want %q", got, "<enter><exit>")
+	}
+
+	h.mu.Lock()
+	h.terminalActive = true
+	start := time.Now()
+	panicWith()
+	if elapsed := time.Since(start); elapsed < panicLockWait {
+		t.Errorf("gave up after %v, want %v", elapsed, panicLockWait)
+	}
+	if !h.terminalActive {
+		t.Error("terminal state changed without the lock")
+	}
+	h.mu.Unlock()
+}
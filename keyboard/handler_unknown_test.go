@@ -0,0 +1,62 @@
+package keyboard
+
+import (
+	"testing"
+	"time"
+)
+
+// TestOnUnknownSequenceConsumes: an undecodable CSI sequence is handed to
+// OnUnknownSequence intact, and claiming it suppresses the Escape+chars
+// fallback.
+func TestOnUnknownSequenceConsumes(t *testing.T) {
+	got := make(chan string, 1)
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+	h.OnUnknownSequence = func(seq []byte) bool {
+		got <- string(seq)
+		return true
+	}
+
+	if _, err := pw.Write([]byte("\x1b[99;1Xa")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case seq := <-got:
+		if seq != "\x1b[99;1X" {
+			t.Errorf("sequence = %q, want %q", seq, "\x1b[99;1X")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnUnknownSequence was not called")
+	}
+	select {
+	case k := <-h.Keys:
+		if k != "a" {
+			t.Errorf("first key = %q, want \"a\" (fallback keys leaked)", k)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("key after unknown sequence never arrived")
+	}
+}
+
+// TestOnUnknownSequenceFallback: returning false keeps the legacy behavior.
+func TestOnUnknownSequenceFallback(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+	h.OnUnknownSequence = func([]byte) bool { return false }
+
+	if _, err := pw.Write([]byte("\x1b[9X")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"Escape", "[", "9", "X"} {
+		select {
+		case k := <-h.Keys:
+			if k != want {
+				t.Errorf("key = %q, want %q", k, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("key %q never arrived", want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package keyboard
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Modifier is a set of modifier keys held with a key
+type Modifier uint8
+
+const (
+	ModShift Modifier = 1 << iota
+	ModAlt            // Alt/Meta (M-)
+	ModCtrl           // Control (C- or ^X)
+	ModSuper          // Super/Command (s-)
+)
+
+// KeyEvent is a key as a structured event, as delivered on Events: the key
+// name that Keys carries, split into the base key and its modifiers.
+type KeyEvent struct {
+	Name string    // Key name as delivered on Keys ("a", "M-x", "C-S-Up", "^A")
+	Key  string    // The key without modifiers ("a", "x", "Up", "a" for ^A)
+	Rune rune      // The character the key types, if it is one (else 0)
+	Mods Modifier  // Modifiers held
+	Time time.Time // When the key was delivered
+
+	// Count is the number of presses a coalesced key stands for, from its
+	// ":N" suffix (see Options.RepeatCoalesce and ScrollCoalesce); 1
+	// otherwise
+	Count int
+
+	// MacOSOption is true for M-Up, M-Down, M-Left, M-Right, M-Home, and
+	// M-End sent the macOS Terminal way (ESC ESC [ A ...) rather than as
+	// xterm modifier sequences
+	MacOSOption bool
+
+	// CapsLock and NumLock are the lock state when the key was delivered
+	// (see Handler.LockState; always false unless the terminal reports it)
+	CapsLock bool
+	NumLock  bool
+}
+
+// Has reports whether all of mods were held
+func (k KeyEvent) Has(mods Modifier) bool {
+	return k.Mods&mods == mods
+}
+
+// String returns the key name, as delivered on Keys
+func (k KeyEvent) String() string {
+	return k.Name
+}
+
+// ParseKeyEvent splits a key name ("S-C-Up", "M-x", "^A", ...) into a
+// KeyEvent. Names that aren't modifier notation come back as the Key
+// itself, so any name the handler emits parses.
+func ParseKeyEvent(name string) KeyEvent {
+	k := KeyEvent{Name: name, Count: 1}
+	rest := name
+	if i := strings.LastIndexByte(rest, ':'); i > 0 {
+		if n, err := strconv.Atoi(rest[i+1:]); err == nil && n > 1 {
+			rest, k.Count = rest[:i], n
+		}
+	}
+	for len(rest) > 2 && rest[1] == '-' {
+		var m Modifier
+		switch rest[0] {
+		case 'M':
+			m = ModAlt
+		case 'C':
+			m = ModCtrl
+		case 'S':
+			m = ModShift
+		case 's':
+			m = ModSuper
+		}
+		if m == 0 {
+			break
+		}
+		k.Mods |= m
+		rest = rest[2:]
+	}
+
+	switch {
+	case len(rest) == 2 && rest[0] == '^':
+		// ^A: Control + character, named by its lowercase letter
+		k.Mods |= ModCtrl
+		k.Key = strings.ToLower(rest[1:])
+	case rest == "Space":
+		k.Key = rest
+		k.Rune = ' '
+	case utf8.RuneCountInString(rest) == 1:
+		k.Key = rest
+		k.Rune, _ = utf8.DecodeRuneInString(rest)
+	default:
+		k.Key = rest
+	}
+	return k
+}
+
+// sendKeyEvent delivers key on Events
+func (h *Handler) sendKeyEvent(key string) {
+	ev := ParseKeyEvent(key)
+	ev.Time = time.Now()
+	ev.MacOSOption = h.macOSOptionKey
+	locks := h.LockState()
+	ev.CapsLock, ev.NumLock = locks.CapsLock, locks.NumLock
+	sendWithPolicy(h.Events, ev, h.keyOverflow, h.stopChan, func(dropped KeyEvent) {
+		h.keyDropped(dropped.Name)
+	})
+}
@@ -0,0 +1,104 @@
+package keyboard
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseKeyEvent(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		r    rune
+		mods Modifier
+	}{
+		{"a", "a", 'a', 0},
+		{"M-x", "x", 'x', ModAlt},
+		{"^A", "a", 0, ModCtrl},
+		{"S-C-Up", "Up", 0, ModShift | ModCtrl},
+		{"s-M-Enter", "Enter", 0, ModSuper | ModAlt},
+		{"é", "é", 'é', 0},
+		{"Space", "Space", ' ', 0},
+		{"-", "-", '-', 0},
+		{"Mouse@3,4", "Mouse@3,4", 0, 0},
+		{"C-Down:3", "Down", 0, ModCtrl},
+	}
+	for _, tt := range tests {
+		ev := ParseKeyEvent(tt.name)
+		if ev.Name != tt.name || ev.Key != tt.key || ev.Rune != tt.r || ev.Mods != tt.mods {
+			t.Errorf("ParseKeyEvent(%q) = %+v, want Key %q Rune %q Mods %b", tt.name, ev, tt.key, tt.r, tt.mods)
+		}
+	}
+}
+
+// TestEventChannel: keys arrive on Events, and on Keys only while
+// LegacyKeys allows it.
+func TestEventChannel(t *testing.T) {
+	noLegacy := false
+	h, pw, cleanup := newPipedHandlerWith(t, Options{EventChannel: true, LegacyKeys: &noLegacy})
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("\x1b[1;5A")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-h.Events:
+		if ev.Name != "C-Up" || ev.Key != "Up" || !ev.Has(ModCtrl) || ev.Time.IsZero() {
+			t.Errorf("event = %+v, want C-Up", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no KeyEvent arrived")
+	}
+	select {
+	case k := <-h.Keys:
+		t.Errorf("Keys got %q with LegacyKeys off", k)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestMacOSOptionArrow: ESC ESC [ A is M-Up flagged MacOSOption, with the
+// "Special" marker only when asked for.
+func TestMacOSOptionArrow(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{EventChannel: true})
+	defer cleanup()
+	if _, err := pw.Write([]byte("\x1b\x1b[A\x1b[1;3A")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "M-Up", "M-Up")
+	for _, want := range []bool{true, false} {
+		select {
+		case ev := <-h.Events:
+			if ev.Name != "M-Up" || ev.MacOSOption != want {
+				t.Errorf("event = %+v, want M-Up with MacOSOption %v", ev, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("no KeyEvent arrived")
+		}
+	}
+
+	marked, pw2, cleanup2 := newPipedHandlerWith(t, Options{SpecialMarker: true})
+	defer cleanup2()
+	if _, err := pw2.Write([]byte("\x1b\x1b[A")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, marked, "Special", "M-Up")
+}
+
+// TestSpaceModifiers: Ctrl+Space and Shift+Space are distinct keys when the
+// terminal reports them (kitty CSI u or xterm modifyOtherKeys); legacy
+// encoding folds them into ^@ and a plain space.
+func TestSpaceModifiers(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+	if _, err := pw.Write([]byte("\x1b[27;5;32~\x1b[27;2;32~")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "C-Space", "S-Space")
+	if h.LockState().Known {
+		t.Error("modifyOtherKeys keys changed the lock state")
+	}
+	if _, err := pw.Write([]byte("\x1b[32;5u\x1b[32;2u\x00 ")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "C-Space", "S-Space", "^@", " ")
+}
@@ -0,0 +1,54 @@
+package keyboard
+
+import "strings"
+
+// keypadMainKeys maps keypad keys to the main keyboard key that does the
+// same, for Options.NormalizeKeypad
+var keypadMainKeys = map[string]string{
+	"KP-0":         "0",
+	"KP-1":         "1",
+	"KP-2":         "2",
+	"KP-3":         "3",
+	"KP-4":         "4",
+	"KP-5":         "5",
+	"KP-6":         "6",
+	"KP-7":         "7",
+	"KP-8":         "8",
+	"KP-9":         "9",
+	"KP-Decimal":   ".",
+	"KP-Divide":    "/",
+	"KP-Multiply":  "*",
+	"KP-Subtract":  "-",
+	"KP-Add":       "+",
+	"KP-Separator": ",",
+	"KP-Equal":     "=",
+	"KP-Enter":     "Enter",
+	"KP-Left":      "Left",
+	"KP-Right":     "Right",
+	"KP-Up":        "Up",
+	"KP-Down":      "Down",
+	"KP-PageUp":    "PageUp",
+	"KP-PageDown":  "PageDown",
+	"KP-Home":      "Home",
+	"KP-End":       "End",
+	"KP-Insert":    "Insert",
+	"KP-Delete":    "Delete",
+}
+
+// normalizeKeypad renames a keypad key (with any modifiers and event
+// suffix, e.g. "C-KP-Up:Release") to its main keyboard name
+func normalizeKeypad(key string) string {
+	i := strings.Index(key, "KP-")
+	if i < 0 || (i > 0 && key[i-1] != '-') {
+		return key
+	}
+	name, suffix := key[i:], ""
+	if j := strings.IndexByte(name, ':'); j >= 0 {
+		name, suffix = name[:j], name[j:]
+	}
+	main, ok := keypadMainKeys[name]
+	if !ok {
+		return key
+	}
+	return key[:i] + main + suffix
+}
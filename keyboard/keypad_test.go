@@ -0,0 +1,55 @@
+package keyboard
+
+import "testing"
+
+// TestKeypadKeys: keypad keys are named apart from the main keyboard, in
+// application keypad mode and under the kitty protocol.
+func TestKeypadKeys(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+	input := "\x1bOu\x1bOM\x1bOk\x1b[E" + // application keypad mode
+		"\x1b[57404;129u\x1b[57414u\x1b[57419;5u\x1b[57413;1:3u" + // kitty
+		"5\r\x1b[A"
+	if _, err := pw.Write([]byte(input)); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "KP-5", "KP-Enter", "KP-Add", "KP-Begin",
+		"KP-5", "KP-Enter", "C-KP-Up", "KP-Add:Release",
+		"5", "Enter", "Up")
+}
+
+// TestNormalizeKeypad: with NormalizeKeypad, keypad keys arrive under their
+// main keyboard names.
+func TestNormalizeKeypad(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{NormalizeKeypad: true})
+	defer cleanup()
+	input := "\x1bOu\x1bOM\x1bOk\x1b[E\x1b[57419;5u\x1b[57413;1:3u"
+	if _, err := pw.Write([]byte(input)); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "5", "Enter", "+", "KP-Begin", "C-Up", "+:Release")
+}
+
+// TestMediaKeys: kitty media keycodes have names of their own.
+func TestMediaKeys(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+	input := "\x1b[57430u\x1b[57429u\x1b[57362u\x1b[57439u\x1b[57438;2u\x1b[57440u\x1b[57435;1:3u"
+	if _, err := pw.Write([]byte(input)); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "MediaPlayPause", "MediaPause", "Pause", "VolumeUp",
+		"S-VolumeDown", "Mute", "MediaNext:Release")
+}
+
+// TestLegacySpecialKeys: the VT220 Help and Do sequences, which xterm sends
+// for Help and Menu, are keys in ordinary terminals too. The profile is
+// pinned, since rxvt's quirks read these sequences as shifted F-keys.
+func TestLegacySpecialKeys(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{Terminal: &TerminalProfile{}})
+	defer cleanup()
+	if _, err := pw.Write([]byte("\x1b[29~\x1b[28~\x1b[29;5~\x1b[57363u")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "Menu", "Help", "C-Menu", "Menu")
+}
@@ -0,0 +1,488 @@
+package keyboard
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PasteLinePolicy selects what line assembly does with a bracketed paste
+// that contains newlines
+type PasteLinePolicy int
+
+const (
+	// PasteLinesDiscard submits the first pasted line and drops the rest
+	PasteLinesDiscard PasteLinePolicy = iota
+	// PasteLinesSubmit submits every pasted line as its own line, in order;
+	// text after the last newline stays on the line being edited
+	PasteLinesSubmit
+	// PasteLinesBuffer submits the first pasted line and keeps the rest for
+	// the next read: each SetLineMode(true) continues with the next line
+	PasteLinesBuffer
+	// PasteLinesJoin inserts the paste as one line, with each newline
+	// replaced by Options.PasteJoin
+	PasteLinesJoin
+)
+
+// PasteNewlineMode selects how newlines in a bracketed paste are emitted
+// as keys. Line assembly treats CR, LF, and CRLF alike as one line break
+// whatever the mode.
+type PasteNewlineMode int
+
+const (
+	// PasteNewlinesPreserve emits CR as Enter and LF as ^J, so CRLF is both
+	PasteNewlinesPreserve PasteNewlineMode = iota
+	// PasteNewlinesLF emits CR, LF, and CRLF each as one ^J
+	PasteNewlinesLF
+	// PasteNewlinesEnter emits CR, LF, and CRLF each as one Enter
+	PasteNewlinesEnter
+)
+
+// pasteNewlineKey returns the key for the CR or LF starting content, and
+// the bytes it covers
+func (h *Handler) pasteNewlineKey(content []byte) (string, int) {
+	size := 1
+	if h.pasteNewlines != PasteNewlinesPreserve && content[0] == '\r' && len(content) > 1 && content[1] == '\n' {
+		size = 2
+	}
+	switch {
+	case h.pasteNewlines == PasteNewlinesLF:
+		return "^J", size
+	case h.pasteNewlines == PasteNewlinesEnter, content[0] == '\r':
+		return "Enter", size
+	}
+	return "^J", size
+}
+
+// submitLineLocked delivers the line being edited on Lines and OnLine and
+// starts a new one, unless OnValidateLine rejects it - call only while
+// holding h.mu, which is released while the line is validated and
+// delivered. delim, if any, is added to the end of the line. Returns false
+// if the line was rejected.
+func (h *Handler) submitLineLocked(delim []byte) bool {
+	lineBytes := make([]byte, len(h.currentLine), len(h.currentLine)+len(delim))
+	copy(lineBytes, h.currentLine)
+	lineBytes = append(lineBytes, delim...)
+	if h.OnValidateLine != nil {
+		h.mu.Unlock()
+		ok := h.OnValidateLine(lineBytes)
+		h.mu.Lock()
+		if !ok {
+			h.echoLocked("\a")
+			return false
+		}
+	}
+	h.clearLineLocked()
+	echoWriter := h.echoWriter
+	h.mu.Unlock()
+
+	h.sendLine(lineBytes)
+	if h.OnLine != nil {
+		h.OnLine(lineBytes)
+	}
+	if echoWriter != nil {
+		echoWriter.Write([]byte("\r\n"))
+	}
+
+	h.mu.Lock()
+	if h.inLineReadMode.Load() {
+		h.echoLocked(h.prompt)
+	}
+	return true
+}
+
+// SetPrompt sets the prompt line mode shows before each line: it is echoed
+// when line mode is enabled and after each line is submitted, and redrawn
+// with the line by RedrawLine. If a line is being edited, it is redrawn
+// with the new prompt. Needs an EchoWriter.
+func (h *Handler) SetPrompt(prompt string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.prompt = prompt
+	if h.inLineReadMode.Load() {
+		h.redrawLocked()
+	}
+}
+
+// SetLineTimeout sets how long line mode waits for the user to type before
+// giving up on the line (0 to wait forever), overriding
+// Options.LineTimeout. It takes effect from the next key.
+func (h *Handler) SetLineTimeout(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lineTimeout = d
+}
+
+// armLineTimeoutLocked restarts the line timeout - call only while holding
+// h.mu
+func (h *Handler) armLineTimeoutLocked() {
+	h.lineTimeoutGen++
+	if h.lineTimeout <= 0 {
+		return
+	}
+	gen := h.lineTimeoutGen
+	h.after(h.lineTimeout, func() { h.lineTimedOut(gen) })
+}
+
+// lineTimedOut delivers the partial line when the line timeout expires
+func (h *Handler) lineTimedOut(gen int) {
+	h.mu.Lock()
+	if h.lineTimeoutGen != gen || !h.inLineReadMode.Load() {
+		h.mu.Unlock()
+		return
+	}
+	h.lineTimeoutGen++
+	line := make([]byte, len(h.currentLine))
+	copy(line, h.currentLine)
+	h.clearLineLocked()
+	echoWriter := h.echoWriter
+	h.mu.Unlock()
+
+	h.debug("Line timed out", "bytes", len(line))
+	h.stats.lines.Add(1)
+	h.audit(Event{Kind: EventLine, Line: line, TimedOut: true}, h.secret.Load())
+	if !h.secret.Load() {
+		h.publish(Event{Kind: EventLine, Line: line, TimedOut: true})
+	}
+	sendWithPolicy(h.Lines, line, h.lineOverflow, h.stopChan, h.lineDropped)
+	if h.OnLineTimeout != nil {
+		h.OnLineTimeout(line)
+	}
+	if echoWriter != nil {
+		echoWriter.Write([]byte("\r\n"))
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.inLineReadMode.Load() {
+		h.echoLocked(h.prompt)
+	}
+}
+
+// SetLineDelimiters sets the keys that end a line besides Enter, and
+// whether lines keep them, overriding Options.LineDelimiters and
+// KeepDelimiter.
+func (h *Handler) SetLineDelimiters(keys []string, keep bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.setLineDelimitersLocked(keys, keep)
+}
+
+// setLineDelimitersLocked sets the line delimiters - call only while
+// holding h.mu
+func (h *Handler) setLineDelimitersLocked(keys []string, keep bool) {
+	h.lineDelimiters = nil
+	if len(keys) > 0 {
+		h.lineDelimiters = make(map[string]bool, len(keys))
+		for _, k := range keys {
+			h.lineDelimiters[k] = true
+		}
+	}
+	h.keepDelimiter = keep
+}
+
+// submitDelimitedLocked submits the line ended by the delimiter key,
+// keeping the key's bytes if asked to - call only while holding h.mu
+func (h *Handler) submitDelimitedLocked(key string) {
+	h.expandAbbrevLocked()
+	var delim []byte
+	if h.keepDelimiter {
+		delim, _ = EncodeKey(key)
+	}
+	h.submitLineLocked(delim)
+}
+
+// SetMaxLineLength limits the lines line mode accepts to n characters
+// (0 for no limit), overriding Options.MaxLineLength.
+func (h *Handler) SetMaxLineLength(n int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.maxLineLength = n
+}
+
+// Prompt returns the prompt set with SetPrompt.
+func (h *Handler) Prompt() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.prompt
+}
+
+// RedrawLine re-renders the prompt and the line being edited, with the
+// cursor back where it was, e.g. after other output has been written over
+// them. Resize calls it in line mode. Does nothing outside line mode.
+func (h *Handler) RedrawLine() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.inLineReadMode.Load() {
+		h.redrawLocked()
+	}
+}
+
+// redrawLocked re-renders the prompt and line from the start of the row the
+// prompt is on (so a line wrapped on a terminal of known width is redrawn
+// whole) - call only while holding h.mu
+func (h *Handler) redrawLocked() {
+	if h.echoWriter == nil {
+		return
+	}
+	off := h.lineOffsetLocked(h.lineCursor)
+	var b strings.Builder
+	if h.termCols > 0 {
+		if up := (textWidth([]byte(h.prompt)) + textWidth(h.shownLocked(h.currentLine[:off]))) / h.termCols; up > 0 {
+			fmt.Fprintf(&b, "\x1b[%dA", up)
+		}
+	}
+	b.WriteString("\r\x1b[J")
+	b.WriteString(h.prompt)
+	b.WriteString(h.renderLineLocked())
+	b.WriteString(cursorLeft(textWidth(h.shownLocked(h.currentLine[off:]))))
+	h.echoLocked(b.String())
+}
+
+// highlightingLocked reports whether edits are echoed by re-rendering the
+// line through OnHighlight - call only while holding h.mu
+func (h *Handler) highlightingLocked() bool {
+	return h.OnHighlight != nil && !h.secret.Load() && h.echoWriter != nil
+}
+
+// renderLineLocked returns the line being edited as echoed: styled by
+// OnHighlight, masked under SetSecret, or as typed - call only while
+// holding h.mu
+func (h *Handler) renderLineLocked() string {
+	if h.highlightingLocked() {
+		return h.OnHighlight(slices.Clone(h.currentLine))
+	}
+	return string(h.shownLocked(h.currentLine))
+}
+
+// rerenderLocked echoes the whole line again after an edit, from the cursor
+// that was cursorWidth columns into it, and puts the cursor back in place -
+// call only while holding h.mu
+func (h *Handler) rerenderLocked(cursorWidth int) {
+	tail := h.currentLine[h.lineOffsetLocked(h.lineCursor):]
+	h.echoLocked(cursorLeft(cursorWidth) + h.renderLineLocked() + "\x1b[K" + cursorLeft(textWidth(tail)))
+}
+
+// insertLocked adds the printable characters of s (and tabs) to the line
+// being edited - call only while holding h.mu
+func (h *Handler) insertLocked(s string) {
+	for _, r := range s {
+		if r == utf8.RuneError || (r < 32 && r != '\t') {
+			continue
+		}
+		h.insertCharLocked(utf8.AppendRune(nil, r))
+	}
+}
+
+// clearLineLocked empties the line being edited - call only while holding
+// h.mu
+func (h *Handler) clearLineLocked() {
+	if h.secret.Load() {
+		clear(h.currentLine[:cap(h.currentLine)])
+	}
+	h.currentLine = nil
+	h.charByteLengths = nil
+	h.lineCursor = 0
+}
+
+// lineOffsetLocked returns the byte offset of character i of the line
+// being edited - call only while holding h.mu
+func (h *Handler) lineOffsetLocked(i int) int {
+	n := 0
+	for _, l := range h.charByteLengths[:i] {
+		n += l
+	}
+	return n
+}
+
+// insertCharLocked inserts one character at the cursor and echoes it,
+// redrawing the rest of the line after it, unless the line is full or
+// the character class or OnValidateChar rejects it - call only while
+// holding h.mu
+func (h *Handler) insertCharLocked(char []byte) {
+	if h.charClass != nil {
+		if r, _ := utf8.DecodeRune(char); !h.charClass(r) {
+			h.echoLocked("\a")
+			if h.OnReject != nil {
+				h.mu.Unlock()
+				h.OnReject(r)
+				h.mu.Lock()
+			}
+			return
+		}
+	}
+	if h.maxLineLength > 0 && len(h.charByteLengths) >= h.maxLineLength {
+		h.echoLocked("\a")
+		if h.OnLineFull != nil {
+			r, _ := utf8.DecodeRune(char)
+			h.mu.Unlock()
+			h.OnLineFull(r)
+			h.mu.Lock()
+		}
+		return
+	}
+	if h.OnValidateChar != nil {
+		r, _ := utf8.DecodeRune(char)
+		proposed := slices.Insert(slices.Clone(h.currentLine), h.lineOffsetLocked(h.lineCursor), char...)
+		h.mu.Unlock()
+		ok := h.OnValidateChar(proposed, r)
+		h.mu.Lock()
+		if !ok {
+			h.echoLocked("\a")
+			return
+		}
+	}
+
+	off := h.lineOffsetLocked(h.lineCursor)
+	before := textWidth(h.shownLocked(h.currentLine[:off]))
+	h.growLineLocked(len(char))
+	h.currentLine = slices.Insert(h.currentLine, off, char...)
+	h.charByteLengths = slices.Insert(h.charByteLengths, h.lineCursor, len(char))
+	h.lineCursor++
+	if h.highlightingLocked() {
+		h.rerenderLocked(before)
+		return
+	}
+	tail := h.shownLocked(h.currentLine[off+len(char):])
+	h.echoLocked(string(h.shownLocked(char)) + string(tail) + cursorLeft(textWidth(tail)))
+}
+
+// backspaceLocked deletes the character before the cursor - call only
+// while holding h.mu
+func (h *Handler) backspaceLocked() {
+	if h.lineCursor == 0 {
+		return
+	}
+	end := h.lineOffsetLocked(h.lineCursor)
+	start := end - h.charByteLengths[h.lineCursor-1]
+	before := textWidth(h.shownLocked(h.currentLine[:end]))
+	w := textWidth(h.shownLocked(h.currentLine[start:end]))
+	h.currentLine = slices.Delete(h.currentLine, start, end)
+	h.scrubLineLocked()
+	h.lineCursor--
+	h.charByteLengths = slices.Delete(h.charByteLengths, h.lineCursor, h.lineCursor+1)
+	if h.highlightingLocked() {
+		h.rerenderLocked(before)
+		return
+	}
+	tail := h.shownLocked(h.currentLine[start:])
+	if len(tail) == 0 {
+		if w > 0 {
+			h.echoLocked("\b \b")
+		}
+		return
+	}
+	h.echoLocked(cursorLeft(w) + string(tail) + strings.Repeat(" ", w) + cursorLeft(textWidth(tail)+w))
+}
+
+// deleteLocked deletes the character under the cursor - call only while
+// holding h.mu
+func (h *Handler) deleteLocked() {
+	if h.lineCursor == len(h.charByteLengths) {
+		return
+	}
+	start := h.lineOffsetLocked(h.lineCursor)
+	end := start + h.charByteLengths[h.lineCursor]
+	before := textWidth(h.shownLocked(h.currentLine[:start]))
+	w := textWidth(h.shownLocked(h.currentLine[start:end]))
+	h.currentLine = slices.Delete(h.currentLine, start, end)
+	h.scrubLineLocked()
+	h.charByteLengths = slices.Delete(h.charByteLengths, h.lineCursor, h.lineCursor+1)
+	if h.highlightingLocked() {
+		h.rerenderLocked(before)
+		return
+	}
+	tail := h.shownLocked(h.currentLine[start:])
+	h.echoLocked(string(tail) + strings.Repeat(" ", w) + cursorLeft(textWidth(tail)+w))
+}
+
+// moveCursorLocked moves the cursor to character i (clamped to the line)
+// - call only while holding h.mu
+func (h *Handler) moveCursorLocked(i int) {
+	i = max(0, min(i, len(h.charByteLengths)))
+	from, to := h.lineOffsetLocked(h.lineCursor), h.lineOffsetLocked(i)
+	if to < from {
+		h.echoLocked(cursorLeft(textWidth(h.shownLocked(h.currentLine[to:from]))))
+	} else {
+		h.echoLocked(cursorRight(textWidth(h.shownLocked(h.currentLine[from:to]))))
+	}
+	h.lineCursor = i
+}
+
+// killLineLocked clears the whole line being edited and its echo - call
+// only while holding h.mu
+func (h *Handler) killLineLocked() {
+	if h.lineCursor == len(h.charByteLengths) {
+		// At the end: erase with backspaces, which need no escapes
+		if len(h.shownLocked(h.currentLine)) > 0 {
+			for range h.charByteLengths {
+				h.echoLocked("\b \b")
+			}
+		}
+	} else {
+		h.echoLocked(cursorLeft(textWidth(h.shownLocked(h.currentLine[:h.lineOffsetLocked(h.lineCursor)]))) + "\x1b[K")
+	}
+	h.clearLineLocked()
+}
+
+// cursorLeft returns the escape that moves the cursor n columns left
+func cursorLeft(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("\x1b[%dD", n)
+}
+
+// cursorRight returns the escape that moves the cursor n columns right
+func cursorRight(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("\x1b[%dC", n)
+}
+
+// textWidth returns the columns UTF-8 text takes on the terminal: two for
+// East Asian wide characters, none for combining marks, one otherwise
+func textWidth(b []byte) int {
+	w := 0
+	for _, r := range string(b) {
+		switch {
+		case unicode.In(r, unicode.Mn, unicode.Me) || r == 0x200B:
+			// zero width
+		case isWide(r):
+			w += 2
+		default:
+			w++
+		}
+	}
+	return w
+}
+
+// isWide reports whether r is an East Asian wide or fullwidth character
+func isWide(r rune) bool {
+	return r >= 0x1100 && (r <= 0x115F || // Hangul Jamo
+		(r >= 0x2E80 && r <= 0xA4CF && r != 0x303F) || // CJK ... Yi
+		(r >= 0xAC00 && r <= 0xD7A3) || // Hangul syllables
+		(r >= 0xF900 && r <= 0xFAFF) || // CJK compatibility ideographs
+		(r >= 0xFE30 && r <= 0xFE4F) || // CJK compatibility forms
+		(r >= 0xFF00 && r <= 0xFF60) || // Fullwidth forms
+		(r >= 0xFFE0 && r <= 0xFFE6) ||
+		(r >= 0x1F300 && r <= 0x1F64F) || // Emoji
+		(r >= 0x1F900 && r <= 0x1F9FF) ||
+		(r >= 0x20000 && r <= 0x3FFFD))
+}
+
+// replayPaste continues a paste buffered by PasteLinesBuffer, on the
+// processing goroutine
+func (h *Handler) replayPaste() {
+	h.mu.Lock()
+	rest := h.pasteRest
+	h.pasteRest = nil
+	h.mu.Unlock()
+	if len(rest) > 0 {
+		h.handlePasteLineAssembly(rest)
+	}
+}
@@ -0,0 +1,578 @@
+package keyboard
+
+import (
+	"bytes"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+// expectLines reads the wanted lines from h.Lines, in order
+func expectLines(t *testing.T, h *Handler, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case l := <-h.Lines:
+			if string(l) != w {
+				t.Errorf("line = %q, want %q", l, w)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("line %q never arrived", w)
+		}
+	}
+}
+
+// expectNoLine checks that no further line is delivered
+func expectNoLine(t *testing.T, h *Handler) {
+	t.Helper()
+	select {
+	case l := <-h.Lines:
+		t.Errorf("unexpected line %q", l)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// waitLine waits until the line being edited is want, with the cursor at
+// character cursor
+func waitLine(t *testing.T, h *Handler, want string, cursor int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		h.mu.Lock()
+		line, at := string(h.currentLine), h.lineCursor
+		h.mu.Unlock()
+		if line == want && at == cursor {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("line being edited = %q at %d, want %q at %d", line, at, want, cursor)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// echoBuffer collects echo output written on the processing goroutine
+type echoBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (e *echoBuffer) Write(p []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.buf.Write(p)
+}
+
+// take returns the echo so far and empties the buffer
+func (e *echoBuffer) take() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.buf.String()
+	e.buf.Reset()
+	return s
+}
+
+// TestPasteLinePolicies: each policy's handling of a multi-line paste.
+func TestPasteLinePolicies(t *testing.T) {
+	paste := bracketedPasteStart + "one\r\ntwo\nthree" + bracketedPasteEnd
+
+	t.Run("discard", func(t *testing.T) {
+		h, pw, cleanup := newPipedHandler(t)
+		defer cleanup()
+		h.SetLineMode(true)
+		if _, err := pw.Write([]byte(paste + "x\r")); err != nil {
+			t.Fatal(err)
+		}
+		expectLines(t, h, "one", "x")
+		expectNoLine(t, h)
+	})
+
+	t.Run("submit", func(t *testing.T) {
+		h, pw, cleanup := newPipedHandlerWith(t, Options{PasteLines: PasteLinesSubmit})
+		defer cleanup()
+		h.SetLineMode(true)
+		if _, err := pw.Write([]byte(paste + "x\r")); err != nil {
+			t.Fatal(err)
+		}
+		expectLines(t, h, "one", "two", "threex")
+		expectNoLine(t, h)
+	})
+
+	t.Run("buffer", func(t *testing.T) {
+		h, pw, cleanup := newPipedHandlerWith(t, Options{PasteLines: PasteLinesBuffer})
+		defer cleanup()
+		h.SetLineMode(true)
+		if _, err := pw.Write([]byte(paste)); err != nil {
+			t.Fatal(err)
+		}
+		expectLines(t, h, "one")
+		expectNoLine(t, h)
+		h.SetLineMode(true)
+		expectLines(t, h, "two")
+		h.SetLineMode(true)
+		waitLine(t, h, "three", 5)
+		if _, err := pw.Write([]byte("x\r")); err != nil {
+			t.Fatal(err)
+		}
+		expectLines(t, h, "threex")
+	})
+
+	t.Run("join", func(t *testing.T) {
+		h, pw, cleanup := newPipedHandlerWith(t, Options{PasteLines: PasteLinesJoin, PasteJoin: "; "})
+		defer cleanup()
+		h.SetLineMode(true)
+		if _, err := pw.Write([]byte(paste + "\r")); err != nil {
+			t.Fatal(err)
+		}
+		expectLines(t, h, "one; two; three")
+	})
+}
+
+// TestPasteNewlines: each mode's keys for CR, LF, and CRLF in a paste.
+func TestPasteNewlines(t *testing.T) {
+	paste := bracketedPasteStart + "a\rb\nc\r\nd" + bracketedPasteEnd
+	for _, tc := range []struct {
+		mode PasteNewlineMode
+		want []string
+	}{
+		{PasteNewlinesPreserve, []string{"a", "Enter", "b", "^J", "c", "Enter", "^J", "d"}},
+		{PasteNewlinesLF, []string{"a", "^J", "b", "^J", "c", "^J", "d"}},
+		{PasteNewlinesEnter, []string{"a", "Enter", "b", "Enter", "c", "Enter", "d"}},
+	} {
+		h, pw, cleanup := newPipedHandlerWith(t, Options{PasteNewlines: tc.mode})
+		if _, err := pw.Write([]byte(paste)); err != nil {
+			t.Fatal(err)
+		}
+		expectKeys(t, h, tc.want...)
+		cleanup()
+	}
+}
+
+// TestCursorEditing: with CursorEditing, the cursor moves within the line
+// and mid-line edits are echoed with cursor movement escapes.
+func TestCursorEditing(t *testing.T) {
+	echo := &echoBuffer{}
+	h, pw, cleanup := newPipedHandlerWith(t, Options{CursorEditing: true, EchoWriter: echo})
+	defer cleanup()
+	h.SetLineMode(true)
+
+	for _, tc := range []struct {
+		input, line, echo string
+	}{
+		{"acd\x1b[D\x1b[Db\r", "abcd", "acd\x1b[1D\x1b[1Dbcd\x1b[2D\r\n"},
+		{"xyz\x01\x1b[3~\x05\x7f\r", "y", "xyz\x1b[3Dyz \x1b[3D\x1b[2C\b \b\r\n"},
+		{"12\x1b[D\x7f\r", "2", "12\x1b[1D\x1b[1D2 \x1b[2D\r\n"},
+		{"世界\x1b[Da\x15q\r", "q", "世界\x1b[2Da界\x1b[2D\x1b[3D\x1b[Kq\r\n"},
+	} {
+		if _, err := pw.Write([]byte(tc.input)); err != nil {
+			t.Fatal(err)
+		}
+		expectLines(t, h, tc.line)
+		if got := echo.take(); got != tc.echo {
+			t.Errorf("%q echoed %q, want %q", tc.input, got, tc.echo)
+		}
+	}
+}
+
+// TestCursorEditingOff: without CursorEditing, movement keys are ignored
+// and editing happens at the end of the line.
+func TestCursorEditingOff(t *testing.T) {
+	echo := &echoBuffer{}
+	h, pw, cleanup := newPipedHandlerWith(t, Options{EchoWriter: echo})
+	defer cleanup()
+	h.SetLineMode(true)
+	if _, err := pw.Write([]byte("ab\x1b[Dc\x7f\x7fd\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "ad")
+	if got, want := echo.take(), "abc\b \b\b \bd\r\n"; got != want {
+		t.Errorf("echoed %q, want %q", got, want)
+	}
+}
+
+// TestPromptRedraw: the prompt is echoed for each line, and RedrawLine and
+// Resize render it with the line, the cursor back in place.
+func TestPromptRedraw(t *testing.T) {
+	echo := &echoBuffer{}
+	h, pw, cleanup := newPipedHandlerWith(t, Options{CursorEditing: true, EchoWriter: echo})
+	defer cleanup()
+	h.SetPrompt("> ")
+	h.SetLineMode(true)
+	if _, err := pw.Write([]byte("ab\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "ab")
+	if got, want := echo.take(), "> ab\r\n> "; got != want {
+		t.Errorf("echoed %q, want %q", got, want)
+	}
+
+	if _, err := pw.Write([]byte("xyz\x1b[D")); err != nil {
+		t.Fatal(err)
+	}
+	waitLine(t, h, "xyz", 2)
+	echo.take()
+	h.RedrawLine()
+	if got, want := echo.take(), "\r\x1b[J> xyz\x1b[1D"; got != want {
+		t.Errorf("RedrawLine echoed %q, want %q", got, want)
+	}
+
+	// On a 3-column terminal "> xy" before the cursor wraps once
+	h.Resize(3, 10)
+	if got, want := echo.take(), "\x1b[1A\r\x1b[J> xyz\x1b[1D"; got != want {
+		t.Errorf("Resize echoed %q, want %q", got, want)
+	}
+
+	h.SetPrompt("$ ")
+	if got, want := echo.take(), "\x1b[1A\r\x1b[J$ xyz\x1b[1D"; got != want {
+		t.Errorf("SetPrompt echoed %q, want %q", got, want)
+	}
+}
+
+// TestValidation: OnValidateChar and OnValidateLine reject characters and
+// lines, ringing the bell.
+func TestValidation(t *testing.T) {
+	echo := &echoBuffer{}
+	h, pw, cleanup := newPipedHandlerWith(t, Options{EchoWriter: echo})
+	defer cleanup()
+	// Up to three digits, and at least one to submit
+	h.OnValidateChar = func(line []byte, r rune) bool {
+		return r >= '0' && r <= '9' && len(line) <= 3
+	}
+	h.OnValidateLine = func(line []byte) bool { return len(line) > 0 }
+	h.SetLineMode(true)
+
+	if _, err := pw.Write([]byte("\r1a2345\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "123")
+	expectNoLine(t, h)
+	if got, want := echo.take(), "\a1\a23\a\a\r\n"; got != want {
+		t.Errorf("echoed %q, want %q", got, want)
+	}
+}
+
+// TestMaxLineLength: characters past the limit are refused and reported.
+func TestMaxLineLength(t *testing.T) {
+	echo := &echoBuffer{}
+	h, pw, cleanup := newPipedHandlerWith(t, Options{EchoWriter: echo, MaxLineLength: 3})
+	defer cleanup()
+	var full []rune
+	h.OnLineFull = func(r rune) { full = append(full, r) }
+	h.SetLineMode(true)
+
+	if _, err := pw.Write([]byte("abcd" + bracketedPasteStart + "ef" + bracketedPasteEnd + "\x7fg\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "abg")
+	if got, want := echo.take(), "abc\a\a\a\b \bg\r\n"; got != want {
+		t.Errorf("echoed %q, want %q", got, want)
+	}
+	if string(full) != "def" {
+		t.Errorf("OnLineFull got %q, want %q", string(full), "def")
+	}
+
+	h.SetMaxLineLength(0)
+	if _, err := pw.Write([]byte("abcde\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "abcde")
+}
+
+// TestLineTimeout: a line left idle is delivered partial and flagged, and
+// typing restarts the wait.
+func TestLineTimeout(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{LineTimeout: 100 * time.Millisecond})
+	defer cleanup()
+	timedOut := make(chan string, 1)
+	h.OnLineTimeout = func(partial []byte) { timedOut <- string(partial) }
+	events := h.Subscribe(8, OnlyKinds(EventLine))
+	h.SetLineMode(true)
+
+	for _, c := range "abc" {
+		time.Sleep(60 * time.Millisecond)
+		if _, err := pw.Write([]byte(string(c))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectLines(t, h, "abc")
+	select {
+	case partial := <-timedOut:
+		if partial != "abc" {
+			t.Errorf("OnLineTimeout got %q, want %q", partial, "abc")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnLineTimeout never called")
+	}
+	if ev := nextEvent(t, events); !ev.TimedOut || string(ev.Line) != "abc" {
+		t.Errorf("event = %+v, want TimedOut line \"abc\"", ev)
+	}
+
+	// No new wait starts until the next key
+	expectNoLine(t, h)
+	time.Sleep(100 * time.Millisecond)
+	expectNoLine(t, h)
+	if _, err := pw.Write([]byte("d\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "d")
+	if ev := nextEvent(t, events); ev.TimedOut {
+		t.Errorf("submitted line flagged TimedOut")
+	}
+}
+
+// TestSecret: secret input is masked (or not echoed), kept out of
+// subscriptions, and zeroed from the line buffer.
+func TestSecret(t *testing.T) {
+	echo := &echoBuffer{}
+	h, pw, cleanup := newPipedHandlerWith(t, Options{CursorEditing: true, EchoWriter: echo})
+	defer cleanup()
+	events := h.Subscribe(16)
+	h.SetPrompt("Password: ")
+	h.SetSecret(true, '*')
+	h.SetLineMode(true)
+
+	if _, err := pw.Write([]byte("hunter2\x1b[D\x7f\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "hunte2")
+	if got, want := echo.take(), "Password: *******\x1b[1D\x1b[1D* \x1b[2D\r\nPassword: "; got != want {
+		t.Errorf("echoed %q, want %q", got, want)
+	}
+
+	h.mu.Lock()
+	h.currentLine = make([]byte, 0, 64)
+	h.mu.Unlock()
+	if _, err := pw.Write([]byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	waitLine(t, h, "abc", 3)
+	h.mu.Lock()
+	buf := h.currentLine[:cap(h.currentLine)]
+	h.mu.Unlock()
+	if _, err := pw.Write([]byte("\x7f\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "ab")
+	h.mu.Lock()
+	if n := bytes.Count(buf, []byte{0}); n != len(buf) {
+		t.Errorf("line buffer not zeroed: %q", buf[:3])
+	}
+	h.mu.Unlock()
+	echo.take()
+
+	h.SetSecret(true, 0)
+	if _, err := pw.Write([]byte("xy\x7f\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "x")
+	if got, want := echo.take(), "\r\nPassword: "; got != want {
+		t.Errorf("echoed %q with no mask, want %q", got, want)
+	}
+
+	h.SetSecret(false, 0)
+	if _, err := pw.Write([]byte("z\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "z")
+	for _, want := range []string{"z", "Enter"} {
+		if ev := nextEvent(t, events); ev.Key != want {
+			t.Errorf("subscriber got %+v, want key %q", ev, want)
+		}
+	}
+}
+
+// TestHighlight: with OnHighlight, each edit re-renders the styled line and
+// puts the cursor back.
+func TestHighlight(t *testing.T) {
+	echo := &echoBuffer{}
+	h, pw, cleanup := newPipedHandlerWith(t, Options{CursorEditing: true, EchoWriter: echo})
+	defer cleanup()
+	digits := regexp.MustCompile(`[0-9]+`)
+	h.OnHighlight = func(line []byte) string {
+		return digits.ReplaceAllString(string(line), "\x1b[33m$0\x1b[m")
+	}
+	h.SetLineMode(true)
+
+	if _, err := pw.Write([]byte("a1\x1b[D\x1b[D\x1b[3~2\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "21")
+	want := "a\x1b[K" + // a
+		"\x1b[1Da\x1b[33m1\x1b[m\x1b[K" + // 1
+		"\x1b[1D\x1b[1D" + // Left, Left
+		"\x1b[33m1\x1b[m\x1b[K\x1b[1D" + // Delete
+		"\x1b[33m21\x1b[m\x1b[K\x1b[1D" + // 2
+		"\r\n"
+	if got := echo.take(); got != want {
+		t.Errorf("echoed %q, want %q", got, want)
+	}
+}
+
+// TestAbbreviations: a typed abbreviation expands on Space and Enter, but
+// not inside a word or from a paste.
+func TestAbbreviations(t *testing.T) {
+	echo := &echoBuffer{}
+	h, pw, cleanup := newPipedHandlerWith(t, Options{EchoWriter: echo})
+	defer cleanup()
+	h.Abbreviate("gco", "git checkout")
+	h.AbbreviateFunc("@d", func() string { return "2024-01-02" })
+	h.SetLineMode(true)
+
+	if _, err := pw.Write([]byte("gco main\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "git checkout main")
+	if got, want := echo.take(), "gco\b \b\b \b\b \bgit checkout main\r\n"; got != want {
+		t.Errorf("echoed %q, want %q", got, want)
+	}
+
+	input := "xgco log @d\r" + bracketedPasteStart + "gco " + bracketedPasteEnd + "\r"
+	if _, err := pw.Write([]byte(input)); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "xgco log 2024-01-02", "gco ")
+
+	h.Abbreviate("gco", "")
+	if _, err := pw.Write([]byte("gco\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "gco")
+}
+
+// TestCharClass: characters outside the class are refused and reported.
+func TestCharClass(t *testing.T) {
+	echo := &echoBuffer{}
+	h, pw, cleanup := newPipedHandlerWith(t, Options{EchoWriter: echo, CharClass: ClassDigits})
+	defer cleanup()
+	var rejected []rune
+	h.OnReject = func(r rune) { rejected = append(rejected, r) }
+	h.SetLineMode(true)
+
+	if _, err := pw.Write([]byte("1a2" + bracketedPasteStart + "3-4" + bracketedPasteEnd + "\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "1234")
+	if got, want := echo.take(), "1\a23\a4\r\n"; got != want {
+		t.Errorf("echoed %q, want %q", got, want)
+	}
+	if string(rejected) != "a-" {
+		t.Errorf("OnReject got %q, want %q", string(rejected), "a-")
+	}
+
+	h.SetCharClass(ClassHex)
+	if _, err := pw.Write([]byte("bEg\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "bE")
+
+	h.SetCharClass(ClassSet("yn"))
+	if _, err := pw.Write([]byte("xy\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "y")
+}
+
+// completeWords is an OnComplete that completes the last word from words
+func completeWords(words ...string) func([]byte, int) ([]string, int) {
+	return func(line []byte, cursor int) ([]string, int) {
+		from := bytes.LastIndexByte(line[:cursor], ' ') + 1
+		var out []string
+		for _, w := range words {
+			if bytes.HasPrefix([]byte(w), line[from:cursor]) {
+				out = append(out, w)
+			}
+		}
+		return out, from
+	}
+}
+
+// TestCompletion: Tab completes a single candidate or the candidates'
+// common prefix, and rings the bell when there is nothing to add.
+func TestCompletion(t *testing.T) {
+	echo := &echoBuffer{}
+	h, pw, cleanup := newPipedHandlerWith(t, Options{EchoWriter: echo})
+	defer cleanup()
+	h.OnComplete = completeWords("commit", "config", "status")
+	h.SetLineMode(true)
+
+	if _, err := pw.Write([]byte("git st\t co\tn\t\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "git status config")
+	if got, want := echo.take(), "git st\b \b\b \bstatus co\an\b \b\b \b\b \bconfig\r\n"; got != want {
+		t.Errorf("echoed %q, want %q", got, want)
+	}
+}
+
+// TestCompletionMenu: candidates with nothing in common open a menu below
+// the line, navigated with Tab and arrows.
+func TestCompletionMenu(t *testing.T) {
+	echo := &echoBuffer{}
+	h, pw, cleanup := newPipedHandlerWith(t, Options{EchoWriter: echo, CompletionMenu: true})
+	defer cleanup()
+	h.OnComplete = completeWords("add", "branch", "commit")
+	h.SetPrompt("> ")
+	h.SetLineMode(true)
+	echo.take()
+
+	if _, err := pw.Write([]byte("\t")); err != nil {
+		t.Fatal(err)
+	}
+	waitMenu := func(sel int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			h.mu.Lock()
+			ok := h.menu != nil && h.menu.sel == sel
+			h.mu.Unlock()
+			if ok {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("menu with selection %d never appeared", sel)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitMenu(0)
+	if got, want := echo.take(), "\r\n\x1b[K\x1b[7madd\x1b[m\r\n\x1b[Kbranch\r\n\x1b[Kcommit\x1b[J\x1b[3A\x1b[3G"; got != want {
+		t.Errorf("menu echoed %q, want %q", got, want)
+	}
+
+	if _, err := pw.Write([]byte("\t\t\x1b[A")); err != nil {
+		t.Fatal(err)
+	}
+	waitMenu(1)
+	echo.take()
+	if _, err := pw.Write([]byte("\r")); err != nil {
+		t.Fatal(err)
+	}
+	waitLine(t, h, "branch", 6)
+	if got, want := echo.take(), "\x1b[1B\r\x1b[J\x1b[1A\x1b[3Gbranch"; got != want {
+		t.Errorf("taking the selection echoed %q, want %q", got, want)
+	}
+	if _, err := pw.Write([]byte(" x\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "branch x")
+}
+
+// TestLineDelimiters: extra delimiters end lines alongside Enter, kept or
+// not, as typed but not in pastes.
+func TestLineDelimiters(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{LineDelimiters: []string{";", "^D"}})
+	defer cleanup()
+	h.SetLineMode(true)
+
+	if _, err := pw.Write([]byte("a;b\x04c\r" + bracketedPasteStart + "d;e" + bracketedPasteEnd + ";")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "a", "b", "c", "d;e")
+
+	h.SetLineDelimiters([]string{";", "^D"}, true)
+	if _, err := pw.Write([]byte("f;g\x04h\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "f;", "g\x04", "h")
+}
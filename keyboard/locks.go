@@ -0,0 +1,37 @@
+package keyboard
+
+// LockState is the Caps Lock and Num Lock state last reported by the
+// terminal. Only the kitty keyboard protocol reports it, with every key
+// (CSI u); other terminals leave it unknown.
+type LockState struct {
+	CapsLock bool
+	NumLock  bool
+	Known    bool // False until a key has reported the state
+}
+
+// Bits of Handler.locks
+const (
+	lockCaps uint32 = 1 << iota
+	lockNum
+	lockKnown
+)
+
+// LockState returns the lock state reported with the most recent kitty
+// protocol key, e.g. to warn "Caps Lock is on" in a password prompt.
+func (h *Handler) LockState() LockState {
+	l := h.locks.Load()
+	return LockState{CapsLock: l&lockCaps != 0, NumLock: l&lockNum != 0, Known: l&lockKnown != 0}
+}
+
+// noteLocks records the lock bits of a kitty modifier parameter (1-based:
+// Caps Lock is bit 64 and Num Lock bit 128 of mod-1)
+func (h *Handler) noteLocks(mod int) {
+	l := lockKnown
+	if (mod-1)&64 != 0 {
+		l |= lockCaps
+	}
+	if (mod-1)&128 != 0 {
+		l |= lockNum
+	}
+	h.locks.Store(l)
+}
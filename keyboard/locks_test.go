@@ -0,0 +1,41 @@
+package keyboard
+
+import (
+	"testing"
+	"time"
+)
+
+// TestLockState: the kitty lock bits are tracked and carried on KeyEvents,
+// without changing the key names.
+func TestLockState(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{EventChannel: true})
+	defer cleanup()
+	if got := h.LockState(); got.Known {
+		t.Errorf("lock state %+v known before any kitty key", got)
+	}
+
+	// 'a' with Caps Lock (64) and Num Lock (128): modifiers 1+64+128
+	if _, err := pw.Write([]byte("\x1b[97;193u")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "a")
+	select {
+	case ev := <-h.Events:
+		if !ev.CapsLock || !ev.NumLock {
+			t.Errorf("event = %+v, want Caps Lock and Num Lock on", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no KeyEvent arrived")
+	}
+	if got := h.LockState(); got != (LockState{CapsLock: true, NumLock: true, Known: true}) {
+		t.Errorf("LockState() = %+v", got)
+	}
+
+	if _, err := pw.Write([]byte("\x1b[97u")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "a")
+	if got := h.LockState(); got != (LockState{Known: true}) {
+		t.Errorf("LockState() after a plain key = %+v", got)
+	}
+}
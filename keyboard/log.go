@@ -0,0 +1,79 @@
+package keyboard
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Log levels used by the handler:
+//
+//	Debug - raw input bytes and every parsed key (high volume)
+//	Info  - state transitions: raw mode, start/stop, paste, suspend, release
+//	Warn  - lost or undecodable input: channel overflow, unknown sequences
+//	Error - input read failures
+
+// debug logs a structured event at Debug level
+func (h *Handler) debug(msg string, args ...any) {
+	h.logAt(slog.LevelDebug, msg, args...)
+}
+
+// logAt logs a structured event at the given level
+func (h *Handler) logAt(level slog.Level, msg string, args ...any) {
+	if h.logger == nil {
+		return
+	}
+	h.logger.Log(context.Background(), level, msg, args...)
+}
+
+// debugFnHandler adapts the legacy DebugFn(string) callback to slog. Every
+// record, at every level, is flattened to "msg key=value ..." and passed on.
+type debugFnHandler struct {
+	fn     func(string)
+	prefix string // pre-rendered attrs from WithAttrs
+	group  string
+}
+
+func (d *debugFnHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (d *debugFnHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString(r.Message)
+	b.WriteString(d.prefix)
+	r.Attrs(func(a slog.Attr) bool {
+		d.writeAttr(&b, a)
+		return true
+	})
+	d.fn(b.String())
+	return nil
+}
+
+func (d *debugFnHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	b.WriteString(d.prefix)
+	for _, a := range attrs {
+		d.writeAttr(&b, a)
+	}
+	return &debugFnHandler{fn: d.fn, prefix: b.String(), group: d.group}
+}
+
+func (d *debugFnHandler) WithGroup(name string) slog.Handler {
+	return &debugFnHandler{fn: d.fn, prefix: d.prefix, group: d.group + name + "."}
+}
+
+// writeAttr renders one attribute as " key=value"
+func (d *debugFnHandler) writeAttr(b *strings.Builder, a slog.Attr) {
+	v := a.Value.Resolve()
+	if bs, ok := v.Any().([]byte); ok {
+		fmt.Fprintf(b, " %s%s=%q", d.group, a.Key, bs)
+		return
+	}
+	if v.Kind() == slog.KindString {
+		fmt.Fprintf(b, " %s%s=%q", d.group, a.Key, v.String())
+		return
+	}
+	fmt.Fprintf(b, " %s%s=%v", d.group, a.Key, v.Any())
+}
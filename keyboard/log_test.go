@@ -0,0 +1,38 @@
+package keyboard
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDebugFnReceivesStructuredEvents: the legacy DebugFn still sees every
+// event, with attributes flattened into the message.
+func TestDebugFnReceivesStructuredEvents(t *testing.T) {
+	msgs := make(chan string, 16)
+	_, pw, cleanup := newPipedHandlerWith(t, Options{
+		DebugFn: func(s string) { msgs <- s },
+	})
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{`Raw input bytes="a"`, `Key key="a"`}
+	deadline := time.After(2 * time.Second)
+	for len(want) > 0 {
+		select {
+		case m := <-msgs:
+			if !strings.HasPrefix(m, "Raw input") && !strings.HasPrefix(m, "Key") {
+				continue // lifecycle events
+			}
+			if m != want[0] {
+				t.Fatalf("debug message = %q, want %q", m, want[0])
+			}
+			want = want[1:]
+		case <-deadline:
+			t.Fatalf("missing debug messages %q", want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package keyboard
+
+import "time"
+
+// EventKind says what an Event carries.
+type EventKind int
+
+const (
+	EventKey   EventKind = iota // A key (Key)
+	EventLine                   // A completed line in line mode (Line)
+	EventMouse                  // A mouse report (Mouse)
+	EventPaste                  // A complete bracketed paste (Paste)
+)
+
+// Event is a parsed input event: a key on its way through the middleware
+// chain (which only ever sees EventKey), or any kind of event on a
+// subscription channel (see Subscribe).
+type Event struct {
+	Kind EventKind
+	Key  string    // Key name, as delivered on Keys ("a", "M-x", "F1", ...)
+	Time time.Time // When the event was parsed
+
+	Line  []byte     // EventLine
+	Mouse MouseEvent // EventMouse
+	Paste []byte     // EventPaste
+
+	// TimedOut marks an EventLine cut short by the line timeout
+	TimedOut bool
+}
+
+// Middleware is one layer between the parser and delivery (OnKey, line
+// assembly, and the Keys channel). It receives each event and the rest of the
+// chain as next: call next(ev) to pass the event on (possibly rewritten),
+// skip it to swallow the event, or call it several times to expand one key
+// into many. Layers compose, so remapping, logging, rate limiting, and macro
+// recording can each be written independently.
+//
+// Middleware runs on the handler's processing goroutine and must not block.
+type Middleware func(ev Event, next func(Event))
+
+// Use appends middleware to the chain. The first layer added sees events
+// first; the last one added is closest to delivery.
+func (h *Handler) Use(mw ...Middleware) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// Copy so a chain already being run by emitKey is never modified
+	var old []Middleware
+	if p := h.middleware.Load(); p != nil {
+		old = *p
+	}
+	chain := make([]Middleware, 0, len(old)+len(mw))
+	chain = append(chain, old...)
+	chain = append(chain, mw...)
+	h.middleware.Store(&chain)
+}
+
+// runMiddleware passes ev through mws and then delivers it
+func (h *Handler) runMiddleware(mws []Middleware, ev Event) {
+	if len(mws) == 0 {
+		h.deliverKey(ev.Key)
+		return
+	}
+	mws[0](ev, func(e Event) {
+		h.runMiddleware(mws[1:], e)
+	})
+}
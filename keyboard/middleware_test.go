@@ -0,0 +1,44 @@
+package keyboard
+
+import (
+	"testing"
+	"time"
+)
+
+// TestMiddlewareChain: layers run in the order added, can rewrite and swallow
+// events, and can expand one event into several.
+func TestMiddlewareChain(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+
+	// Remap "a" to "b", then swallow "x", then double every key.
+	h.Use(func(ev Event, next func(Event)) {
+		if ev.Key == "a" {
+			ev.Key = "b"
+		}
+		next(ev)
+	}, func(ev Event, next func(Event)) {
+		if ev.Key != "x" {
+			next(ev)
+		}
+	})
+	h.Use(func(ev Event, next func(Event)) {
+		next(ev)
+		next(ev)
+	})
+
+	if _, err := pw.Write([]byte("axc")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"b", "b", "c", "c"} {
+		select {
+		case k := <-h.Keys:
+			if k != want {
+				t.Errorf("key = %q, want %q", k, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("key %q never arrived", want)
+		}
+	}
+}
@@ -0,0 +1,546 @@
+package keyboard
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// Mouse reporting mode sequences. Tracking modes select which events are
+// reported; an encoding mode (SGR is the one to use) selects the format.
+// Combine them in Options.EnterModes / ExitModes.
+const (
+	MouseTrackingOn  = "\x1b[?1000h" // Button press/release and wheel
+	MouseTrackingOff = "\x1b[?1000l"
+
+	MouseButtonMotionOn  = "\x1b[?1002h" // Plus motion while a button is held (drag)
+	MouseButtonMotionOff = "\x1b[?1002l"
+
+	// MouseAnyMotionOn also reports motion with no button held, delivered
+	// as MouseMotion@x,y keys, for hover effects. Chatty; see also the
+	// ButtonMotion mode and Options.MotionRate.
+	MouseAnyMotionOn  = "\x1b[?1003h"
+	MouseAnyMotionOff = "\x1b[?1003l"
+
+	MouseSGROn  = "\x1b[?1006h" // SGR encoding: ESC [ < b ; x ; y M/m
+	MouseSGROff = "\x1b[?1006l"
+
+	// MouseURXVTOn is the urxvt encoding (ESC [ b ; x ; y M, decimal) for
+	// terminals that lack SGR. Releases don't say which button.
+	MouseURXVTOn  = "\x1b[?1015h"
+	MouseURXVTOff = "\x1b[?1015l"
+
+	// MouseSGRPixelsOn is SGR encoding with pixel instead of cell
+	// coordinates. Tell the handler with SetMousePixels(true).
+	MouseSGRPixelsOn  = "\x1b[?1016h"
+	MouseSGRPixelsOff = "\x1b[?1016l"
+)
+
+// MouseAction is what happened in a MouseEvent.
+type MouseAction int
+
+const (
+	MousePress     MouseAction = iota // Button pressed
+	MouseRelease                      // Button released
+	MouseDrag                         // Motion with a button held
+	MouseDragStart                    // First drag motion; X, Y are where the button was pressed
+	MouseDragEnd                      // Release that ends a drag
+	MouseMove                         // Motion with no button held (any-motion tracking)
+	MouseScroll                       // Wheel step(s); the Button is the direction
+	MouseLongPress                    // Button held still for Options.LongPress
+)
+
+// MouseButton identifies the button (or wheel direction) of a MouseEvent.
+type MouseButton int
+
+const (
+	MouseButtonNone MouseButton = iota // Unknown (urxvt and X10 releases) or none (MouseMove)
+	MouseButtonLeft
+	MouseButtonMiddle
+	MouseButtonRight
+	MouseWheelUp
+	MouseWheelDown
+	MouseWheelLeft
+	MouseWheelRight
+	MouseButtonBack    // Button 8
+	MouseButtonForward // Button 9
+	MouseButton10
+	MouseButton11
+)
+
+// MouseEvent is a decoded mouse report, delivered on the Mouse channel when
+// Options.MouseChannel is set.
+type MouseEvent struct {
+	Action MouseAction
+	Button MouseButton
+	X, Y   int // Cell position (see Options.MouseZeroBased)
+
+	Shift, Alt, Ctrl bool
+
+	// Count is the number of wheel steps a coalesced MouseScroll stands for
+	// (see Options.ScrollCoalesce); 1 otherwise
+	Count int
+
+	// Region is the hit region a MousePress landed in, if any (SetRegion)
+	Region string
+
+	// PixelX and PixelY are the pixel position in SGR-Pixels mode
+	// (SetMousePixels), otherwise 0
+	PixelX, PixelY int
+}
+
+// newMouseEvent builds a MouseEvent from a decoded report's button code
+// and cell position
+func newMouseEvent(cb, cx, cy int, isRelease bool) MouseEvent {
+	ev := MouseEvent{
+		Button: mouseButtonOf(cb),
+		X:      cx,
+		Y:      cy,
+		Shift:  cb&4 != 0,
+		Alt:    cb&8 != 0,
+		Ctrl:   cb&16 != 0,
+		Count:  1,
+	}
+	switch {
+	case cb&64 != 0 && cb&128 == 0:
+		ev.Action = MouseScroll
+	case cb&32 != 0 && ev.Button == MouseButtonNone:
+		ev.Action = MouseMove
+	case cb&32 != 0:
+		ev.Action = MouseDrag
+	case isRelease:
+		ev.Action = MouseRelease
+	default:
+		ev.Action = MousePress
+	}
+	return ev
+}
+
+// mouseButtonOf decodes the button from a button code
+func mouseButtonOf(cb int) MouseButton {
+	bits := MouseButton(cb & 3)
+	switch {
+	case cb&128 != 0:
+		return MouseButtonBack + bits
+	case cb&64 != 0:
+		return MouseWheelUp + bits
+	case bits == 3:
+		return MouseButtonNone
+	}
+	return MouseButtonLeft + bits
+}
+
+// emitMouse formats a decoded mouse report and emits it, on the Mouse
+// channel or as legacy keys: for drag and
+// motion a single key with the position embedded, otherwise the position
+// key followed by the action key. In pixel mode a MousePixel@x,y key comes
+// first, and the cell coordinates are derived from the cell size. Cell
+// coordinates are clamped to the terminal size and shifted to the
+// configured origin.
+func (h *Handler) emitMouse(cb, cx, cy int, isRelease bool) {
+	if h.simple {
+		return
+	}
+	h.mu.Lock()
+	pixels, cw, ch := h.mousePixels, h.cellWidth, h.cellHeight
+	origin, cols, rows := h.mouseOrigin, h.termCols, h.termRows
+	off := h.mouseOff
+	h.mu.Unlock()
+
+	if off {
+		return
+	}
+
+	var px, py int
+	if pixels {
+		px, py = cx, cy
+		if h.Mouse == nil {
+			h.emitKey(fmt.Sprintf("MousePixel@%d,%d", cx, cy))
+		}
+		if cw > 0 && ch > 0 {
+			cx, cy = pixelToCell(cx, cw), pixelToCell(cy, ch)
+		}
+	}
+	if !pixels || (cw > 0 && ch > 0) {
+		cx = clampCoord(cx, cols) - 1 + origin
+		cy = clampCoord(cy, rows) - 1 + origin
+	}
+
+	posKey, actionKey, ok := formatMouseEvent(cb, cx, cy, isRelease)
+	if !ok {
+		return
+	}
+	ev := newMouseEvent(cb, cx, cy, isRelease)
+	ev.PixelX, ev.PixelY = px, py
+
+	// Scroll coalescing: gather a burst of identical scroll events into one
+	if cb&64 != 0 && h.scrollCoalesce > 0 {
+		h.coalesceScroll(ev, posKey, actionKey)
+		return
+	}
+
+	// Hover throttling
+	if ev.Action == MouseMove && h.motionInterval > 0 {
+		h.throttleMotion(ev, actionKey)
+		return
+	}
+
+	// Drag tracking: a press arms it, the first motion with the same button
+	// held starts the drag, and the release ends it. Motion with no press
+	// seen (e.g. one made before the handler started) starts no drag.
+	button := cb & 3
+	isMotion := cb&32 != 0
+	var dragEnd string
+	if cb&(64|128) == 0 {
+		switch {
+		case isMotion && button != 3:
+			if abs(cx-h.pressX) > 1 || abs(cy-h.pressY) > 1 {
+				h.longPressGen++
+			}
+			if h.pressed && button == h.pressButton && !h.dragging {
+				h.dragging = true
+				start := ev
+				start.Action, start.X, start.Y = MouseDragStart, h.pressX, h.pressY
+				h.emitMouseEvent(start, fmt.Sprintf("%sMouse%sDragStart@%d,%d", mouseModifierPrefix(cb), mouseButtonName(button), h.pressX, h.pressY))
+			}
+		case isRelease:
+			h.longPressGen++
+			h.pressed = false
+			if h.dragging {
+				dragEnd = fmt.Sprintf("%sMouse%sDragEnd@%d,%d", mouseModifierPrefix(cb), mouseButtonName(h.pressButton), cx, cy)
+				h.dragging = false
+			}
+		case !isMotion:
+			h.pressButton, h.pressX, h.pressY = button, cx, cy
+			h.pressed, h.dragging = true, false
+			h.armLongPress(ev, fmt.Sprintf("%sMouse%sLongPress@%d,%d", mouseModifierPrefix(cb), mouseButtonName(button), cx, cy))
+		}
+	}
+
+	// For drag events, posKey is empty and position is in actionKey
+	// For other events, emit position first, then action
+	keys := []string{actionKey}
+	if posKey != "" {
+		keys = []string{posKey, actionKey}
+	}
+	if ev.Action == MousePress && cb&64 == 0 {
+		if name, ok := h.regionAt(cx, cy); ok {
+			ev.Region = name
+			keys = append(keys, "Region:"+name+":"+actionKey)
+		}
+	}
+	h.emitMouseEvent(ev, keys...)
+	if dragEnd != "" {
+		end := ev
+		end.Action, end.Button = MouseDragEnd, mouseButtonOf(h.pressButton)
+		h.emitMouseEvent(end, dragEnd)
+	}
+}
+
+// emitMouseEvent publishes a mouse event to subscribers and delivers it: on
+// the Mouse channel if it exists, otherwise as its legacy keys
+func (h *Handler) emitMouseEvent(ev MouseEvent, keys ...string) {
+	if h.rateLimits != nil && h.limitKey(mouseActionKey(keys), true) {
+		h.debug("Mouse rate limited", "event", ev)
+		return
+	}
+	if h.sink != nil {
+		h.sink(Event{Kind: EventMouse, Mouse: ev})
+		return
+	}
+	h.audit(Event{Kind: EventMouse, Mouse: ev}, false)
+	h.publish(Event{Kind: EventMouse, Mouse: ev})
+	if h.Mouse == nil {
+		for _, k := range keys {
+			h.emitKey(k)
+		}
+		return
+	}
+
+	// A pending scroll burst, throttled motion, repeat burst, or chord key
+	// goes out first, as in emitKey
+	if h.chordFirst != "" {
+		h.flushChord()
+	}
+	if h.repeatCount > 0 {
+		h.flushRepeat()
+	}
+	if h.scrollCount > 0 {
+		h.flushScroll()
+	}
+	if h.motionPending != "" {
+		h.flushMotion()
+	}
+	h.debug("Mouse", "event", ev)
+	sendWithPolicy(h.Mouse, ev, h.keyOverflow, h.stopChan, h.mouseDropped)
+}
+
+// mouseDropped reports a mouse event lost to Mouse channel overflow
+func (h *Handler) mouseDropped(ev MouseEvent) {
+	h.logAt(slog.LevelWarn, "Mouse channel full, event dropped", "event", ev)
+}
+
+// armLongPress starts the long-press timer for a button press; the key is
+// emitted unless a release, larger movement, or new press cancels it first
+func (h *Handler) armLongPress(ev MouseEvent, key string) {
+	h.longPressGen++
+	if h.longPress <= 0 {
+		return
+	}
+	gen := h.longPressGen
+	h.after(h.longPress, func() {
+		if h.longPressGen == gen {
+			ev.Action = MouseLongPress
+			h.emitMouseEvent(ev, key)
+		}
+	})
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// throttleMotion emits a hover motion key if the throttle interval has
+// passed since the last one, otherwise holds it back (replacing any motion
+// already held) until the interval is up.
+func (h *Handler) throttleMotion(ev MouseEvent, key string) {
+	wait := h.motionInterval - time.Since(h.motionLast)
+	if wait <= 0 && h.motionPending == "" {
+		h.motionLast = time.Now()
+		h.emitMouseEvent(ev, key)
+		return
+	}
+	pending := h.motionPending != ""
+	h.motionPending, h.motionEvent = key, ev
+	if pending {
+		return
+	}
+	h.motionGen++
+	gen := h.motionGen
+	h.after(wait, func() {
+		if h.motionGen == gen {
+			h.flushMotion()
+		}
+	})
+}
+
+// flushMotion emits the held-back hover motion key
+func (h *Handler) flushMotion() {
+	key := h.motionPending
+	h.motionPending = ""
+	h.motionGen++
+	h.motionLast = time.Now()
+	h.emitMouseEvent(h.motionEvent, key)
+}
+
+// mouseButtonName names a mouse button from the low button bits
+func mouseButtonName(button int) string {
+	switch button {
+	case 0:
+		return "Left"
+	case 1:
+		return "Middle"
+	case 2:
+		return "Right"
+	}
+	return ""
+}
+
+// mouseModifierPrefix returns the key prefix for the modifier bits of a
+// mouse button code
+func mouseModifierPrefix(cb int) string {
+	prefix := ""
+	if cb&4 != 0 {
+		prefix += "S-"
+	}
+	if cb&8 != 0 {
+		prefix += "M-"
+	}
+	if cb&16 != 0 {
+		prefix += "C-"
+	}
+	return prefix
+}
+
+// pixelToCell converts a 1-based pixel coordinate to a 1-based cell
+// coordinate
+func pixelToCell(p, cellSize int) int {
+	if p < 1 {
+		return 1
+	}
+	return (p-1)/cellSize + 1
+}
+
+// SetMouseEnabled turns mouse events on or off. While off, mouse reports
+// are consumed without emitting keys, and if the handler is writing
+// MouseEnterModes it sends MouseExitModes so the terminal stops reporting
+// and native text selection works again; turning it back on re-sends
+// MouseEnterModes. Mouse events are enabled by default.
+func (h *Handler) SetMouseEnabled(enabled bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.mouseOff == !enabled {
+		return
+	}
+	h.mouseOff = !enabled
+	if !h.terminalActive || h.modeWriter == nil {
+		return
+	}
+	if enabled && h.mouseEnterModes != "" {
+		h.modeWriter.Write([]byte(h.mouseEnterModes))
+	} else if !enabled && h.mouseExitModes != "" {
+		h.modeWriter.Write([]byte(h.mouseExitModes))
+	}
+}
+
+// MouseEnabled reports whether mouse events are enabled.
+func (h *Handler) MouseEnabled() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return !h.mouseOff
+}
+
+// clampCoord clamps a 1-based cell coordinate to 1..limit (no upper bound
+// if limit is 0)
+func clampCoord(c, limit int) int {
+	if limit > 0 && c > limit {
+		c = limit
+	}
+	if c < 1 {
+		c = 1
+	}
+	return c
+}
+
+// SetTerminalSize sets the terminal size in cells used to clamp mouse
+// coordinates, e.g. from a resize handler. When the handler manages a
+// terminal it reads the size itself on entering raw mode, but cannot see
+// later resizes.
+func (h *Handler) SetTerminalSize(cols, rows int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.termCols, h.termRows = cols, rows
+	h.sizeExplicit = true
+}
+
+// Resize reports a terminal size change, e.g. from SIGWINCH or an SSH
+// window-change request: it sets the size as SetTerminalSize does and calls
+// OnResize. In line mode, it first redraws the prompt and line (see
+// RedrawLine). It runs OnResize on the caller's goroutine.
+func (h *Handler) Resize(cols, rows int) {
+	h.SetTerminalSize(cols, rows)
+	h.RedrawLine()
+	if h.OnResize != nil {
+		h.OnResize(cols, rows)
+	}
+}
+
+// TerminalFd returns the file descriptor of the terminal the handler
+// manages (puts in raw mode), if any, e.g. to watch it for size changes.
+func (h *Handler) TerminalFd() (int, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.terminalFd, h.managesTerminal
+}
+
+// TerminalSize returns the terminal size in cells (0, 0 if unknown).
+func (h *Handler) TerminalSize() (cols, rows int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.termCols, h.termRows
+}
+
+// SetMousePixels tells the handler whether SGR mouse reports carry pixel
+// coordinates (mode 1016, MouseSGRPixelsOn). In pixel mode every mouse
+// report also emits MousePixel@x,y, and the usual Mouse keys carry cell
+// coordinates derived from the cell size (see QueryCellSize and
+// SetCellSize); while the cell size is unknown they carry pixels.
+func (h *Handler) SetMousePixels(enabled bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.mousePixels = enabled
+}
+
+// SetCellSize sets the size of a character cell in pixels, used to derive
+// cell coordinates in pixel mouse mode.
+func (h *Handler) SetCellSize(width, height int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cellWidth, h.cellHeight = width, height
+}
+
+// CellSize returns the cell size in pixels (0, 0 if unknown).
+func (h *Handler) CellSize() (width, height int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.cellWidth, h.cellHeight
+}
+
+// QueryCellSize asks the terminal for its character cell size in pixels
+// (CSI 16 t), remembers it for pixel mouse mode, and returns it. Requires
+// ModeWriter; must not be called from a handler callback.
+func (h *Handler) QueryCellSize() (width, height int, err error) {
+	ch, cancel := h.expectReply(replyCellSize)
+	defer cancel()
+
+	if err := h.sendQuery("\x1b[16t"); err != nil {
+		return 0, 0, err
+	}
+	r, err := h.awaitReply(ch)
+	if err != nil {
+		return 0, 0, err
+	}
+	height, _ = strconv.Atoi(r.params[1])
+	width, _ = strconv.Atoi(r.params[2])
+	h.SetCellSize(width, height)
+	return width, height, nil
+}
+
+// coalesceScroll adds a scroll event to the pending burst. A different
+// direction or modifier set flushes the burst first; the burst is emitted
+// when its window, which starts at the first event, closes.
+func (h *Handler) coalesceScroll(ev MouseEvent, posKey, actionKey string) {
+	if h.scrollCount > 0 && h.scrollAction != actionKey {
+		h.flushScroll()
+	}
+	h.scrollPos, h.scrollEvent = posKey, ev
+	h.scrollCount++
+	if h.scrollCount == 1 {
+		h.scrollAction = actionKey
+		h.scrollGen++
+		gen := h.scrollGen
+		h.after(h.scrollCoalesce, func() {
+			if h.scrollGen == gen {
+				h.flushScroll()
+			}
+		})
+	}
+}
+
+// flushScroll emits the pending scroll burst as the last position followed
+// by the action, suffixed with ":N" when it stands for N > 1 steps (after
+// the acceleration curve, if any)
+func (h *Handler) flushScroll() {
+	if h.scrollCount == 0 {
+		return
+	}
+	count, posKey, actionKey := h.scrollCount, h.scrollPos, h.scrollAction
+	h.scrollCount = 0
+	h.scrollGen++
+	if h.scrollAccel != nil {
+		if n := h.scrollAccel(count); n > 0 {
+			count = n
+		}
+	}
+	if count > 1 {
+		actionKey += ":" + strconv.Itoa(count)
+	}
+	ev := h.scrollEvent
+	ev.Count = count
+	h.emitMouseEvent(ev, posKey, actionKey)
+}
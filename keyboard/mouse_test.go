@@ -0,0 +1,206 @@
+package keyboard
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// expectKeys reads keys from h.Keys and compares them with want in order.
+func expectKeys(t *testing.T, h *Handler, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case k := <-h.Keys:
+			if k != w {
+				t.Errorf("key = %q, want %q", k, w)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("key %q never arrived", w)
+		}
+	}
+}
+
+// TestMousePixels: in pixel mode the report's pixel position is emitted and
+// the usual keys carry cell coordinates derived from the cell size.
+func TestMousePixels(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+	h.SetMousePixels(true)
+	h.SetCellSize(10, 20)
+
+	if _, err := pw.Write([]byte("\x1b[<0;25;45M")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "MousePixel@25,45", "Mouse@3,3", "MouseLeftPress")
+}
+
+// TestMouseZeroBased: with MouseZeroBased the top-left cell is 0,0, and
+// coordinates beyond the known terminal size are clamped to its last cell.
+func TestMouseZeroBased(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{MouseZeroBased: true})
+	defer cleanup()
+	h.SetTerminalSize(80, 24)
+
+	if _, err := pw.Write([]byte("\x1b[<0;1;1M\x1b[<0;95;30M")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "Mouse@0,0", "MouseLeftPress", "Mouse@79,23", "MouseLeftPress")
+}
+
+// TestSetMouseEnabled: turning the mouse off pops the mouse modes and drops
+// reports; turning it back on re-pushes them and events flow again.
+func TestSetMouseEnabled(t *testing.T) {
+	var modes bytes.Buffer
+	h, pw, cleanup := newPipedHandlerWith(t, Options{
+		ModeWriter:      &modes,
+		EnterModes:      "<enter>",
+		ExitModes:       "<exit>",
+		MouseEnterModes: "<mouse>",
+		MouseExitModes:  "<nomouse>",
+	})
+	defer cleanup()
+
+	h.SetMouseEnabled(false)
+	if _, err := pw.Write([]byte("\x1b[<0;1;1Ma")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "a")
+
+	h.SetMouseEnabled(true)
+	if _, err := pw.Write([]byte("\x1b[<0;2;3M")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "Mouse@2,3", "MouseLeftPress")
+
+	if got, want := modes.String(), "<enter><mouse><nomouse><mouse>"; got != want {
+		t.Errorf("mode output = %q, want %q", got, want)
+	}
+}
+
+// TestMouseURXVT: the urxvt (1015) encoding decodes like X10 with decimal
+// parameters.
+func TestMouseURXVT(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("\x1b[32;120;40M\x1b[35;120;40M")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "Mouse@120,40", "MouseLeftPress", "Mouse@120,40", "MouseRelease")
+}
+
+// TestDragStartEnd: the first motion after a press emits DragStart at the
+// press position, and the release after a drag emits DragEnd.
+func TestDragStartEnd(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("\x1b[<0;5;5M\x1b[<32;6;5M\x1b[<32;7;5M\x1b[<0;7;5m")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h,
+		"Mouse@5,5", "MouseLeftPress",
+		"MouseLeftDragStart@5,5", "MouseLeftDrag@6,5",
+		"MouseLeftDrag@7,5",
+		"Mouse@7,5", "MouseLeftRelease", "MouseLeftDragEnd@7,5",
+	)
+}
+
+// TestDragWithoutPress: motion with a button held but no press seen starts
+// no drag, and neither does motion with a different button from the press.
+func TestDragWithoutPress(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("\x1b[<32;3;4M\x1b[<2;5;5M\x1b[<32;6;5M\x1b[<34;7;5M")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h,
+		"MouseLeftDrag@3,4",
+		"Mouse@5,5", "MouseRightPress",
+		"MouseLeftDrag@6,5",
+		"MouseRightDragStart@5,5", "MouseRightDrag@7,5",
+	)
+}
+
+// TestScrollCoalesce: a burst of scroll steps becomes one event with a count;
+// a change of direction starts a new burst.
+func TestScrollCoalesce(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{ScrollCoalesce: 30 * time.Millisecond})
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("\x1b[<65;3;4M\x1b[<65;3;4M\x1b[<65;3;5M\x1b[<64;3;5M")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "Mouse@3,5", "MouseScrollDown:3", "Mouse@3,5", "MouseScrollUp")
+}
+
+// TestMotionRate: hover motion beyond the rate is collapsed, and the latest
+// position still arrives once the interval is up.
+func TestMotionRate(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{MotionRate: 20})
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("\x1b[<35;1;1M\x1b[<35;2;2M\x1b[<35;3;3M")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "MouseMotion@1,1", "MouseMotion@3,3")
+	select {
+	case k := <-h.Keys:
+		t.Errorf("unexpected key %q", k)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestLongPress: a button held still past the threshold emits LongPress;
+// one that moves away first does not.
+func TestLongPress(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{LongPress: 30 * time.Millisecond})
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("\x1b[<2;5;6M\x1b[<34;6;6M")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "Mouse@5,6", "MouseRightPress", "MouseRightDragStart@5,6", "MouseRightDrag@6,6", "MouseRightLongPress@5,6")
+
+	if _, err := pw.Write([]byte("\x1b[<2;6;6m\x1b[<0;1;1M\x1b[<32;4;1M")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "Mouse@6,6", "MouseRightRelease", "MouseRightDragEnd@6,6", "Mouse@1,1", "MouseLeftPress", "MouseLeftDragStart@1,1", "MouseLeftDrag@4,1")
+	select {
+	case k := <-h.Keys:
+		t.Errorf("unexpected key %q", k)
+	case <-time.After(80 * time.Millisecond):
+	}
+}
+
+// TestMouseChannel: with MouseChannel, mouse reports arrive as typed events
+// on Mouse and nothing mouse-related reaches Keys.
+func TestMouseChannel(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{MouseChannel: true})
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("\x1b[<0;2;3M\x1b[<32;4;3M\x1b[<0;4;3m\x1b[<81;5;5Mx")); err != nil {
+		t.Fatal(err)
+	}
+	want := []MouseEvent{
+		{Action: MousePress, Button: MouseButtonLeft, X: 2, Y: 3, Count: 1},
+		{Action: MouseDragStart, Button: MouseButtonLeft, X: 2, Y: 3, Count: 1},
+		{Action: MouseDrag, Button: MouseButtonLeft, X: 4, Y: 3, Count: 1},
+		{Action: MouseRelease, Button: MouseButtonLeft, X: 4, Y: 3, Count: 1},
+		{Action: MouseDragEnd, Button: MouseButtonLeft, X: 4, Y: 3, Count: 1},
+		{Action: MouseScroll, Button: MouseWheelDown, X: 5, Y: 5, Ctrl: true, Count: 1},
+	}
+	for _, w := range want {
+		select {
+		case ev := <-h.Mouse:
+			if ev != w {
+				t.Errorf("event = %+v, want %+v", ev, w)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("event %+v never arrived", w)
+		}
+	}
+	expectKeys(t, h, "x")
+}
@@ -23,3 +23,34 @@ func TestScrollWheelDirections(t *testing.T) {
 		}
 	}
 }
+
+// Motion with no button held (any-motion tracking, mode 1003) is hover, not
+// a drag.
+func TestMouseMotionWithoutButton(t *testing.T) {
+	pos, action, ok := formatMouseEvent(35, 7, 3, false)
+	if !ok || pos != "" || action != "MouseMotion@7,3" {
+		t.Errorf("formatMouseEvent(cb=35) = (%q, %q, ok=%v), want (\"\", \"MouseMotion@7,3\")", pos, action, ok)
+	}
+}
+
+// Buttons 8-11 set bit 128 of the SGR button code; 8 and 9 are Back and
+// Forward, and must not be mistaken for Left/Middle.
+func TestExtraMouseButtons(t *testing.T) {
+	cases := []struct {
+		cb      int
+		release bool
+		want    string
+	}{
+		{128, false, "MouseBackPress"},
+		{128, true, "MouseBackRelease"},
+		{129, false, "MouseForwardPress"},
+		{130, false, "MouseButton10Press"},
+		{131 | 16, true, "C-MouseButton11Release"},
+	}
+	for _, c := range cases {
+		_, action, ok := formatMouseEvent(c.cb, 1, 1, c.release)
+		if !ok || action != c.want {
+			t.Errorf("formatMouseEvent(cb=%d, release=%v) = (%q, ok=%v), want %q", c.cb, c.release, action, ok, c.want)
+		}
+	}
+}
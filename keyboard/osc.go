@@ -0,0 +1,95 @@
+package keyboard
+
+import "strconv"
+
+// HandleOSC routes OSC strings with the given numeric code to fn instead of
+// OnOSC (or, for 52, instead of OnClipboard). fn receives the payload after
+// "code;" and runs on the processing goroutine. A nil fn removes the route.
+func (h *Handler) HandleOSC(code int, fn func(payload []byte)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if fn == nil {
+		delete(h.oscRoutes, code)
+		return
+	}
+	if h.oscRoutes == nil {
+		h.oscRoutes = make(map[int]func(payload []byte))
+	}
+	h.oscRoutes[code] = fn
+}
+
+// parseOSCIntro reports whether seq is a complete OSC introducer ESC ] Ps ;
+// and returns the numeric Ps
+func parseOSCIntro(seq string) (int, bool) {
+	if len(seq) < len(oscIntro)+2 || seq[:len(oscIntro)] != oscIntro || seq[len(seq)-1] != ';' {
+		return 0, false
+	}
+	digits := seq[len(oscIntro) : len(seq)-1]
+	if !isDigits(digits) {
+		return 0, false
+	}
+	code, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, false
+	}
+	return code, true
+}
+
+// isDigits reports whether s consists only of ASCII digits (true if empty)
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// finishOSC ends an OSC string and routes the accumulated payload: to a
+// HandleOSC route if one matches, else OSC 52 to OnClipboard, else OnOSC.
+func (h *Handler) finishOSC() {
+	code, payload := h.oscCode, h.oscBuffer
+	h.state = stateGround
+	h.oscBuffer = nil
+	h.oscEsc = false
+
+	h.mu.Lock()
+	route := h.oscRoutes[code]
+	h.mu.Unlock()
+
+	switch {
+	case route != nil:
+		h.debug("OSC routed", "code", code, "bytes", len(payload))
+		route(payload)
+	case code == 52:
+		h.finishClipboard(payload)
+	case h.OnOSC != nil:
+		h.debug("OSC", "code", code, "bytes", len(payload))
+		h.OnOSC(code, payload)
+	default:
+		h.debug("OSC unhandled, dropped", "code", code, "bytes", len(payload))
+	}
+}
+
+// isControlStringIntro reports whether c, following ESC, introduces a DCS
+// ('P'), APC ('_'), PM ('^'), or SOS ('X') string
+func isControlStringIntro(c byte) bool {
+	return c == 'P' || c == '_' || c == '^' || c == 'X'
+}
+
+// finishControlString ends a DCS/APC/PM/SOS string and delivers it on
+// OnControlString
+func (h *Handler) finishControlString() {
+	intro, payload := h.stringIntro, h.stringBuffer
+	h.state = stateGround
+	h.stringBuffer = nil
+	h.stringEsc = false
+
+	h.debug("Control string", "intro", string(intro), "bytes", len(payload))
+	if intro == 'P' && isXTGETTCAPReply(payload) && h.handleReply(termReply{kind: replyXTGETTCAP, payload: payload}) {
+		return
+	}
+	if h.OnControlString != nil {
+		h.OnControlString(intro, payload)
+	}
+}
@@ -0,0 +1,584 @@
+package keyboard
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// parserState is the input parser's state, after the ECMA-48 (DEC VT)
+// parser model. Each state consumes bytes until it hands off to another:
+//
+//	ground  --ESC-->          escape
+//	escape  --ESC ] Ps ;-->   OSC     --BEL / ST-->       ground
+//	escape  --ESC P|_|^|X-->  string  --ST-->             ground
+//	escape  --ESC [ 200 ~-->  paste   --ESC [ 201 ~-->    ground
+//	escape  --complete/bad--> ground
+//
+// The escape state covers ESC, CSI, and SS3 sequences: the bytes are
+// gathered in escBuffer and matched against the binding tables and the
+// dynamic CSI parsers. UTF-8 decoding happens in ground (utf8Remaining).
+type parserState uint8
+
+const (
+	stateGround parserState = iota
+	stateEscape
+	stateOSC
+	stateString
+	statePaste
+	numParserStates
+)
+
+// escapeTimeout is how long an unfinished escape sequence waits for its
+// next byte before it is resolved on its own (a lone ESC is Escape)
+const escapeTimeout = 50 * time.Millisecond
+
+// maxControlString bounds a DCS/APC/PM/SOS string body. Terminal replies
+// are far shorter; a longer one is typed input that happened to follow an
+// Alt+P (etc.), and is given back as keys.
+const maxControlString = 4096
+
+// parserStates holds the byte handler for each state
+var parserStates [numParserStates]func(h *Handler, b byte, escTimeout *time.Timer)
+
+func init() {
+	// Filled here rather than in the declaration: the handlers re-enter
+	// processByte, which would make an initialization cycle.
+	parserStates = [numParserStates]func(h *Handler, b byte, escTimeout *time.Timer){
+		stateGround: (*Handler).groundByte,
+		stateEscape: (*Handler).escapeByte,
+		stateOSC:    (*Handler).oscByte,
+		stateString: (*Handler).stringByte,
+		statePaste:  (*Handler).pasteByte,
+	}
+}
+
+// processByte handles a single byte of input
+func (h *Handler) processByte(b byte, escTimeout *time.Timer) {
+	parserStates[h.state](h, b, escTimeout)
+}
+
+// runLength returns how many bytes at the start of data the parser can
+// take as one run in its current state: plain text in ground, a whole UTF-8
+// character, or string and paste content up to the next byte that could
+// end it. Everything else - escape sequences and the bytes that may end a
+// string or paste - goes a byte at a time.
+func (h *Handler) runLength(data []byte) int {
+	n := 1
+	switch h.state {
+	case stateGround:
+		if h.utf8Remaining > 0 {
+			break
+		}
+		if data[0] >= 0x80 {
+			if _, size := utf8.DecodeRune(data); size > 1 {
+				n = size
+			}
+			break
+		}
+		// Each printable key is its own raw event
+		if h.RawEvents != nil {
+			break
+		}
+		for n = 0; n < len(data) && data[n] >= 0x20 && data[n] < 0x7f; n++ {
+		}
+	case statePaste:
+		// The end sequence ESC [ 2 0 1 ~ can only complete on a '~'
+		n = bytes.IndexByte(data, '~')
+	case stateOSC:
+		if !h.oscEsc {
+			n = bytes.IndexAny(data, "\x07\x1b")
+		}
+	case stateString:
+		if !h.stringEsc {
+			n = bytes.IndexByte(data, 0x1b)
+		}
+	}
+	if n < 0 {
+		return len(data)
+	}
+	if n == 0 {
+		return 1
+	}
+	return n
+}
+
+// processRun handles a run of input measured by runLength
+func (h *Handler) processRun(run []byte, escTimeout *time.Timer) {
+	if len(run) == 1 {
+		h.processByte(run[0], escTimeout)
+		return
+	}
+	switch h.state {
+	case stateGround:
+		if run[0] >= 0x80 {
+			h.emitKey(string(run))
+			return
+		}
+		for _, b := range run {
+			h.emitKey(string(b))
+		}
+	case statePaste:
+		h.paste.write(run)
+		h.emitPasteChunks()
+	case stateOSC:
+		h.oscBuffer = append(h.oscBuffer, run...)
+	case stateString:
+		h.stringBuffer = append(h.stringBuffer, run...)
+		h.stringGrew(escTimeout)
+	default:
+		for _, b := range run {
+			h.processByte(b, escTimeout)
+		}
+	}
+}
+
+// enterEscape starts an escape sequence with the ESC just read
+func (h *Handler) enterEscape(escTimeout *time.Timer) {
+	h.state = stateEscape
+	h.escBuffer = []byte{0x1b}
+	escTimeout.Reset(escapeTimeout)
+}
+
+// groundByte handles a byte outside any sequence: control characters,
+// printable ASCII, and UTF-8
+func (h *Handler) groundByte(b byte, escTimeout *time.Timer) {
+	// Anything but a continuation byte cuts a pending UTF-8 character short
+	if h.utf8Remaining > 0 && (b < 0x80 || b > 0xBF) {
+		h.flushUTF8()
+	}
+
+	// Check for escape start
+	if b == 0x1b {
+		h.enterEscape(escTimeout)
+		return
+	}
+
+	// Handle control characters
+	if b < 32 || b == 127 {
+		if key, ok := controlKeys[b]; ok {
+			h.emitKey(key)
+		} else {
+			h.emitKey(fmt.Sprintf("^%c", b+64))
+		}
+		return
+	}
+
+	// Regular printable character
+	if b < 128 {
+		h.emitKey(string(b))
+		return
+	}
+
+	// Continuation of a UTF-8 character (10xxxxxx)
+	if h.utf8Remaining > 0 {
+		h.utf8Buffer = append(h.utf8Buffer, b)
+		h.utf8Remaining--
+		if h.utf8Remaining == 0 {
+			// Complete UTF-8 sequence - emit the character
+			h.emitKey(string(h.utf8Buffer))
+			h.utf8Buffer = nil
+		}
+		return
+	}
+
+	// Start of new UTF-8 sequence - determine length from lead byte
+	if b >= 0xC0 && b <= 0xDF {
+		// 2-byte sequence: 110xxxxx
+		h.utf8Buffer = []byte{b}
+		h.utf8Remaining = 1
+	} else if b >= 0xE0 && b <= 0xEF {
+		// 3-byte sequence: 1110xxxx
+		h.utf8Buffer = []byte{b}
+		h.utf8Remaining = 2
+	} else if b >= 0xF0 && b <= 0xF7 {
+		// 4-byte sequence: 11110xxx
+		h.utf8Buffer = []byte{b}
+		h.utf8Remaining = 3
+	} else {
+		// Invalid UTF-8 lead byte or bare continuation byte - emit as-is
+		h.emitKey(string(rune(b)))
+	}
+}
+
+// flushUTF8 emits an incomplete UTF-8 character's bytes as-is
+func (h *Handler) flushUTF8() {
+	for _, bb := range h.utf8Buffer {
+		h.emitKey(string(rune(bb)))
+	}
+	h.utf8Buffer = nil
+	h.utf8Remaining = 0
+}
+
+// escapeByte gathers an ESC, CSI, or SS3 sequence and emits its key once
+// it is complete, or switches to the OSC, string, or paste state when the
+// sequence introduces one
+func (h *Handler) escapeByte(b byte, escTimeout *time.Timer) {
+	// ESC ] Ps ends without a ';' when the OSC has no text (e.g. the
+	// "ESC ] 104 BEL" reset); the terminator is handled by the OSC state.
+	if (b == 0x07 || b == 0x1b) && len(h.escBuffer) > len(oscIntro) {
+		seq := string(h.escBuffer)
+		if code, ok := parseOSCIntro(seq + ";"); ok {
+			h.enterOSC(code, escTimeout)
+			h.oscByte(b, escTimeout)
+			return
+		}
+	}
+
+	// An ESC inside a sequence cancels it and starts a new one (ECMA-48),
+	// unless a binding continues with it (ESC ESC [ A, user bindings) or it
+	// opens an empty control string (ESC P ESC \)
+	if b == 0x1b && len(h.escBuffer) > 1 && !isControlStringIntro(h.escBuffer[1]) &&
+		!h.continuesBinding(string(h.escBuffer)+"\x1b") {
+		h.resolveEscape()
+		h.enterEscape(escTimeout)
+		return
+	}
+
+	h.escBuffer = append(h.escBuffer, b)
+
+	// Check if we have a complete escape sequence
+	seq := string(h.escBuffer)
+
+	// Simple mode drops the paste markers and leaves the content to arrive
+	// as keys
+	if h.simple && (seq == bracketedPasteStart || seq == bracketedPasteEnd) {
+		h.escBuffer = nil
+		h.state = stateGround
+		escTimeout.Stop()
+		return
+	}
+
+	// Check for bracketed paste start
+	if seq == bracketedPasteStart {
+		h.logAt(slog.LevelInfo, "Paste start")
+		h.state = statePaste
+		h.escBuffer = nil
+		h.paste.reset()
+		h.pasteEmitted = 0
+		// Release the content as it is passed on when only chunks of it
+		// are wanted; a consumer added mid-paste sees it empty
+		h.pasteDiscard = h.sink == nil && h.OnPaste == nil && h.numSubs.Load() == 0 &&
+			(h.Pastes == nil || h.streamPastes) && !h.inLineReadMode.Load() && !h.emitPasteKeys
+		escTimeout.Stop()
+		if h.streamPastes {
+			h.pasteStream = make(chan []byte, 16)
+			h.sendPaste(PasteEvent{Chunks: h.pasteStream})
+		}
+		return
+	}
+
+	// Check for an OSC string start (ESC ] Ps ;). The body runs until
+	// BEL/ST and is gathered by oscByte.
+	if code, ok := parseOSCIntro(seq); ok {
+		h.enterOSC(code, escTimeout)
+		return
+	}
+
+	// Check for a DCS/APC/PM/SOS string: the introducer followed by any
+	// byte. A lone ESC P (etc.) that times out is still an Alt key.
+	if len(seq) == 3 && isControlStringIntro(seq[1]) {
+		h.debug("Control string start", "intro", string(seq[1]))
+		h.state = stateString
+		h.escBuffer = nil
+		h.stringIntro = seq[1]
+		h.stringBuffer = nil
+		h.stringEsc = false
+		h.stringByte(b, escTimeout)
+		return
+	}
+
+	// A double tap of Escape, when it isn't left to the timeout
+	if seq == "\x1b\x1b" && h.doubleEscape != DoubleEscapeMeta {
+		if h.doubleEscape == DoubleEscapeKey {
+			h.emitKey("DoubleEscape")
+		} else {
+			h.emitKey("Escape")
+			h.emitKey("Escape")
+		}
+		h.escBuffer = nil
+		h.state = stateGround
+		escTimeout.Stop()
+		return
+	}
+
+	if key, ok := h.lookupBinding(seq); ok {
+		h.emitKey(key)
+		h.escBuffer = nil
+		h.state = stateGround
+		escTimeout.Stop()
+		return
+	}
+
+	// Check if this could be a prefix of a valid sequence
+	if h.couldBeEscapePrefix(seq) {
+		// Reset timeout - wait for more bytes
+		escTimeout.Reset(escapeTimeout)
+		return
+	}
+
+	// Try dynamic parsing for CSI sequences with modifiers
+	if key, ok := h.parseModifiedCSI(seq); ok {
+		// Mouse events return "" but emit keys internally
+		if key != "" {
+			h.emitKey(key)
+		}
+		h.macOSOptionKey = false
+		h.escBuffer = nil
+		h.state = stateGround
+		escTimeout.Stop()
+		return
+	}
+
+	// An ESC prefix on a complete CSI or SS3 key adds Meta to it
+	if key, ok := h.parseMetaPrefixed(seq); ok {
+		h.emitKey(key)
+		h.escBuffer = nil
+		h.state = stateGround
+		escTimeout.Stop()
+		return
+	}
+
+	// Try Alt+key parsing (ESC followed by character)
+	if key, ok := h.parseAltSequence(seq); ok {
+		h.emitKey(key)
+		h.escBuffer = nil
+		h.state = stateGround
+		escTimeout.Stop()
+		return
+	}
+
+	// Not a valid sequence - emit as individual keys
+	h.unknownSequence()
+}
+
+// parseMetaPrefixed parses ESC followed by a CSI or SS3 key sequence (as
+// terminals send Alt with keys that already have a sequence of their own),
+// or by a UTF-8 character, as that key with Meta added
+func (h *Handler) parseMetaPrefixed(seq string) (string, bool) {
+	if len(seq) > 2 && seq[0] == 0x1b && seq[1] >= 0xC0 {
+		if r, size := utf8.DecodeRuneInString(seq[1:]); r != utf8.RuneError && size == len(seq)-1 {
+			return "M-" + seq[1:], true
+		}
+		return "", false
+	}
+	if len(seq) < 4 || seq[0] != 0x1b || seq[1] != 0x1b || (seq[2] != '[' && seq[2] != 'O') {
+		return "", false
+	}
+	inner := seq[1:]
+	// Mouse reports are emitted as they parse; they never carry an ESC prefix
+	if strings.HasPrefix(inner, "\x1b[<") || strings.HasPrefix(inner, "\x1b[M") {
+		return "", false
+	}
+	key, ok := h.lookupBinding(inner)
+	if !ok {
+		key, ok = h.parseModifiedCSI(inner)
+	}
+	if !ok || key == "" {
+		return "", false
+	}
+	return addMeta(key), true
+}
+
+// addMeta adds the Meta modifier to a key name, in the S-M-C-s- order that
+// modifier sequences use
+func addMeta(key string) string {
+	for rest := key; len(rest) > 2 && rest[1] == '-'; rest = rest[2:] {
+		if rest[0] == 'M' {
+			return key // Already has Meta
+		}
+	}
+	if strings.HasPrefix(key, "S-") {
+		return "S-M-" + key[2:]
+	}
+	return "M-" + key
+}
+
+// continuesBinding reports whether seq is a built-in or bound sequence, or
+// the start of one
+func (h *Handler) continuesBinding(seq string) bool {
+	if _, ok := h.lookupBinding(seq); ok {
+		return true
+	}
+	return escTrie.isProperPrefix(seq) || h.isBindingPrefix(seq)
+}
+
+// resolveEscape ends an escape sequence that will get no more bytes (it
+// timed out, or an ESC cancelled it): as an Alt key if it is one, else as
+// an unknown sequence
+func (h *Handler) resolveEscape() {
+	if key, ok := h.parseAltSequence(string(h.escBuffer)); ok {
+		h.emitKey(key)
+		h.escBuffer = nil
+		h.state = stateGround
+	} else if len(h.escBuffer) > 1 {
+		// Incomplete sequence that never terminated
+		h.unknownSequence()
+	} else {
+		h.emitEscapeBuffer()
+	}
+}
+
+// enterOSC starts gathering the text of an OSC string with the given Ps
+func (h *Handler) enterOSC(code int, escTimeout *time.Timer) {
+	h.debug("OSC start", "code", code)
+	h.state = stateOSC
+	h.escBuffer = nil
+	h.oscCode = code
+	h.oscBuffer = nil
+	h.oscEsc = false
+	escTimeout.Stop()
+}
+
+// oscByte accumulates an OSC body until a BEL (0x07) or ST (ESC \)
+// terminator, then routes it. OSC replies never contain a raw ESC, so an
+// ESC always ends the body; if it isn't the start of ST, it begins the next
+// escape sequence.
+func (h *Handler) oscByte(b byte, escTimeout *time.Timer) {
+	if h.oscEsc {
+		h.oscEsc = false
+		h.finishOSC()
+		h.afterStringESC(b, escTimeout)
+		return
+	}
+	switch b {
+	case 0x07: // BEL terminator
+		h.finishOSC()
+	case 0x1b: // ESC - possible ST terminator start
+		h.oscEsc = true
+	default:
+		h.oscBuffer = append(h.oscBuffer, b)
+	}
+}
+
+// stringByte accumulates a DCS/APC/PM/SOS body until ST (ESC \). As with
+// OSC, any ESC ends the body. The escape timeout runs meanwhile: a reply
+// arrives all at once, so a string that goes idle was typed (see
+// abandonString).
+func (h *Handler) stringByte(b byte, escTimeout *time.Timer) {
+	if h.stringEsc {
+		h.stringEsc = false
+		escTimeout.Stop()
+		h.finishControlString()
+		h.afterStringESC(b, escTimeout)
+		return
+	}
+	if b == 0x1b {
+		h.stringEsc = true
+		escTimeout.Reset(escapeTimeout)
+		return
+	}
+	h.stringBuffer = append(h.stringBuffer, b)
+	h.stringGrew(escTimeout)
+}
+
+// stringGrew restarts the idle timeout after string content, or gives the
+// string up once it is too long to be a reply
+func (h *Handler) stringGrew(escTimeout *time.Timer) {
+	if len(h.stringBuffer) > maxControlString {
+		h.abandonString(escTimeout)
+		return
+	}
+	escTimeout.Reset(escapeTimeout)
+}
+
+// abandonString ends a control string that went idle or grew too long: it
+// was not a reply but Alt+P (or Alt+_, ...) and then typing, so the
+// introducer becomes its Alt key and the body is parsed again as input.
+func (h *Handler) abandonString(escTimeout *time.Timer) {
+	h.debug("Control string abandoned", "intro", string(h.stringIntro), "bytes", len(h.stringBuffer))
+	rest := h.stringBuffer
+	if h.stringEsc {
+		rest = append(rest, 0x1b)
+	}
+	h.stringBuffer = nil
+	h.stringEsc = false
+	escTimeout.Stop()
+
+	h.state = stateEscape
+	h.escBuffer = []byte{0x1b, h.stringIntro}
+	h.resolveEscape()
+	h.state = stateGround
+	for _, b := range rest {
+		h.processByte(b, escTimeout)
+	}
+}
+
+// afterStringESC handles the byte after the ESC that ended an OSC or
+// control string: '\' completes ST, anything else continues the escape
+// sequence that ESC started
+func (h *Handler) afterStringESC(b byte, escTimeout *time.Timer) {
+	if b == '\\' {
+		return
+	}
+	h.enterEscape(escTimeout)
+	h.escapeByte(b, escTimeout)
+}
+
+// pasteByte accumulates bracketed paste content until ESC [ 201 ~,
+// emitting chunks along the way
+func (h *Handler) pasteByte(b byte, escTimeout *time.Timer) {
+	h.paste.write([]byte{b})
+
+	// Check if the content ends with the end sequence
+	if h.paste.hasSuffix(bracketedPasteEnd) {
+		// End of paste - the content is everything before the end sequence
+		contentLen := h.paste.len() - len(bracketedPasteEnd)
+		h.state = stateGround
+		h.logAt(slog.LevelInfo, "Paste end", "bytes", contentLen)
+		h.stats.pastes.Add(1)
+		h.stats.pasteBytes.Add(uint64(contentLen))
+		// Emit the final chunk (the content not yet emitted) if wanted
+		if h.OnPasteChunk != nil || h.pasteStream != nil {
+			remainingContent := h.paste.slice(h.pasteEmitted, contentLen)
+			if h.OnPasteChunk != nil {
+				h.OnPasteChunk(PasteChunk{Content: remainingContent, IsFinal: true})
+			}
+			if h.pasteStream != nil {
+				if len(remainingContent) > 0 {
+					h.streamChunk(append([]byte(nil), remainingContent...))
+				}
+				close(h.pasteStream)
+				h.pasteStream = nil
+			}
+		}
+		// emitPaste receives full content for OnPaste callback and key emission
+		var fullContent []byte
+		if !h.pasteDiscard {
+			fullContent = h.paste.slice(0, contentLen)
+		}
+		h.paste.reset()
+		h.emitPaste(fullContent)
+		return
+	}
+
+	h.emitPasteChunks()
+}
+
+// emitPasteChunks emits incremental chunks while there is enough data: when
+// the unemitted content is >= chunkSize + pasteEndBufferSize (to keep 7
+// bytes for end detection). Content that is no longer needed is released.
+func (h *Handler) emitPasteChunks() {
+	chunked := h.OnPasteChunk != nil || h.pasteStream != nil
+	for chunked && h.paste.len()-h.pasteEmitted >= h.pasteChunkSize+pasteEndBufferSize {
+		// Emit a full chunk, keeping pasteEndBufferSize bytes buffered
+		chunk := h.paste.slice(h.pasteEmitted, h.pasteEmitted+h.pasteChunkSize)
+		h.pasteEmitted += h.pasteChunkSize
+		if h.OnPasteChunk != nil {
+			h.OnPasteChunk(PasteChunk{Content: chunk, IsFinal: false})
+		}
+		if h.pasteStream != nil {
+			h.streamChunk(chunk)
+		}
+	}
+	if h.pasteDiscard {
+		if chunked {
+			h.paste.release(h.pasteEmitted)
+		} else {
+			h.paste.release(h.paste.len() - pasteEndBufferSize)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package keyboard
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParserESCCancelsSequence: an ESC in the middle of a CSI sequence
+// abandons it and starts the next one, instead of swallowing both.
+func TestParserESCCancelsSequence(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("\x1b[1\x1b[Ax")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "Escape", "[", "1", "Up", "x")
+}
+
+// TestParserOSCTerminators: an OSC with no text ends at its terminator, and
+// an ESC that isn't ST both ends the string and starts the next sequence.
+func TestParserOSCTerminators(t *testing.T) {
+	type osc struct {
+		code    int
+		payload string
+	}
+	got := make(chan osc, 2)
+
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+	h.OnOSC = func(code int, payload []byte) { got <- osc{code, string(payload)} }
+
+	if _, err := pw.Write([]byte("\x1b]104\x07\x1b]11;abc\x1b[Ax")); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []osc{{104, ""}, {11, "abc"}} {
+		select {
+		case r := <-got:
+			if r != want {
+				t.Errorf("OnOSC got (%d, %q), want (%d, %q)", r.code, r.payload, want.code, want.payload)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("OnOSC was not called for %d", want.code)
+		}
+	}
+	expectKeys(t, h, "Up", "x")
+}
+
+// TestParserInterruptedUTF8: a byte that can't continue a UTF-8 character
+// flushes the partial character before it is handled.
+func TestParserInterruptedUTF8(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("\xc3a\xc3\xa9")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "Ã", "a", "é")
+}
+
+// TestChunkedInputMatchesBytewise: input read in one chunk parses to the same
+// keys and paste chunks as the same input arriving a byte at a time.
+func TestChunkedInputMatchesBytewise(t *testing.T) {
+	input := []byte("héllo 世界\x1b[Ax\x1b]11;abc\x07\x1b[200~pasted ~text, long enough to chunk\x1b[201~z\xc3!")
+
+	run := func(bytewise bool) (keys, chunks []string) {
+		h, pw, cleanup := newPipedHandlerWith(t, Options{PasteChunkSize: 8})
+		defer cleanup()
+		done := make(chan struct{})
+		h.OnPasteChunk = func(c PasteChunk) {
+			chunks = append(chunks, string(c.Content))
+			if c.IsFinal {
+				close(done)
+			}
+		}
+		go func() {
+			if !bytewise {
+				pw.Write(input)
+				return
+			}
+			for i := range input {
+				pw.Write(input[i : i+1])
+			}
+		}()
+		for {
+			select {
+			case k := <-h.Keys:
+				keys = append(keys, k)
+				if k == "!" {
+					<-done
+					return keys, chunks
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("input not fully parsed (bytewise=%v), got %q", bytewise, keys)
+			}
+		}
+	}
+
+	keys, chunks := run(false)
+	wantKeys, wantChunks := run(true)
+	if strings.Join(keys, " ") != strings.Join(wantKeys, " ") {
+		t.Errorf("chunked keys = %q, want %q", keys, wantKeys)
+	}
+	if strings.Join(chunks, "|") != strings.Join(wantChunks, "|") {
+		t.Errorf("chunked paste chunks = %q, want %q", chunks, wantChunks)
+	}
+}
+
+// TestDoubleEscape: ESC ESC is reported at once in the immediate modes, and
+// as M-Escape after the timeout by default.
+func TestDoubleEscape(t *testing.T) {
+	for _, tt := range []struct {
+		mode DoubleEscapeMode
+		want []string
+	}{
+		{DoubleEscapeMeta, []string{"M-Escape", "x"}},
+		{DoubleEscapeKeys, []string{"Escape", "Escape", "x"}},
+		{DoubleEscapeKey, []string{"DoubleEscape", "x"}},
+	} {
+		h, pw, cleanup := newPipedHandlerWith(t, Options{DoubleEscape: tt.mode})
+		if _, err := pw.Write([]byte("\x1b\x1b")); err != nil {
+			t.Fatal(err)
+		}
+		if tt.mode == DoubleEscapeMeta {
+			time.Sleep(100 * time.Millisecond) // let the escape timeout pass
+		}
+		if _, err := pw.Write([]byte("x")); err != nil {
+			t.Fatal(err)
+		}
+		expectKeys(t, h, tt.want...)
+		cleanup()
+	}
+}
+
+// TestMetaPrefix: an ESC before a key that has a sequence of its own, or
+// before a UTF-8 character, adds Meta to that key.
+func TestMetaPrefix(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+
+	for _, tt := range []struct{ in, want string }{
+		{"\x1b\x1b[1;5A", "M-C-Up"},
+		{"\x1b\x1b[1;2B", "S-M-Down"},
+		{"\x1b\x1bOP", "M-F1"},
+		{"\x1b\x1b[3~", "M-Delete"},
+		{"\x1b\x1b[1;3C", "M-Right"},
+		{"\x1b\xc3\xa9", "M-é"},
+		{"\x1b\x01", "M-^A"},
+	} {
+		if _, err := pw.Write([]byte(tt.in)); err != nil {
+			t.Fatal(err)
+		}
+		expectKeys(t, h, tt.want)
+	}
+}
@@ -0,0 +1,135 @@
+package keyboard
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPasteChannel: a completed paste arrives on Pastes in one event.
+func TestPasteChannel(t *testing.T) {
+	noKeys := false
+	h, pw, cleanup := newPipedHandlerWith(t, Options{PasteChannel: true, EmitPasteKeys: &noKeys})
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("\x1b[200~hello\rworld\x1b[201~")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-h.Pastes:
+		if string(ev.Content) != "hello\rworld" || ev.Chunks != nil {
+			t.Errorf("paste = {Content: %q, Chunks: %v}, want the full content", ev.Content, ev.Chunks)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("paste never arrived")
+	}
+}
+
+// TestPasteStream: with StreamPastes the event arrives at paste start and
+// the content follows on Chunks, which is closed at the end.
+func TestPasteStream(t *testing.T) {
+	noKeys := false
+	h, pw, cleanup := newPipedHandlerWith(t, Options{
+		PasteChannel:   true,
+		StreamPastes:   true,
+		PasteChunkSize: 4,
+		EmitPasteKeys:  &noKeys,
+	})
+	defer cleanup()
+
+	content := strings.Repeat("abcdefgh", 5)
+	go pw.Write([]byte("\x1b[200~" + content + "\x1b[201~"))
+
+	var ev PasteEvent
+	select {
+	case ev = <-h.Pastes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("paste never arrived")
+	}
+	if ev.Chunks == nil {
+		t.Fatal("streamed paste has no Chunks")
+	}
+	var got strings.Builder
+	for chunk := range ev.Chunks {
+		if len(chunk) > 4 {
+			t.Errorf("chunk %q longer than PasteChunkSize", chunk)
+		}
+		got.Write(chunk)
+	}
+	if got.String() != content {
+		t.Errorf("streamed content = %q, want %q", got.String(), content)
+	}
+}
+
+// TestLargePaste: a paste spanning several buffer blocks arrives intact,
+// both whole on OnPaste and in order on OnPasteChunk.
+func TestLargePaste(t *testing.T) {
+	noKeys := false
+	h, pw, cleanup := newPipedHandlerWith(t, Options{EmitPasteKeys: &noKeys, PasteChunkSize: 1000})
+	defer cleanup()
+	whole := make(chan string, 1)
+	var chunks strings.Builder
+	h.OnPaste = func(content []byte) { whole <- string(content) }
+	h.OnPasteChunk = func(c PasteChunk) { chunks.Write(c.Content) }
+
+	var b strings.Builder
+	for i := 0; b.Len() < 3*pasteBlockSize+123; i++ {
+		b.WriteString(strings.Repeat(string(rune('a'+i%26)), i%97))
+		b.WriteString("~")
+	}
+	content := b.String()
+	go pw.Write([]byte(bracketedPasteStart + content + bracketedPasteEnd))
+
+	select {
+	case got := <-whole:
+		if got != content {
+			t.Errorf("OnPaste got %d bytes, want the %d pasted", len(got), len(content))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("paste never arrived")
+	}
+	if chunks.String() != content {
+		t.Errorf("chunks joined to %d bytes, want the %d pasted", chunks.Len(), len(content))
+	}
+}
+
+// TestPasteStreamInterrupted: a streamed paste whose end marker never
+// comes has its Chunks closed when the input fails or the handler stops.
+func TestPasteStreamInterrupted(t *testing.T) {
+	for _, stop := range []string{"input failure", "Stop"} {
+		t.Run(stop, func(t *testing.T) {
+			noKeys := false
+			h, pw, cleanup := newPipedHandlerWith(t, Options{
+				PasteChannel:  true,
+				StreamPastes:  true,
+				EmitPasteKeys: &noKeys,
+			})
+			defer cleanup()
+			go pw.Write([]byte("\x1b[200~half a paste"))
+
+			var ev PasteEvent
+			select {
+			case ev = <-h.Pastes:
+			case <-time.After(2 * time.Second):
+				t.Fatal("paste never arrived")
+			}
+			if stop == "Stop" {
+				h.Stop()
+			} else {
+				pw.Close()
+			}
+
+			done := make(chan struct{})
+			go func() {
+				for range ev.Chunks {
+				}
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(2 * time.Second):
+				t.Fatal("Chunks never closed")
+			}
+		})
+	}
+}
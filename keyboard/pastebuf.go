@@ -0,0 +1,85 @@
+package keyboard
+
+// pasteBlockSize is the size of the blocks bracketed paste content is
+// gathered in
+const pasteBlockSize = 64 << 10
+
+// pasteBuf gathers bracketed paste content in fixed-size blocks. Unlike one
+// appended-to slice, a large paste is never copied to grow it, and blocks
+// that are no longer needed (already passed on as chunks, when nothing wants
+// the whole paste) can be released while the paste is still arriving.
+type pasteBuf struct {
+	blocks [][]byte
+	base   int // offset of blocks[0] in the content (released bytes)
+	n      int // total bytes written
+}
+
+// write appends b
+func (p *pasteBuf) write(b []byte) {
+	for len(b) > 0 {
+		if len(p.blocks) == 0 || len(p.blocks[len(p.blocks)-1]) == pasteBlockSize {
+			p.blocks = append(p.blocks, make([]byte, 0, pasteBlockSize))
+		}
+		last := &p.blocks[len(p.blocks)-1]
+		k := min(pasteBlockSize-len(*last), len(b))
+		*last = append(*last, b[:k]...)
+		b = b[k:]
+		p.n += k
+	}
+}
+
+// len returns the total bytes written, released ones included
+func (p *pasteBuf) len() int {
+	return p.n
+}
+
+// at returns the byte at content offset i, which must not be released
+func (p *pasteBuf) at(i int) byte {
+	i -= p.base
+	return p.blocks[i/pasteBlockSize][i%pasteBlockSize]
+}
+
+// hasSuffix reports whether the content written so far ends with s
+func (p *pasteBuf) hasSuffix(s string) bool {
+	if p.n-p.base < len(s) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if p.at(p.n-len(s)+i) != s[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// slice returns a copy of content offsets [from, to)
+func (p *pasteBuf) slice(from, to int) []byte {
+	out := make([]byte, 0, to-from)
+	for from < to {
+		i := from - p.base
+		block := p.blocks[i/pasteBlockSize]
+		end := min(len(block), i%pasteBlockSize+to-from)
+		out = append(out, block[i%pasteBlockSize:end]...)
+		from += end - i%pasteBlockSize
+	}
+	return out
+}
+
+// release drops the blocks that lie entirely before content offset upto
+func (p *pasteBuf) release(upto int) {
+	drop := (upto - p.base) / pasteBlockSize
+	if drop <= 0 {
+		return
+	}
+	// Clear the dropped entries so their memory can be collected
+	for i := 0; i < drop; i++ {
+		p.blocks[i] = nil
+	}
+	p.blocks = p.blocks[drop:]
+	p.base += drop * pasteBlockSize
+}
+
+// reset empties the buffer
+func (p *pasteBuf) reset() {
+	*p = pasteBuf{}
+}
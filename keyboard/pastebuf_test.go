@@ -0,0 +1,38 @@
+package keyboard
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestPasteBufBlocks: content written across block boundaries reads back
+// unchanged, and released blocks no longer count toward what is held.
+func TestPasteBufBlocks(t *testing.T) {
+	var want []byte
+	for i := 0; len(want) < 2*pasteBlockSize+100; i++ {
+		want = append(want, byte(i))
+	}
+	var p pasteBuf
+	p.write(want[:10])
+	p.write(want[10 : pasteBlockSize+5])
+	p.write(want[pasteBlockSize+5:])
+
+	if p.len() != len(want) {
+		t.Fatalf("len = %d, want %d", p.len(), len(want))
+	}
+	if got := p.slice(0, len(want)); !bytes.Equal(got, want) {
+		t.Error("slice of the whole buffer differs from what was written")
+	}
+	if !p.hasSuffix(string(want[len(want)-6:])) {
+		t.Error("hasSuffix is false for the last bytes written")
+	}
+
+	p.release(pasteBlockSize + 1)
+	if len(p.blocks) != 2 || p.base != pasteBlockSize {
+		t.Errorf("after release: %d blocks from %d, want 2 from %d", len(p.blocks), p.base, pasteBlockSize)
+	}
+	from := pasteBlockSize + 1
+	if got := p.slice(from, len(want)); !bytes.Equal(got, want[from:]) {
+		t.Error("slice after release differs from what was written")
+	}
+}
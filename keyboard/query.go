@@ -0,0 +1,282 @@
+package keyboard
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// DefaultQueryTimeout is how long the Query* methods wait for a reply
+const DefaultQueryTimeout = 500 * time.Millisecond
+
+// ErrQueryTimeout is returned when the terminal does not answer a query in
+// time - usually because it does not support it.
+var ErrQueryTimeout = errors.New("terminal query timed out")
+
+// replyKind identifies a terminal reply recognized by the parser
+type replyKind int
+
+const (
+	replyDA1       replyKind = iota // CSI ? ... c
+	replyDA2                        // CSI > ... c
+	replyCPR                        // CSI row ; col R
+	replyXTGETTCAP                  // DCS 1 + r name=value ST / DCS 0 + r ST
+	replyDECRPM                     // CSI ? mode ; value $ y
+	replyCellSize                   // CSI 6 ; height ; width t
+)
+
+// termReply is a terminal reply pulled out of the input stream
+type termReply struct {
+	kind    replyKind
+	params  []string
+	payload []byte // string body, for DCS replies
+}
+
+// queryWaiter is a Query* call waiting for a reply of a given kind
+type queryWaiter struct {
+	kind  replyKind
+	ch    chan termReply
+	multi bool                 // collects every reply of kind until cancelled
+	match func(termReply) bool // optional further filter on the reply
+}
+
+// handleReply hands a terminal reply to the oldest query waiting for it.
+// Returns false if nobody was waiting.
+func (h *Handler) handleReply(r termReply) bool {
+	h.mu.Lock()
+	for i, w := range h.queryWaiters {
+		if w.kind != r.kind || (w.match != nil && !w.match(r)) {
+			continue
+		}
+		if w.multi {
+			h.mu.Unlock()
+			select {
+			case w.ch <- r:
+			default:
+			}
+			return true
+		}
+		h.queryWaiters = append(h.queryWaiters[:i:i], h.queryWaiters[i+1:]...)
+		h.mu.Unlock()
+		w.ch <- r // buffered; never blocks
+		return true
+	}
+	h.mu.Unlock()
+	h.debug("Unsolicited terminal reply", "kind", int(r.kind), "params", r.params)
+	return false
+}
+
+// awaitingReply reports whether a query is waiting for a reply of kind
+func (h *Handler) awaitingReply(kind replyKind) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, w := range h.queryWaiters {
+		if w.kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// expectReply registers interest in the next reply of the given kind. The
+// returned cancel must be called if the reply is no longer wanted.
+func (h *Handler) expectReply(kind replyKind) (<-chan termReply, func()) {
+	return h.expectReplies(kind, 1, false, nil)
+}
+
+// expectReplies is expectReply with a buffer of n replies; with multi set,
+// every reply of kind is collected (up to n) until cancel is called.
+func (h *Handler) expectReplies(kind replyKind, n int, multi bool, match func(termReply) bool) (<-chan termReply, func()) {
+	w := &queryWaiter{kind: kind, ch: make(chan termReply, n), multi: multi, match: match}
+	h.mu.Lock()
+	h.queryWaiters = append(h.queryWaiters, w)
+	h.mu.Unlock()
+	return w.ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for i, o := range h.queryWaiters {
+			if o == w {
+				h.queryWaiters = append(h.queryWaiters[:i:i], h.queryWaiters[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// sendQuery writes a query to the terminal
+func (h *Handler) sendQuery(q string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.running {
+		return fmt.Errorf("handler not running")
+	}
+	if h.modeWriter == nil {
+		return fmt.Errorf("no ModeWriter to send terminal query")
+	}
+	if _, err := h.modeWriter.Write([]byte(q)); err != nil {
+		return fmt.Errorf("failed to send terminal query: %w", err)
+	}
+	return nil
+}
+
+// awaitReply waits for a reply on ch, up to the query timeout
+func (h *Handler) awaitReply(ch <-chan termReply) (termReply, error) {
+	t := time.NewTimer(h.queryTimeout)
+	defer t.Stop()
+	select {
+	case r := <-ch:
+		return r, nil
+	case <-t.C:
+		return termReply{}, ErrQueryTimeout
+	case <-h.stopChan:
+		return termReply{}, fmt.Errorf("handler stopped")
+	}
+}
+
+// DeviceAttributes describes the terminal, from its Primary (DA1) and
+// Secondary (DA2) Device Attributes replies.
+type DeviceAttributes struct {
+	// Level is the DA1 conformance level: 1 for VT100-class terminals,
+	// otherwise 62-65 for VT200-VT500 class.
+	Level int
+	// Features lists the DA1 extension codes (e.g. 4 = sixel, 22 = color).
+	Features []int
+
+	// HasSecondary is true if the terminal answered DA2.
+	HasSecondary bool
+	// Type is the DA2 terminal type (0 = VT100, 1 = VT220, 41 = VT420 /
+	// xterm, 65 = VT525, ...).
+	Type int
+	// Version is the DA2 firmware version: the xterm patch level, the VTE
+	// version number, and so on.
+	Version int
+}
+
+// HasFeature reports whether the DA1 reply listed feature code f.
+func (da DeviceAttributes) HasFeature(f int) bool {
+	for _, x := range da.Features {
+		if x == f {
+			return true
+		}
+	}
+	return false
+}
+
+// QueryDeviceAttributes asks the terminal for its Primary and Secondary
+// Device Attributes and returns the decoded replies, which are consumed
+// rather than emitted as keys. DA2 is requested first: terminals answer in
+// order, so once the DA1 reply arrives any DA2 reply has too, and terminals
+// without DA2 cost no extra wait. Requires ModeWriter; must not be called
+// from a handler callback, since replies are parsed on that goroutine.
+func (h *Handler) QueryDeviceAttributes() (DeviceAttributes, error) {
+	da2, cancel2 := h.expectReply(replyDA2)
+	defer cancel2()
+	da1, cancel1 := h.expectReply(replyDA1)
+	defer cancel1()
+
+	if err := h.sendQuery("\x1b[>c\x1b[c"); err != nil {
+		return DeviceAttributes{}, err
+	}
+	r1, err := h.awaitReply(da1)
+	if err != nil {
+		return DeviceAttributes{}, err
+	}
+
+	var da DeviceAttributes
+	for i, p := range r1.params {
+		n, _ := strconv.Atoi(p)
+		if i == 0 {
+			da.Level = n
+		} else {
+			da.Features = append(da.Features, n)
+		}
+	}
+	select {
+	case r2 := <-da2:
+		da.HasSecondary = true
+		if len(r2.params) > 0 {
+			da.Type, _ = strconv.Atoi(r2.params[0])
+		}
+		if len(r2.params) > 1 {
+			da.Version, _ = strconv.Atoi(r2.params[1])
+		}
+	default:
+	}
+	return da, nil
+}
+
+// QueryCursorPosition asks the terminal where the cursor is (DSR 6) and
+// returns the 1-based row and column from its Cursor Position Report. The
+// report is consumed rather than emitted as a key; CPRs that arrive with no
+// query waiting are still emitted as "CPR:row;col". Requires ModeWriter;
+// must not be called from a handler callback.
+func (h *Handler) QueryCursorPosition() (row, col int, err error) {
+	ch, cancel := h.expectReply(replyCPR)
+	defer cancel()
+
+	if err := h.sendQuery("\x1b[6n"); err != nil {
+		return 0, 0, err
+	}
+	r, err := h.awaitReply(ch)
+	if err != nil {
+		return 0, 0, err
+	}
+	row, _ = strconv.Atoi(r.params[0])
+	col, _ = strconv.Atoi(r.params[1])
+	return row, col, nil
+}
+
+// ModeState is a terminal's answer to a DECRQM mode query.
+type ModeState int
+
+const (
+	ModeNotRecognized    ModeState = 0 // The terminal does not know the mode
+	ModeSet              ModeState = 1 // Supported and currently enabled
+	ModeReset            ModeState = 2 // Supported and currently disabled
+	ModePermanentlySet   ModeState = 3 // Always enabled
+	ModePermanentlyReset ModeState = 4 // Always disabled
+)
+
+// Supported reports whether the terminal recognizes the mode at all.
+func (m ModeState) Supported() bool {
+	return m != ModeNotRecognized
+}
+
+// Enabled reports whether the mode is currently in effect.
+func (m ModeState) Enabled() bool {
+	return m == ModeSet || m == ModePermanentlySet
+}
+
+// Common DEC private modes for QueryMode
+const (
+	ModeMouseX10       = 1000 // Mouse button reporting
+	ModeMouseButtons   = 1002 // Mouse button + drag reporting
+	ModeMouseAnyMotion = 1003 // Mouse reporting including hover motion
+	ModeFocusEvents    = 1004 // Focus in/out reporting
+	ModeMouseSGR       = 1006 // SGR mouse encoding
+	ModeBracketedPaste = 2004 // Bracketed paste
+)
+
+// QueryMode asks the terminal about a DEC private mode (DECRQM, CSI ? mode
+// $ p) and returns its DECRPM answer, so an application can check that e.g.
+// bracketed paste or SGR mouse is supported before relying on it. Terminals
+// that don't implement DECRQM at all time out with ErrQueryTimeout. Requires
+// ModeWriter; must not be called from a handler callback.
+func (h *Handler) QueryMode(mode int) (ModeState, error) {
+	want := strconv.Itoa(mode)
+	ch, cancel := h.expectReplies(replyDECRPM, 1, false, func(r termReply) bool {
+		return r.params[0] == want
+	})
+	defer cancel()
+
+	if err := h.sendQuery("\x1b[?" + want + "$p"); err != nil {
+		return ModeNotRecognized, err
+	}
+	r, err := h.awaitReply(ch)
+	if err != nil {
+		return ModeNotRecognized, err
+	}
+	v, _ := strconv.Atoi(r.params[1])
+	return ModeState(v), nil
+}
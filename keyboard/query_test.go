@@ -0,0 +1,159 @@
+package keyboard
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTerminal answers queries written to it by feeding canned replies back
+// into the handler's input, the way a real terminal would.
+type fakeTerminal struct {
+	pw      *io.PipeWriter
+	replies map[string]string // query -> reply
+}
+
+func (f *fakeTerminal) Write(p []byte) (int, error) {
+	var out strings.Builder
+	q := string(p)
+	for len(q) > 0 {
+		matched := false
+		for query, reply := range f.replies {
+			if strings.HasPrefix(q, query) {
+				out.WriteString(reply)
+				q = q[len(query):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			break
+		}
+	}
+	if out.Len() > 0 {
+		// Asynchronous, like a terminal: the handler is mid-Write here.
+		go f.pw.Write([]byte(out.String()))
+	}
+	return len(p), nil
+}
+
+// newQueryHandler starts a handler whose ModeWriter is a fakeTerminal
+func newQueryHandler(t *testing.T, replies map[string]string) (*Handler, *io.PipeWriter, func()) {
+	t.Helper()
+	ft := &fakeTerminal{replies: replies}
+	h, pw, cleanup := newPipedHandlerWith(t, Options{
+		ModeWriter:   ft,
+		QueryTimeout: 200 * time.Millisecond,
+	})
+	ft.pw = pw
+	return h, pw, cleanup
+}
+
+func TestQueryDeviceAttributes(t *testing.T) {
+	h, _, cleanup := newQueryHandler(t, map[string]string{
+		"\x1b[>c": "\x1b[>41;367;0c",
+		"\x1b[c":  "\x1b[?64;1;2;4;22c",
+	})
+	defer cleanup()
+
+	da, err := h.QueryDeviceAttributes()
+	if err != nil {
+		t.Fatalf("QueryDeviceAttributes: %v", err)
+	}
+	if da.Level != 64 || !da.HasFeature(4) || !da.HasSecondary || da.Type != 41 || da.Version != 367 {
+		t.Errorf("DeviceAttributes = %+v", da)
+	}
+	select {
+	case k := <-h.Keys:
+		t.Errorf("DA reply leaked as key %q", k)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestQueryDeviceAttributesTimeout(t *testing.T) {
+	h, _, cleanup := newQueryHandler(t, nil)
+	defer cleanup()
+
+	if _, err := h.QueryDeviceAttributes(); err != ErrQueryTimeout {
+		t.Errorf("err = %v, want ErrQueryTimeout", err)
+	}
+}
+
+// TestQueryCursorPosition: the CPR reply is returned to the caller, even for
+// row 1 (which is otherwise indistinguishable from a modified F3).
+func TestQueryCursorPosition(t *testing.T) {
+	h, _, cleanup := newQueryHandler(t, map[string]string{
+		"\x1b[6n": "\x1b[1;42R",
+	})
+	defer cleanup()
+
+	row, col, err := h.QueryCursorPosition()
+	if err != nil {
+		t.Fatalf("QueryCursorPosition: %v", err)
+	}
+	if row != 1 || col != 42 {
+		t.Errorf("position = (%d, %d), want (1, 42)", row, col)
+	}
+	select {
+	case k := <-h.Keys:
+		t.Errorf("CPR leaked as key %q", k)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestQueryTermcap: known capabilities come back decoded; unknown ones are
+// absent; the DA1 fence ends the wait without a timeout.
+func TestQueryTermcap(t *testing.T) {
+	h, _, cleanup := newQueryHandler(t, map[string]string{
+		"\x1bP+q6B63757531\x1b\\": "\x1bP1+r6B63757531=1B5B5A\x1b\\", // kcuu1 = ESC [ Z
+		"\x1bP+q666F6F\x1b\\":     "\x1bP0+r666F6F\x1b\\",            // foo: unknown
+		"\x1b[c":                  "\x1b[?62c",
+	})
+	defer cleanup()
+
+	caps, err := h.QueryTermcap("kcuu1", "foo")
+	if err != nil {
+		t.Fatalf("QueryTermcap: %v", err)
+	}
+	if len(caps) != 1 || caps["kcuu1"] != "\x1b[Z" {
+		t.Errorf("caps = %q, want kcuu1 only", caps)
+	}
+}
+
+func TestQueryMode(t *testing.T) {
+	h, _, cleanup := newQueryHandler(t, map[string]string{
+		"\x1b[?2004$p": "\x1b[?2004;2$y",
+		"\x1b[?1016$p": "\x1b[?1016;0$y",
+	})
+	defer cleanup()
+
+	st, err := h.QueryMode(ModeBracketedPaste)
+	if err != nil {
+		t.Fatalf("QueryMode: %v", err)
+	}
+	if st != ModeReset || !st.Supported() || st.Enabled() {
+		t.Errorf("bracketed paste state = %v, want ModeReset", st)
+	}
+	if st, err := h.QueryMode(1016); err != nil || st.Supported() {
+		t.Errorf("mode 1016 = (%v, %v), want ModeNotRecognized", st, err)
+	}
+}
+
+func TestQueryCellSize(t *testing.T) {
+	h, _, cleanup := newQueryHandler(t, map[string]string{
+		"\x1b[16t": "\x1b[6;18;9t",
+	})
+	defer cleanup()
+
+	w, ht, err := h.QueryCellSize()
+	if err != nil {
+		t.Fatalf("QueryCellSize: %v", err)
+	}
+	if w != 9 || ht != 18 {
+		t.Errorf("cell size = %dx%d, want 9x18", w, ht)
+	}
+	if cw, ch := h.CellSize(); cw != 9 || ch != 18 {
+		t.Errorf("CellSize() = %dx%d, want 9x18", cw, ch)
+	}
+}
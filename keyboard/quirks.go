@@ -0,0 +1,226 @@
+package keyboard
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// TerminalProfile describes a terminal family's sequences and quirks. The
+// handler picks one at Start (see Options.Terminal) and applies it: its
+// Bindings are added to the sequence table and, unless the application set
+// DecodeMacOSOption, its Option-key behavior is used.
+type TerminalProfile struct {
+	// Name identifies the profile ("iterm2", "apple-terminal", "kitty", ...)
+	Name string
+
+	// DecodeMacOSOption, if not nil, says whether the terminal delivers
+	// Option+key as composed characters (true) rather than ESC-prefixed
+	// Meta keys (false).
+	DecodeMacOSOption *bool
+
+	// KittyKeyboard is true if the terminal implements the kitty keyboard
+	// protocol (CSI u); applications can check it before pushing it.
+	KittyKeyboard bool
+
+	// Bindings are extra escape sequences this terminal sends, mapped to
+	// key names.
+	Bindings map[string]string
+}
+
+// Terminal returns the profile the handler is using.
+func (h *Handler) Terminal() TerminalProfile {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.profile
+}
+
+// rxvtBindings are the rxvt/urxvt sequences that differ from xterm's
+var rxvtBindings = map[string]string{
+	"\x1b[7~":  "Home",
+	"\x1b[8~":  "End",
+	"\x1b[11~": "F1",
+	"\x1b[12~": "F2",
+	"\x1b[13~": "F3",
+	"\x1b[14~": "F4",
+	"\x1b[28~": "S-F5",
+	"\x1b[29~": "S-F6",
+	"\x1b[a":   "S-Up",
+	"\x1b[b":   "S-Down",
+	"\x1b[c":   "S-Right",
+	"\x1b[d":   "S-Left",
+	"\x1bOa":   "C-Up",
+	"\x1bOb":   "C-Down",
+	"\x1bOc":   "C-Right",
+	"\x1bOd":   "C-Left",
+	"\x1b[7^":  "C-Home",
+	"\x1b[8^":  "C-End",
+	"\x1b[7$":  "S-Home",
+	"\x1b[8$":  "S-End",
+	"\x1b[2^":  "C-Insert",
+	"\x1b[3^":  "C-Delete",
+	"\x1b[5^":  "C-PageUp",
+	"\x1b[6^":  "C-PageDown",
+}
+
+// linuxBindings are the Linux console's function key sequences
+var linuxBindings = map[string]string{
+	"\x1b[[A": "F1",
+	"\x1b[[B": "F2",
+	"\x1b[[C": "F3",
+	"\x1b[[D": "F4",
+	"\x1b[[E": "F5",
+}
+
+var (
+	optionComposes = true
+	optionIsMeta   = false
+)
+
+// terminalProfiles is the quirk database, keyed by profile name
+var terminalProfiles = map[string]TerminalProfile{
+	// iTerm2 composes characters with Option unless "Option as Meta" is
+	// set, and supports CSI u.
+	"iterm2": {Name: "iterm2", DecodeMacOSOption: &optionComposes, KittyKeyboard: true},
+	// Terminal.app composes with Option by default and has no CSI u.
+	"apple-terminal": {Name: "apple-terminal", DecodeMacOSOption: &optionComposes},
+	// These send Option/Alt as Meta (ESC prefix), even on macOS.
+	"kitty":     {Name: "kitty", DecodeMacOSOption: &optionIsMeta, KittyKeyboard: true},
+	"wezterm":   {Name: "wezterm", DecodeMacOSOption: &optionIsMeta, KittyKeyboard: true},
+	"ghostty":   {Name: "ghostty", DecodeMacOSOption: &optionIsMeta, KittyKeyboard: true},
+	"foot":      {Name: "foot", KittyKeyboard: true},
+	"alacritty": {Name: "alacritty", KittyKeyboard: true},
+	"vscode":    {Name: "vscode"},
+	"rxvt":      {Name: "rxvt", Bindings: rxvtBindings},
+	"linux":     {Name: "linux", Bindings: linuxBindings},
+	"screen":    {Name: "screen"},
+	"tmux":      {Name: "tmux"},
+	"xterm":     {Name: "xterm"},
+}
+
+// TerminalProfileByName returns the built-in profile with the given name.
+func TerminalProfileByName(name string) (TerminalProfile, bool) {
+	p, ok := terminalProfiles[name]
+	return p, ok
+}
+
+// DetectTerminalProfile picks a profile from the environment: TERM_PROGRAM
+// first (it names the emulator even inside TERM=xterm-256color), then TERM.
+// Unknown terminals get an empty profile with Name "unknown".
+func DetectTerminalProfile() TerminalProfile {
+	return detectTerminalProfile(os.Getenv)
+}
+
+// isLocalTerminal reports whether r is a terminal of this process, whose
+// TERM and TERM_PROGRAM describe it
+func isLocalTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// TerminalProfileForTERM picks a profile from a TERM value alone, for
+// input that doesn't come from this process's terminal (e.g. the TERM sent
+// in an SSH pty request).
+func TerminalProfileForTERM(term string) TerminalProfile {
+	return detectTerminalProfile(func(name string) string {
+		if name == "TERM" {
+			return term
+		}
+		return ""
+	})
+}
+
+func detectTerminalProfile(getenv func(string) string) TerminalProfile {
+	switch getenv("TERM_PROGRAM") {
+	case "iTerm.app":
+		return terminalProfiles["iterm2"]
+	case "Apple_Terminal":
+		return terminalProfiles["apple-terminal"]
+	case "WezTerm":
+		return terminalProfiles["wezterm"]
+	case "ghostty":
+		return terminalProfiles["ghostty"]
+	case "vscode":
+		return terminalProfiles["vscode"]
+	case "tmux":
+		return terminalProfiles["tmux"]
+	}
+
+	term := getenv("TERM")
+	switch {
+	case term == "xterm-kitty":
+		return terminalProfiles["kitty"]
+	case term == "xterm-ghostty":
+		return terminalProfiles["ghostty"]
+	case strings.HasPrefix(term, "rxvt"):
+		return terminalProfiles["rxvt"]
+	case term == "linux":
+		return terminalProfiles["linux"]
+	case strings.HasPrefix(term, "foot"):
+		return terminalProfiles["foot"]
+	case term == "alacritty":
+		return terminalProfiles["alacritty"]
+	case strings.HasPrefix(term, "tmux"):
+		return terminalProfiles["tmux"]
+	case strings.HasPrefix(term, "screen"):
+		return terminalProfiles["screen"]
+	case strings.HasPrefix(term, "xterm"):
+		return terminalProfiles["xterm"]
+	}
+	return TerminalProfile{Name: "unknown"}
+}
+
+// da2Terminals maps DA2 terminal type codes that identify a specific
+// emulator (rather than just a VT model) to profile names
+var da2Terminals = map[int]string{
+	41: "xterm",
+	83: "screen", // 'S'
+	84: "tmux",   // 'T'
+	85: "rxvt",   // 'U'
+}
+
+// DetectTerminal refines the profile chosen at Start by asking the terminal
+// for its Device Attributes, which identifies some terminals even when TERM
+// is generic or wrong (e.g. over ssh). The environment still wins when it
+// names a known terminal. Returns the profile now in use.
+func (h *Handler) DetectTerminal() (TerminalProfile, error) {
+	da, err := h.QueryDeviceAttributes()
+	if err != nil {
+		return h.Terminal(), err
+	}
+	if h.Terminal().Name != "unknown" || !da.HasSecondary {
+		return h.Terminal(), nil
+	}
+	if p, ok := terminalProfiles[da2Terminals[da.Type]]; ok {
+		h.applyProfile(p)
+	}
+	return h.Terminal(), nil
+}
+
+// applyProfile switches to profile p: its bindings are added, and its Option
+// handling is used unless the application chose one explicitly
+func (h *Handler) applyProfile(p TerminalProfile) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.applyProfileLocked(p)
+}
+
+// applyProfileLocked is applyProfile - call only while holding h.mu
+func (h *Handler) applyProfileLocked(p TerminalProfile) {
+	h.profile = p
+	for seq, key := range p.Bindings {
+		if h.bindings == nil {
+			h.bindings = make(map[string]string)
+		}
+		if _, exists := h.bindings[seq]; !exists {
+			h.bindings[seq] = key
+		}
+	}
+	h.publishBindingsLocked()
+	if p.DecodeMacOSOption != nil && !h.macOSOptionExplicit {
+		h.decodeMacOSOption.Store(*p.DecodeMacOSOption)
+	}
+	h.debug("Terminal profile", "name", p.Name)
+}
@@ -0,0 +1,47 @@
+package keyboard
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetectTerminalProfile(t *testing.T) {
+	cases := []struct {
+		termProgram, term, want string
+	}{
+		{"iTerm.app", "xterm-256color", "iterm2"},
+		{"Apple_Terminal", "xterm-256color", "apple-terminal"},
+		{"", "xterm-kitty", "kitty"},
+		{"", "rxvt-unicode-256color", "rxvt"},
+		{"", "screen.xterm-256color", "screen"},
+		{"", "dumb", "unknown"},
+	}
+	for _, c := range cases {
+		env := map[string]string{"TERM_PROGRAM": c.termProgram, "TERM": c.term}
+		got := detectTerminalProfile(func(k string) string { return env[k] })
+		if got.Name != c.want {
+			t.Errorf("TERM_PROGRAM=%q TERM=%q: profile %q, want %q", c.termProgram, c.term, got.Name, c.want)
+		}
+	}
+}
+
+// TestProfileBindings: a profile's sequences are parsed as keys.
+func TestProfileBindings(t *testing.T) {
+	rxvt, _ := TerminalProfileByName("rxvt")
+	h, pw, cleanup := newPipedHandlerWith(t, Options{Terminal: &rxvt})
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("\x1b[7~\x1bOa\x1b[29~")); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Home", "C-Up", "S-F6"} {
+		select {
+		case k := <-h.Keys:
+			if k != want {
+				t.Errorf("key = %q, want %q", k, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("key %q never arrived", want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package keyboard
+
+import (
+	"strings"
+	"time"
+)
+
+// keyClass is the class of key that Options.RateLimits is keyed by: the
+// key name without modifiers, mouse position, or scroll count, e.g.
+// "MouseScrollUp" for "C-MouseScrollUp@10,4"
+func keyClass(key string) string {
+	k := ParseKeyEvent(key).Key
+	if i := strings.IndexAny(k, "@:"); i > 0 {
+		k = k[:i]
+	}
+	return k
+}
+
+// isMouseKey reports whether key is one of the legacy mouse keys, which
+// emitMouseEvent rate limits as a whole event
+func isMouseKey(key string) bool {
+	class := keyClass(key)
+	return strings.HasPrefix(class, "Mouse") || strings.HasPrefix(class, "Region")
+}
+
+// mouseActionKey picks the key that names a mouse event's action, skipping
+// the position key that precedes it
+func mouseActionKey(keys []string) string {
+	for _, k := range keys {
+		if keyClass(k) != "Mouse" {
+			return k
+		}
+	}
+	return keys[0]
+}
+
+// limitKey reports whether key is to be dropped under Options.Debounce or
+// Options.RateLimits, and otherwise records it as delivered. Only keyboard
+// keys are debounced. Called on the processing goroutine.
+func (h *Handler) limitKey(key string, mouse bool) bool {
+	now := time.Now()
+	if !mouse && h.debounce > 0 && key == h.debounceKey && now.Sub(h.debounceTime) < h.debounce {
+		return true
+	}
+	class := keyClass(key)
+	interval, capped := h.rateLimits[class]
+	if capped {
+		if last, ok := h.rateLast[class]; ok && now.Sub(last) < interval {
+			return true
+		}
+		h.rateLast[class] = now
+	}
+	if !mouse && h.debounce > 0 {
+		h.debounceKey, h.debounceTime = key, now
+	}
+	return false
+}
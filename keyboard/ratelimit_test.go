@@ -0,0 +1,48 @@
+package keyboard
+
+import (
+	"testing"
+	"time"
+)
+
+// TestDebounce: a key repeating the previous one within the window is
+// dropped; other keys, and a key after a different one, are not.
+func TestDebounce(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{Debounce: time.Hour})
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("aabba\x1b[A\x1b[Az")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "a", "b", "a", "Up", "z")
+}
+
+// TestRateLimits: a capped class gets one key per interval, whole mouse
+// events included; other classes are untouched.
+func TestRateLimits(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{RateLimits: map[string]time.Duration{
+		"MouseScrollUp": time.Hour,
+		"Down":          time.Hour,
+	}})
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("\x1b[<64;3;5M\x1b[<64;4;5M\x1b[<65;4;5M\x1b[B\x1b[1;5B\x1b[Axx")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "Mouse@3,5", "MouseScrollUp", "Mouse@4,5", "MouseScrollDown", "Down", "Up", "x", "x")
+}
+
+func TestKeyClass(t *testing.T) {
+	for key, want := range map[string]string{
+		"a":                     "a",
+		"@":                     "@",
+		"C-Up":                  "Up",
+		"M-S-MouseScrollUp@1,2": "MouseScrollUp",
+		"MouseScrollDown:3":     "MouseScrollDown",
+		"Mouse@3,5":             "Mouse",
+	} {
+		if got := keyClass(key); got != want {
+			t.Errorf("keyClass(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
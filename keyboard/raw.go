@@ -0,0 +1,45 @@
+package keyboard
+
+import (
+	"log/slog"
+	"time"
+)
+
+// RawEvent pairs the exact input bytes of one parsed unit - a key, an escape
+// sequence, a mouse report, a whole bracketed paste or OSC 52 response, or an
+// unparseable sequence - with the keys the parser produced from them.
+type RawEvent struct {
+	Bytes   []byte   // Input bytes exactly as read
+	Keys    []string // Keys parsed from Bytes, before OnKeyFilter/middleware (may be empty)
+	Unknown bool     // True if Bytes was an escape sequence that could not be parsed
+}
+
+// feed runs a chunk of input through the parser a run at a time (see
+// runLength), recording the bytes for the RawEvents channel when enabled
+func (h *Handler) feed(data []byte, escTimeout *time.Timer) {
+	for len(data) > 0 {
+		n := h.runLength(data)
+		if h.RawEvents != nil {
+			h.rawPending = append(h.rawPending, data[:n]...)
+		}
+		h.processRun(data[:n], escTimeout)
+		if h.RawEvents != nil && h.state == stateGround && h.utf8Remaining == 0 {
+			h.flushRawEvent()
+		}
+		data = data[n:]
+	}
+}
+
+// flushRawEvent emits the bytes consumed since the last event boundary
+func (h *Handler) flushRawEvent() {
+	if h.RawEvents == nil || len(h.rawPending) == 0 {
+		return
+	}
+	ev := RawEvent{Bytes: h.rawPending, Keys: h.rawKeys, Unknown: h.rawUnknown}
+	h.rawPending = nil
+	h.rawKeys = nil
+	h.rawUnknown = false
+	sendWithPolicy(h.RawEvents, ev, h.keyOverflow, h.stopChan, func(dropped RawEvent) {
+		h.logAt(slog.LevelWarn, "RawEvents channel full, event dropped", "bytes", dropped.Bytes)
+	})
+}
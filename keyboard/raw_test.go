@@ -0,0 +1,34 @@
+package keyboard
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestRawEvents: each parsed unit is reported with its exact input bytes and
+// the keys produced from them.
+func TestRawEvents(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{EmitRawEvents: true})
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("\x1b[Ab\xc3\xa9")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []RawEvent{
+		{Bytes: []byte("\x1b[A"), Keys: []string{"Up"}},
+		{Bytes: []byte("b"), Keys: []string{"b"}},
+		{Bytes: []byte("\xc3\xa9"), Keys: []string{"é"}},
+	}
+	for _, w := range want {
+		select {
+		case ev := <-h.RawEvents:
+			if !reflect.DeepEqual(ev, w) {
+				t.Errorf("raw event = %+v, want %+v", ev, w)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("raw event %q never arrived", w.Bytes)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package keyboard
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrStopped is returned by the blocking reads (ReadKey, Select, ...) when
+// the handler is stopped while they wait.
+var ErrStopped = errors.New("handler stopped")
+
+// ErrSimple is returned by ReadLine in Simple mode, which has no line
+// assembly.
+var ErrSimple = errors.New("line mode unavailable in Simple mode")
+
+// Done returns a channel that is closed when the handler is stopped, for
+// loops that select on Keys alongside other channels.
+func (h *Handler) Done() <-chan struct{} {
+	return h.stopChan
+}
+
+// ReadKey waits for the next key on Keys and returns it, or returns the
+// last key pushed back with UnreadKey. It returns ErrStopped if the
+// handler is stopped first.
+func (h *Handler) ReadKey() (string, error) {
+	return h.ReadKeyContext(context.Background())
+}
+
+// ReadKeyContext is ReadKey, giving up with ctx.Err() when ctx is done.
+// The handler keeps running, and a key that arrives later stays on Keys.
+func (h *Handler) ReadKeyContext(ctx context.Context) (string, error) {
+	if key, ok := h.popUnread(); ok {
+		return key, nil
+	}
+	select {
+	case key := <-h.Keys:
+		return key, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-h.stopChan:
+		return "", ErrStopped
+	}
+}
+
+// TryReadKey returns the next key if one is waiting (pushed back with
+// UnreadKey, or on Keys) and returns at once either way, for game loops
+// that poll input once per frame.
+func (h *Handler) TryReadKey() (string, bool) {
+	if key, ok := h.popUnread(); ok {
+		return key, true
+	}
+	select {
+	case key := <-h.Keys:
+		return key, true
+	default:
+		return "", false
+	}
+}
+
+// Pending returns the number of events waiting to be read: keys pushed
+// back with UnreadKey, and keys, lines, key events, and mouse events
+// queued on Keys, Lines, Events, and Mouse.
+func (h *Handler) Pending() int {
+	h.mu.Lock()
+	n := len(h.unread)
+	h.mu.Unlock()
+	n += len(h.Keys) + len(h.Lines)
+	if h.Events != nil {
+		n += len(h.Events)
+	}
+	if h.Mouse != nil {
+		n += len(h.Mouse)
+	}
+	return n
+}
+
+// Flush discards the events Pending counts, e.g. keystrokes typed during a
+// long operation that shouldn't trigger actions once it is done. It
+// returns how many were discarded. Pastes and raw events are left alone.
+func (h *Handler) Flush() int {
+	h.mu.Lock()
+	n := len(h.unread)
+	h.unread = nil
+	h.mu.Unlock()
+	n += drain(h.Keys) + drain(h.Lines)
+	if h.Events != nil {
+		n += drain(h.Events)
+	}
+	if h.Mouse != nil {
+		n += drain(h.Mouse)
+	}
+	if n > 0 {
+		h.debug("Flushed pending events", "count", n)
+	}
+	return n
+}
+
+// drain empties ch of what is buffered in it and returns how many items
+// that was
+func drain[T any](ch chan T) int {
+	n := 0
+	for {
+		select {
+		case <-ch:
+			n++
+		default:
+			return n
+		}
+	}
+}
+
+// PeekKey waits for the next key like ReadKey but leaves it to be read
+// again, for parsers that look ahead one key.
+func (h *Handler) PeekKey() (string, error) {
+	return h.PeekKeyContext(context.Background())
+}
+
+// PeekKeyContext is PeekKey, giving up with ctx.Err() when ctx is done.
+func (h *Handler) PeekKeyContext(ctx context.Context) (string, error) {
+	key, err := h.ReadKeyContext(ctx)
+	if err == nil {
+		h.UnreadKey(key)
+	}
+	return key, err
+}
+
+// UnreadKey pushes key back to be returned by the next ReadKey (or
+// PeekKey), e.g. when a vi-style count and motion grammar doesn't match.
+// Keys pushed back are read last in, first out, ahead of Keys; they are
+// not seen by code receiving from the Keys channel directly.
+func (h *Handler) UnreadKey(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.unread = append(h.unread, key)
+}
+
+// ReadLine waits for the next line on Lines and returns it, switching line
+// mode on for the read if it is off. It returns ErrStopped if the handler
+// is stopped first.
+func (h *Handler) ReadLine() ([]byte, error) {
+	return h.ReadLineContext(context.Background())
+}
+
+// ReadLineContext is ReadLine, giving up with ctx.Err() when ctx is done.
+// If line mode was already on, what was typed of the line stays for the
+// next read; otherwise line mode goes back off and the partial line is
+// discarded.
+func (h *Handler) ReadLineContext(ctx context.Context) ([]byte, error) {
+	if h.simple {
+		return nil, ErrSimple
+	}
+	if !h.IsLineMode() {
+		h.SetLineMode(true)
+		defer h.SetLineMode(false)
+	}
+	select {
+	case line := <-h.Lines:
+		return line, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-h.stopChan:
+		return nil, ErrStopped
+	}
+}
+
+// popUnread takes the last key pushed back with UnreadKey, if any
+func (h *Handler) popUnread() (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := len(h.unread)
+	if n == 0 {
+		return "", false
+	}
+	key := h.unread[n-1]
+	h.unread = h.unread[:n-1]
+	return key, true
+}
@@ -0,0 +1,160 @@
+package keyboard
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestReadKeyContext: a canceled read returns the context's error and
+// leaves the handler running; a stopped handler returns ErrStopped.
+func TestReadKeyContext(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := h.ReadKeyContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ReadKeyContext = %v, want DeadlineExceeded", err)
+	}
+
+	if _, err := pw.Write([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if key, err := h.ReadKey(); key != "a" || err != nil {
+		t.Fatalf("ReadKey = %q, %v; want a", key, err)
+	}
+
+	h.Stop()
+	if _, err := h.ReadKey(); !errors.Is(err, ErrStopped) {
+		t.Errorf("ReadKey after Stop = %v, want ErrStopped", err)
+	}
+}
+
+// TestReadLineContext: ReadLine turns line mode on for the read and back
+// off after, canceled or not.
+func TestReadLineContext(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+
+	go pw.Write([]byte("hi\r"))
+	if line, err := h.ReadLine(); string(line) != "hi" || err != nil {
+		t.Fatalf("ReadLine = %q, %v; want hi", line, err)
+	}
+	if h.IsLineMode() {
+		t.Error("line mode left on")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := h.ReadLineContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("ReadLineContext = %v, want Canceled", err)
+	}
+	if h.IsLineMode() {
+		t.Error("line mode left on after cancel")
+	}
+}
+
+// TestSelectContext: a canceled Select clears its menu.
+func TestSelectContext(t *testing.T) {
+	echo := &echoBuffer{}
+	h, _, cleanup := newPipedHandlerWith(t, Options{EchoWriter: echo})
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if i, err := h.SelectContext(ctx, "Pick:", []string{"a", "b"}); i != -1 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("SelectContext = %d, %v; want -1, DeadlineExceeded", i, err)
+	}
+	if out := echo.take(); len(out) < 4 || out[len(out)-4:] != "\r\x1b[J" {
+		t.Errorf("menu not cleared: %q", out)
+	}
+	if ok, err := h.ConfirmContext(ctx, "Sure?", true); ok || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ConfirmContext = %v, %v; want false, DeadlineExceeded", ok, err)
+	}
+}
+
+// TestPeekUnread: PeekKey leaves the key to be read, and unread keys come
+// back last in, first out, ahead of new input.
+func TestPeekUnread(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("3w")); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"3", "3"} {
+		if key, err := h.PeekKey(); key != want || err != nil {
+			t.Fatalf("PeekKey = %q, %v; want %q", key, err, want)
+		}
+	}
+	h.UnreadKey("2")
+	h.UnreadKey("1")
+	for _, want := range []string{"1", "2", "3", "w"} {
+		if key, err := h.ReadKey(); key != want || err != nil {
+			t.Fatalf("ReadKey = %q, %v; want %q", key, err, want)
+		}
+	}
+}
+
+// TestTryReadKey: TryReadKey returns waiting keys, pushed back ones first,
+// and reports none without blocking.
+func TestTryReadKey(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+
+	if key, ok := h.TryReadKey(); ok {
+		t.Fatalf("TryReadKey = %q with no input", key)
+	}
+	if _, err := pw.Write([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	h.UnreadKey("z")
+	if key, ok := h.TryReadKey(); key != "z" || !ok {
+		t.Fatalf("TryReadKey = %q, %v; want z", key, ok)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		key, ok := h.TryReadKey()
+		if ok {
+			if key != "a" {
+				t.Errorf("TryReadKey = %q, want a", key)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("key never arrived")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestPendingFlush: queued keys and lines are counted and discarded.
+func TestPendingFlush(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	h.UnreadKey("z")
+	deadline := time.Now().Add(2 * time.Second)
+	for h.Pending() < 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Pending = %d, want 4", h.Pending())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := h.Flush(); n != 4 {
+		t.Errorf("Flush = %d, want 4", n)
+	}
+	if n := h.Pending(); n != 0 {
+		t.Errorf("Pending after Flush = %d", n)
+	}
+
+	if _, err := pw.Write([]byte("d")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "d")
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package keyboard
+
+// waitReadable waits out a terminal release. Without poll, a Read already in
+// progress when the terminal is released cannot be withdrawn. Returns false
+// if the handler was stopped.
+func (h *Handler) waitReadable() bool {
+	return h.waitAcquired()
+}
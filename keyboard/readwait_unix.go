@@ -0,0 +1,39 @@
+//go:build unix
+
+package keyboard
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// readPollInterval bounds how long waitReadable sleeps in poll before
+// re-checking whether the terminal has been released.
+const readPollInterval = 50 // milliseconds
+
+// waitReadable blocks until input is available to read without stealing it
+// from a child program. For a terminal we manage, it polls the descriptor and
+// only reports ready while the terminal is acquired; other readers just wait
+// out a release. Returns false if the handler was stopped.
+func (h *Handler) waitReadable() bool {
+	for {
+		if !h.waitAcquired() {
+			return false
+		}
+		if !h.managesTerminal {
+			return true
+		}
+		fds := []unix.PollFd{{Fd: int32(h.terminalFd), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, readPollInterval)
+		if err != nil && err != unix.EINTR {
+			return true // let Read surface the error
+		}
+		if n > 0 && !h.IsTerminalReleased() {
+			return true
+		}
+		select {
+		case <-h.stopChan:
+			return false
+		default:
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package keyboard
+
+// Region is a named rectangle of terminal cells, in the same coordinates
+// as the Mouse@x,y keys (see Options.MouseZeroBased).
+type Region struct {
+	Name          string
+	X, Y          int // Top-left cell
+	Width, Height int
+}
+
+// Contains reports whether the cell x,y lies inside the region.
+func (r Region) Contains(x, y int) bool {
+	return x >= r.X && x < r.X+r.Width && y >= r.Y && y < r.Y+r.Height
+}
+
+// SetRegion registers a hit region, replacing any region with the same
+// name. A button press inside it emits Region:name:action after the usual
+// mouse keys, e.g. "Region:save:MouseLeftPress", so widgets can react to
+// clicks without hit-testing coordinates themselves. Where regions
+// overlap, the most recently set one wins.
+func (h *Handler) SetRegion(r Region) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeRegionLocked(r.Name)
+	h.regions = append(h.regions, r)
+}
+
+// RemoveRegion unregisters the named hit region.
+func (h *Handler) RemoveRegion(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeRegionLocked(name)
+}
+
+// ClearRegions unregisters all hit regions, e.g. before a full redraw.
+func (h *Handler) ClearRegions() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.regions = nil
+}
+
+// Regions returns the registered hit regions, oldest first.
+func (h *Handler) Regions() []Region {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Region(nil), h.regions...)
+}
+
+// removeRegionLocked deletes the named region - call only while holding h.mu
+func (h *Handler) removeRegionLocked(name string) {
+	for i, r := range h.regions {
+		if r.Name == name {
+			h.regions = append(h.regions[:i], h.regions[i+1:]...)
+			return
+		}
+	}
+}
+
+// regionAt returns the name of the topmost region containing x,y
+func (h *Handler) regionAt(x, y int) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := len(h.regions) - 1; i >= 0; i-- {
+		if h.regions[i].Contains(x, y) {
+			return h.regions[i].Name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,26 @@
+package keyboard
+
+import "testing"
+
+// TestRegions: a press inside a region is followed by a Region key naming
+// the topmost region; presses outside, and releases, are not.
+func TestRegions(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+	h.SetRegion(Region{Name: "panel", X: 1, Y: 1, Width: 20, Height: 10})
+	h.SetRegion(Region{Name: "save", X: 2, Y: 3, Width: 6, Height: 1})
+
+	if _, err := pw.Write([]byte("\x1b[<0;4;3M\x1b[<0;4;3m\x1b[<16;10;5M\x1b[<0;30;5M")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h,
+		"Mouse@4,3", "MouseLeftPress", "Region:save:MouseLeftPress",
+		"Mouse@4,3", "MouseLeftRelease",
+		"Mouse@10,5", "C-MouseLeftPress", "Region:panel:C-MouseLeftPress",
+		"Mouse@30,5", "MouseLeftPress")
+
+	h.RemoveRegion("save")
+	if got := h.Regions(); len(got) != 1 || got[0].Name != "panel" {
+		t.Errorf("Regions() = %v, want only panel", got)
+	}
+}
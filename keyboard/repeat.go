@@ -0,0 +1,53 @@
+package keyboard
+
+import (
+	"strconv"
+	"strings"
+)
+
+// coalesceRepeat adds key to the pending repeat burst if it can be
+// coalesced, and reports whether it was. A key that can't, or a different
+// key, flushes the burst first. As with scroll bursts, the window starts
+// at the first key.
+func (h *Handler) coalesceRepeat(key string) bool {
+	base := strings.TrimSuffix(key, ":Repeat")
+	// Line mode needs every character, mouse keys have ScrollCoalesce, and
+	// releases and modifier events (S-Press:Left) pass through
+	i := strings.IndexByte(base, ':')
+	if h.inLineReadMode.Load() || isMouseKey(key) || (i > 0 && i < len(base)-1) {
+		h.flushRepeat()
+		return false
+	}
+	if h.repeatCount > 0 && h.repeatKey != base {
+		h.flushRepeat()
+	}
+	h.repeatCount++
+	if h.repeatCount == 1 {
+		h.repeatKey = base
+		h.repeatGen++
+		gen := h.repeatGen
+		h.after(h.repeatCoalesce, func() {
+			if h.repeatGen == gen {
+				h.flushRepeat()
+			}
+		})
+	}
+	return true
+}
+
+// flushRepeat emits the pending repeat burst as its key, suffixed with
+// ":N" when it stands for N > 1 presses
+func (h *Handler) flushRepeat() {
+	if h.repeatCount == 0 {
+		return
+	}
+	key := h.repeatKey
+	if h.repeatCount > 1 {
+		key += ":" + strconv.Itoa(h.repeatCount)
+	}
+	h.repeatCount = 0
+	h.repeatGen++
+	h.repeatFlushing = true
+	h.emitKey(key)
+	h.repeatFlushing = false
+}
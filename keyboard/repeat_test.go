@@ -0,0 +1,50 @@
+package keyboard
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRepeatCoalesce: a burst of one key, kitty :Repeat events included,
+// becomes one key with a count; a different key or a release ends it.
+func TestRepeatCoalesce(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{RepeatCoalesce: 50 * time.Millisecond, EventChannel: true})
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("\x1b[B\x1b[B\x1b[Bx\x1b[97u\x1b[97;1:2u\x1b[97;1:2u\x1b[97;1:3u")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "Down:3", "x", "a:3", "a:Release")
+
+	for _, want := range []struct {
+		key   string
+		count int
+	}{{"Down", 3}, {"x", 1}, {"a", 3}} {
+		select {
+		case ev := <-h.Events:
+			if ev.Key != want.key || ev.Count != want.count {
+				t.Errorf("event = %+v, want %s x%d", ev, want.key, want.count)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("no KeyEvent arrived")
+		}
+	}
+
+	// The window closes on its own
+	if _, err := pw.Write([]byte("jj")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "j:2")
+}
+
+// TestRepeatCoalesceLineMode: line mode gets every character.
+func TestRepeatCoalesceLineMode(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{RepeatCoalesce: time.Hour})
+	defer cleanup()
+	h.SetLineMode(true)
+
+	if _, err := pw.Write([]byte("aaa\r")); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, h, "aaa")
+}
@@ -0,0 +1,98 @@
+package keyboard
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// byteRing is the input queue between the read loop (and Feed) and the
+// processing goroutine: a fixed ring of bytes with atomic read and write
+// positions, so handing input over allocates nothing and the consumer never
+// takes a lock. Producers are serialized by wmu (Feed may be called from
+// any goroutine); there is a single consumer. ready and space are one-slot
+// wakeups for a consumer waiting on an empty ring and a producer waiting on
+// a full one.
+type byteRing struct {
+	buf  []byte
+	mask uint64
+	head atomic.Uint64 // total bytes consumed
+	tail atomic.Uint64 // total bytes written
+
+	wmu   sync.Mutex
+	ready chan struct{}
+	space chan struct{}
+}
+
+// newByteRing returns a ring holding at least size bytes (rounded up to a
+// power of two)
+func newByteRing(size int) *byteRing {
+	n := 1
+	for n < size {
+		n <<= 1
+	}
+	return &byteRing{
+		buf:   make([]byte, n),
+		mask:  uint64(n - 1),
+		ready: make(chan struct{}, 1),
+		space: make(chan struct{}, 1),
+	}
+}
+
+// write copies p into the ring, waiting for room as needed. Returns false
+// if stop closed before all of p was written.
+func (r *byteRing) write(p []byte, stop <-chan struct{}) bool {
+	r.wmu.Lock()
+	defer r.wmu.Unlock()
+	for len(p) > 0 {
+		tail := r.tail.Load()
+		free := uint64(len(r.buf)) - (tail - r.head.Load())
+		if free == 0 {
+			select {
+			case <-r.space:
+				continue
+			case <-stop:
+				return false
+			}
+		}
+		// Copy up to the free space, in two pieces if it wraps
+		n := uint64(len(p))
+		if n > free {
+			n = free
+		}
+		start := tail & r.mask
+		c := copy(r.buf[start:], p[:n])
+		copy(r.buf, p[c:n])
+		r.tail.Store(tail + n)
+		p = p[n:]
+		wake(r.ready)
+	}
+	return true
+}
+
+// pending returns the unread bytes as up to two slices (two when they wrap
+// around the end of the ring). They stay valid until release.
+func (r *byteRing) pending() ([]byte, []byte) {
+	head, tail := r.head.Load(), r.tail.Load()
+	if head == tail {
+		return nil, nil
+	}
+	start, end := head&r.mask, tail&r.mask
+	if start < end {
+		return r.buf[start:end], nil
+	}
+	return r.buf[start:], r.buf[:end]
+}
+
+// release marks n bytes returned by pending as consumed
+func (r *byteRing) release(n int) {
+	r.head.Add(uint64(n))
+	wake(r.space)
+}
+
+// wake wakes the waiter on a one-slot wakeup channel, if any
+func wake(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,64 @@
+package keyboard
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// TestByteRingWraps: data written across the end of the ring comes back in
+// order as two pieces, and a writer blocked on a full ring resumes once the
+// reader releases space.
+func TestByteRingWraps(t *testing.T) {
+	r := newByteRing(8)
+	stop := make(chan struct{})
+
+	r.write([]byte("abcdef"), stop)
+	first, second := r.pending()
+	if string(first) != "abcdef" || second != nil {
+		t.Fatalf("pending = %q, %q", first, second)
+	}
+	r.release(len(first))
+
+	done := make(chan bool)
+	go func() { done <- r.write([]byte("ghijklmnop"), stop) }()
+	select {
+	case <-done:
+		t.Fatal("write of 10 bytes into an 8-byte ring did not block")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	var got []byte
+	for len(got) < 10 {
+		first, second := r.pending()
+		got = append(got, first...)
+		got = append(got, second...)
+		r.release(len(first) + len(second))
+		if len(first)+len(second) == 0 {
+			<-r.ready
+		}
+	}
+	if !<-done {
+		t.Fatal("write reported stop")
+	}
+	if !bytes.Equal(got, []byte("ghijklmnop")) {
+		t.Errorf("read %q, want %q", got, "ghijklmnop")
+	}
+}
+
+// TestByteRingStop: a writer waiting for space gives up when stop closes.
+func TestByteRingStop(t *testing.T) {
+	r := newByteRing(4)
+	stop := make(chan struct{})
+	done := make(chan bool)
+	go func() { done <- r.write([]byte("too long"), stop) }()
+	close(stop)
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("write succeeded without a reader")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("write did not return after stop")
+	}
+}
@@ -0,0 +1,62 @@
+package keyboard
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// SetSecret switches line mode's secret input on or off, for passwords and
+// other credentials. While it is on, the line is echoed as one mask
+// character per character typed (or not at all if mask is 0), keys and
+// lines are left out of subscriptions (Subscribe) and debug logging, and
+// the line buffer is zeroed as the line is edited and once it has been
+// delivered. The delivered line itself belongs to the application, which
+// should zero it after use.
+func (h *Handler) SetSecret(enabled bool, mask rune) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !enabled {
+		h.secret.Store(false)
+		return
+	}
+	h.secret.Store(true)
+	h.secretMask = mask
+}
+
+// IsSecret returns true while secret input is on (see SetSecret).
+func (h *Handler) IsSecret() bool {
+	return h.secret.Load()
+}
+
+// shownLocked returns what echoing line text b shows: b itself, or under
+// SetSecret its mask characters - call only while holding h.mu
+func (h *Handler) shownLocked(b []byte) []byte {
+	if !h.secret.Load() {
+		return b
+	}
+	if h.secretMask == 0 {
+		return nil
+	}
+	return []byte(strings.Repeat(string(h.secretMask), utf8.RuneCount(b)))
+}
+
+// growLineLocked makes room for n more bytes in the line being edited. Under
+// SetSecret, a buffer that is outgrown is zeroed rather than left to the
+// garbage collector - call only while holding h.mu
+func (h *Handler) growLineLocked(n int) {
+	if !h.secret.Load() || len(h.currentLine)+n <= cap(h.currentLine) {
+		return
+	}
+	grown := make([]byte, len(h.currentLine), 2*cap(h.currentLine)+n)
+	copy(grown, h.currentLine)
+	clear(h.currentLine[:cap(h.currentLine)])
+	h.currentLine = grown
+}
+
+// scrubLineLocked zeroes the unused end of the line buffer under SetSecret,
+// where deleted characters are left - call only while holding h.mu
+func (h *Handler) scrubLineLocked() {
+	if h.secret.Load() {
+		clear(h.currentLine[len(h.currentLine):cap(h.currentLine)])
+	}
+}
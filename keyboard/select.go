@@ -0,0 +1,156 @@
+package keyboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrCanceled is returned by the interactive helpers (Select, Confirm) when
+// the user cancels with Escape or Ctrl+C.
+var ErrCanceled = errors.New("canceled")
+
+// selectRows is the most options Select shows at once; the list scrolls to
+// keep the selection in view
+const selectRows = 10
+
+// Select shows prompt and a list of options below it and lets the user
+// pick one with the keys: Up/Down (or k/j, ^P/^N) move, Enter chooses, and
+// Escape or ^C cancels with ErrCanceled. Typing other characters filters
+// the list to the options containing them (ignoring case); once a filter
+// is typed, j and k are part of it, and Backspace edits it. Select returns
+// the index of the chosen option in options.
+//
+// It draws on the EchoWriter (or the ModeWriter if there is none) and
+// reads keys with ReadKey, so nothing else may read Keys meanwhile; line
+// mode is switched off while it runs and restored after.
+func (h *Handler) Select(prompt string, options []string) (int, error) {
+	return h.SelectContext(context.Background(), prompt, options)
+}
+
+// SelectContext is Select, giving up with ctx.Err() when ctx is done, e.g.
+// when a network event makes the question moot. The menu is cleared as on
+// Escape.
+func (h *Handler) SelectContext(ctx context.Context, prompt string, options []string) (int, error) {
+	if len(options) == 0 {
+		return -1, errors.New("no options to select from")
+	}
+	if h.IsLineMode() {
+		h.SetLineMode(false)
+		defer h.SetLineMode(true)
+	}
+	w := h.interactiveWriter()
+
+	var filter []rune
+	visible := make([]int, len(options)) // indices of the options shown
+	for i := range visible {
+		visible[i] = i
+	}
+	sel := 0
+	for {
+		drawSelect(w, prompt, string(filter), options, visible, sel)
+
+		key, err := h.ReadKeyContext(ctx)
+		if err != nil {
+			io.WriteString(w, "\r\x1b[J")
+			return -1, err
+		}
+		switch {
+		case key == "Up" || key == "^P" || (key == "k" && len(filter) == 0):
+			if sel > 0 {
+				sel--
+			}
+		case key == "Down" || key == "^N" || (key == "j" && len(filter) == 0):
+			if sel < len(visible)-1 {
+				sel++
+			}
+		case key == "Enter":
+			if len(visible) == 0 {
+				continue
+			}
+			choice := visible[sel]
+			fmt.Fprintf(w, "\r\x1b[J%s %s\r\n", prompt, options[choice])
+			return choice, nil
+		case key == "Escape" || key == "^C":
+			io.WriteString(w, "\r\x1b[J")
+			return -1, ErrCanceled
+		case key == "Backspace":
+			if len(filter) > 0 {
+				filter = filter[:len(filter)-1]
+				visible, sel = filterOptions(options, string(filter)), 0
+			}
+		case utf8.RuneCountInString(key) == 1 || key == "Space":
+			r, _ := utf8.DecodeRuneInString(key)
+			if key == "Space" {
+				r = ' '
+			}
+			if r < 32 {
+				continue
+			}
+			filter = append(filter, r)
+			visible, sel = filterOptions(options, string(filter)), 0
+		}
+	}
+}
+
+// filterOptions returns the indices of the options containing filter,
+// ignoring case
+func filterOptions(options []string, filter string) []int {
+	filter = strings.ToLower(filter)
+	var visible []int
+	for i, o := range options {
+		if strings.Contains(strings.ToLower(o), filter) {
+			visible = append(visible, i)
+		}
+	}
+	return visible
+}
+
+// drawSelect renders Select's prompt line and the list below it, over the
+// previous rendering, leaving the cursor at the end of the prompt line
+func drawSelect(w io.Writer, prompt, filter string, options []string, visible []int, sel int) {
+	var b strings.Builder
+	b.WriteString("\r\x1b[J")
+	b.WriteString(prompt)
+	b.WriteString(" ")
+	b.WriteString(filter)
+
+	first := max(0, sel-selectRows+1)
+	last := min(len(visible), first+selectRows)
+	for i := first; i < last; i++ {
+		if i == sel {
+			b.WriteString("\r\n\x1b[7m> " + options[visible[i]] + "\x1b[m")
+		} else {
+			b.WriteString("\r\n  " + options[visible[i]])
+		}
+	}
+	// Back to the end of the prompt line, where typing goes
+	b.WriteString(cursorUp(last - first))
+	fmt.Fprintf(&b, "\r\x1b[%dC", textWidth([]byte(prompt))+1+textWidth([]byte(filter)))
+	io.WriteString(w, b.String())
+}
+
+// cursorUp returns the escape that moves the cursor n rows up
+func cursorUp(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("\x1b[%dA", n)
+}
+
+// interactiveWriter returns where the interactive helpers draw: the echo
+// writer, else the mode writer, else nowhere
+func (h *Handler) interactiveWriter() io.Writer {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch {
+	case h.echoWriter != nil:
+		return h.echoWriter
+	case h.modeWriter != nil:
+		return h.modeWriter
+	}
+	return io.Discard
+}
@@ -0,0 +1,117 @@
+package keyboard
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// selectResult is what Select returned
+type selectResult struct {
+	choice int
+	err    error
+}
+
+// runSelect runs Select in the background
+func runSelect(h *Handler, options ...string) <-chan selectResult {
+	done := make(chan selectResult, 1)
+	go func() {
+		choice, err := h.Select("Pick:", options)
+		done <- selectResult{choice, err}
+	}()
+	return done
+}
+
+// awaitSelect waits for Select to return
+func awaitSelect(t *testing.T, done <-chan selectResult) selectResult {
+	t.Helper()
+	select {
+	case r := <-done:
+		return r
+	case <-time.After(2 * time.Second):
+		t.Fatal("Select never returned")
+	}
+	return selectResult{}
+}
+
+// TestSelect: arrows and j/k move the selection, typing filters the list,
+// Enter chooses, and Escape cancels.
+func TestSelect(t *testing.T) {
+	echo := &echoBuffer{}
+	h, pw, cleanup := newPipedHandlerWith(t, Options{EchoWriter: echo})
+	defer cleanup()
+	options := []string{"apple", "banana", "cherry", "blueberry"}
+
+	done := runSelect(h, options...)
+	if _, err := pw.Write([]byte("jj\x1b[Ak\x1b[B\r")); err != nil {
+		t.Fatal(err)
+	}
+	if r := awaitSelect(t, done); r.err != nil || r.choice != 1 {
+		t.Errorf("Select = %d, %v; want 1 (banana)", r.choice, r.err)
+	}
+	if got := echo.take(); !strings.HasSuffix(got, "\r\x1b[JPick: banana\r\n") {
+		t.Errorf("Select ended its output with %q", got)
+	}
+
+	// "b" leaves banana and blueberry; "bl" only blueberry
+	done = runSelect(h, options...)
+	if _, err := pw.Write([]byte("bx\x7fl\r")); err != nil {
+		t.Fatal(err)
+	}
+	if r := awaitSelect(t, done); r.err != nil || r.choice != 3 {
+		t.Errorf("filtered Select = %d, %v; want 3 (blueberry)", r.choice, r.err)
+	}
+
+	done = runSelect(h, options...)
+	if _, err := pw.Write([]byte("\x03")); err != nil {
+		t.Fatal(err)
+	}
+	if r := awaitSelect(t, done); !errors.Is(r.err, ErrCanceled) {
+		t.Errorf("canceled Select returned %d, %v", r.choice, r.err)
+	}
+}
+
+// TestConfirm: y and n answer, Enter takes the default, other keys are
+// ignored, and Escape cancels.
+func TestConfirm(t *testing.T) {
+	echo := &echoBuffer{}
+	h, pw, cleanup := newPipedHandlerWith(t, Options{EchoWriter: echo})
+	defer cleanup()
+
+	for _, tc := range []struct {
+		input string
+		def   bool
+		want  bool
+		err   error
+		echo  string
+	}{
+		{"xY", false, true, nil, "Continue? [y/N] yes\r\n"},
+		{"n", true, false, nil, "Continue? [Y/n] no\r\n"},
+		{"\r", true, true, nil, "Continue? [Y/n] yes\r\n"},
+		{"\r", false, false, nil, "Continue? [y/N] no\r\n"},
+		{"\x03", true, false, ErrCanceled, "Continue? [Y/n] \r\n"},
+	} {
+		done := make(chan error, 1)
+		var got bool
+		go func() {
+			var err error
+			got, err = h.Confirm("Continue?", tc.def)
+			done <- err
+		}()
+		if _, err := pw.Write([]byte(tc.input)); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case err := <-done:
+			if got != tc.want || !errors.Is(err, tc.err) {
+				t.Errorf("%q: Confirm = %v, %v; want %v, %v", tc.input, got, err, tc.want, tc.err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%q: Confirm never returned", tc.input)
+		}
+		if e := echo.take(); e != tc.echo {
+			t.Errorf("%q: echoed %q, want %q", tc.input, e, tc.echo)
+		}
+	}
+}
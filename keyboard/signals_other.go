@@ -0,0 +1,6 @@
+//go:build !unix
+
+package keyboard
+
+// watchSignals is a no-op: signal-triggered restore is only supported on Unix.
+func (h *Handler) watchSignals() {}
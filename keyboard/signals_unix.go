@@ -0,0 +1,31 @@
+//go:build unix
+
+package keyboard
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchSignals restores the terminal on SIGINT, SIGTERM, or SIGHUP and then
+// re-raises the signal with its default disposition, so the process still
+// dies (and reports the right exit status) the way it would have otherwise.
+func (h *Handler) watchSignals() {
+	defer h.RestoreOnPanic()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	select {
+	case <-h.stopChan:
+		return
+	case sig := <-sigs:
+		h.logAt(slog.LevelInfo, "Signal received, restoring terminal", "signal", sig.String())
+		h.Stop()
+		signal.Reset(sig)
+		syscall.Kill(syscall.Getpid(), sig.(syscall.Signal))
+	}
+}
@@ -0,0 +1,26 @@
+package keyboard
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestSimple: Simple mode has no line mode, passes pasted text as keys,
+// and drops mouse reports.
+func TestSimple(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{Simple: true, MouseEnterModes: "\x1b[?1006h"})
+	defer cleanup()
+
+	h.SetLineMode(true)
+	if h.IsLineMode() {
+		t.Error("line mode enabled")
+	}
+	if _, err := h.ReadLine(); !errors.Is(err, ErrSimple) {
+		t.Errorf("ReadLine = %v, want ErrSimple", err)
+	}
+
+	if _, err := pw.Write([]byte("a" + bracketedPasteStart + "xy" + bracketedPasteEnd + "\x1b[<0;3;4M\x1b[M #$\x1b[Ab")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "a", "x", "y", "Up", "b")
+}
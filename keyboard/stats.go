@@ -0,0 +1,70 @@
+package keyboard
+
+import (
+	"expvar"
+	"sync/atomic"
+)
+
+// Stats is a snapshot of a Handler's counters, for monitoring long-running
+// applications. All counts are cumulative since New.
+type Stats struct {
+	BytesRead        uint64 `json:"bytes_read"`        // Raw bytes read from input
+	Keys             uint64 `json:"keys"`              // Key events emitted (to Keys or line assembly)
+	KeysDropped      uint64 `json:"keys_dropped"`      // Keys lost to Keys channel overflow
+	Lines            uint64 `json:"lines"`             // Lines completed in line mode
+	LinesDropped     uint64 `json:"lines_dropped"`     // Lines lost to Lines channel overflow
+	UnknownSequences uint64 `json:"unknown_sequences"` // Escape sequences that could not be parsed
+	EscapeTimeouts   uint64 `json:"escape_timeouts"`   // Escape sequences ended by the ESC timeout
+	Pastes           uint64 `json:"pastes"`            // Bracketed pastes completed
+	PasteBytes       uint64 `json:"paste_bytes"`       // Total bracketed paste content bytes
+}
+
+// handlerStats holds the live counters behind Stats
+type handlerStats struct {
+	bytesRead        atomic.Uint64
+	keys             atomic.Uint64
+	keysDropped      atomic.Uint64
+	lines            atomic.Uint64
+	linesDropped     atomic.Uint64
+	unknownSequences atomic.Uint64
+	escapeTimeouts   atomic.Uint64
+	pastes           atomic.Uint64
+	pasteBytes       atomic.Uint64
+}
+
+// Stats returns a snapshot of the handler's counters.
+func (h *Handler) Stats() Stats {
+	return Stats{
+		BytesRead:        h.stats.bytesRead.Load(),
+		Keys:             h.stats.keys.Load(),
+		KeysDropped:      h.stats.keysDropped.Load(),
+		Lines:            h.stats.lines.Load(),
+		LinesDropped:     h.stats.linesDropped.Load(),
+		UnknownSequences: h.stats.unknownSequences.Load(),
+		EscapeTimeouts:   h.stats.escapeTimeouts.Load(),
+		Pastes:           h.stats.pastes.Load(),
+		PasteBytes:       h.stats.pasteBytes.Load(),
+	}
+}
+
+// Map returns the counters keyed by their snake_case names, for exporting
+// to metrics systems such as Prometheus (e.g. as dkh_<name> gauges).
+func (s Stats) Map() map[string]uint64 {
+	return map[string]uint64{
+		"bytes_read":        s.BytesRead,
+		"keys":              s.Keys,
+		"keys_dropped":      s.KeysDropped,
+		"lines":             s.Lines,
+		"lines_dropped":     s.LinesDropped,
+		"unknown_sequences": s.UnknownSequences,
+		"escape_timeouts":   s.EscapeTimeouts,
+		"pastes":            s.Pastes,
+		"paste_bytes":       s.PasteBytes,
+	}
+}
+
+// StatsVar returns an expvar.Var that reports the handler's current Stats
+// as JSON. Publish it with expvar.Publish("keyboard", h.StatsVar()).
+func (h *Handler) StatsVar() expvar.Var {
+	return expvar.Func(func() any { return h.Stats() })
+}
@@ -0,0 +1,136 @@
+package keyboard
+
+import (
+	"path"
+	"time"
+)
+
+// EventFilter decides whether a subscriber receives an event.
+type EventFilter func(Event) bool
+
+// OnlyKinds is an EventFilter that accepts events of the given kinds, e.g.
+// OnlyKinds(EventMouse) for a component that only handles the mouse.
+func OnlyKinds(kinds ...EventKind) EventFilter {
+	return func(ev Event) bool {
+		for _, k := range kinds {
+			if ev.Kind == k {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// KeyMatch is an EventFilter that accepts key events whose name matches
+// any of the patterns, in path.Match syntax: "F*" for function keys,
+// "M-?" for Alt+character, "^[A-Z]" for control keys. Other events are
+// rejected. Malformed patterns match nothing.
+func KeyMatch(patterns ...string) EventFilter {
+	return func(ev Event) bool {
+		if ev.Kind != EventKey {
+			return false
+		}
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, ev.Key); ok {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// subscription is one Subscribe channel and its filters
+type subscription struct {
+	ch      chan Event
+	filters []EventFilter
+	closed  bool // Unsubscribed (under h.mu)
+}
+
+// accepts reports whether every filter accepts ev
+func (s *subscription) accepts(ev Event) bool {
+	for _, f := range s.filters {
+		if !f(ev) {
+			return false
+		}
+	}
+	return true
+}
+
+// Subscribe returns a new channel that receives a copy of every input
+// event, independently of Keys, Lines, and any other subscriber, so
+// several components can observe input without stealing events from each
+// other. It receives each key as delivered (including keys consumed by line
+// assembly), each completed line, and every mouse report and bracketed
+// paste as a typed EventMouse/EventPaste event - even when those are also
+// delivered as keys.
+//
+// Filters narrow what the subscriber receives: an event is delivered only
+// if every filter accepts it (see OnlyKinds and KeyMatch). Filters run on
+// the handler's processing goroutine and must not block; they may call the
+// handler's methods.
+//
+// The channel has the given buffer size (default 64 if <= 0). A subscriber
+// that falls behind loses its oldest events, without holding up input
+// processing or other subscribers. Call Unsubscribe to stop delivery and
+// close the channel.
+func (h *Handler) Subscribe(buffer int, filters ...EventFilter) <-chan Event {
+	if buffer <= 0 {
+		buffer = 64
+	}
+	sub := &subscription{ch: make(chan Event, buffer), filters: filters}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subs = append(h.subs, sub)
+	h.numSubs.Store(int32(len(h.subs)))
+	return sub.ch
+}
+
+// Unsubscribe stops delivery to a channel returned by Subscribe and closes
+// it. Unknown channels are ignored.
+func (h *Handler) Unsubscribe(ch <-chan Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, sub := range h.subs {
+		if sub.ch == ch {
+			h.subs = append(h.subs[:i:i], h.subs[i+1:]...)
+			h.numSubs.Store(int32(len(h.subs)))
+			sub.closed = true
+			close(sub.ch)
+			return
+		}
+	}
+}
+
+// publish delivers ev to every subscriber that accepts it, dropping a
+// subscriber's oldest event if its channel is full. The filters run
+// without h.mu, so they may call the handler's methods.
+func (h *Handler) publish(ev Event) {
+	if h.numSubs.Load() == 0 {
+		return
+	}
+	// Subscribe and Unsubscribe never change the elements of a subs slice
+	// already handed out, so it can be read without the lock
+	h.mu.Lock()
+	subs := h.subs
+	h.mu.Unlock()
+	if len(subs) == 0 {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	accepted := make([]*subscription, 0, len(subs))
+	for _, sub := range subs {
+		if sub.accepts(ev) {
+			accepted = append(accepted, sub)
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, sub := range accepted {
+		if !sub.closed {
+			sendWithPolicy(sub.ch, ev, OverflowDropOldest, h.stopChan, func(Event) {})
+		}
+	}
+}
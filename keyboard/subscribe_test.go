@@ -0,0 +1,102 @@
+package keyboard
+
+import (
+	"testing"
+	"time"
+)
+
+// nextEvent reads one event from a subscription channel.
+func nextEvent(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("event never arrived")
+	}
+	return Event{}
+}
+
+// TestSubscribeFanOut: every subscriber sees every event, and Keys still
+// gets its own copy.
+func TestSubscribeFanOut(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+	a, b := h.Subscribe(0), h.Subscribe(0)
+
+	if _, err := pw.Write([]byte("x\x1b[<0;3;4M")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "x", "Mouse@3,4", "MouseLeftPress")
+	for _, ch := range []<-chan Event{a, b} {
+		if ev := nextEvent(t, ch); ev.Kind != EventKey || ev.Key != "x" {
+			t.Errorf("first event = %+v, want key x", ev)
+		}
+		if ev := nextEvent(t, ch); ev.Kind != EventMouse || ev.Mouse.Action != MousePress || ev.Mouse.X != 3 {
+			t.Errorf("second event = %+v, want mouse press at 3,4", ev)
+		}
+	}
+
+	// The mouse report is also delivered as keys, which a subscriber sees too
+	if ev := nextEvent(t, a); ev.Kind != EventKey || ev.Key != "Mouse@3,4" {
+		t.Errorf("third event = %+v, want key Mouse@3,4", ev)
+	}
+
+	h.Unsubscribe(a)
+	done := make(chan struct{})
+	go func() {
+		for range a {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Error("channel still open after Unsubscribe")
+	}
+}
+
+// TestSubscribeFilters: filtered subscribers only see matching events.
+func TestSubscribeFilters(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+	mouse := h.Subscribe(0, OnlyKinds(EventMouse))
+	fkeys := h.Subscribe(0, KeyMatch("F*"))
+
+	if _, err := pw.Write([]byte("a\x1bOP\x1b[<0;3;4Mb\x1b[15~")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "a", "F1", "Mouse@3,4", "MouseLeftPress", "b", "F5")
+
+	if ev := nextEvent(t, mouse); ev.Kind != EventMouse || ev.Mouse.X != 3 {
+		t.Errorf("mouse subscriber got %+v, want the mouse press", ev)
+	}
+	for _, want := range []string{"F1", "F5"} {
+		if ev := nextEvent(t, fkeys); ev.Key != want {
+			t.Errorf("F-key subscriber got %+v, want %s", ev, want)
+		}
+	}
+	select {
+	case ev := <-mouse:
+		t.Errorf("mouse subscriber got extra event %+v", ev)
+	case ev := <-fkeys:
+		t.Errorf("F-key subscriber got extra event %+v", ev)
+	default:
+	}
+}
+
+// TestSubscribeFilterCallsHandler: a filter can call locking Handler
+// methods without deadlocking input processing.
+func TestSubscribeFilterCallsHandler(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+	ch := h.Subscribe(4, func(Event) bool { return !h.IsVerbatim() })
+
+	if _, err := pw.Write([]byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if ev := nextEvent(t, ch); ev.Key != "x" {
+		t.Errorf("got %+v, want key x", ev)
+	}
+	expectKeys(t, h, "x")
+}
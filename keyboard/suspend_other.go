@@ -0,0 +1,17 @@
+//go:build !unix
+
+package keyboard
+
+import "os"
+
+// notifySuspendLocked is a no-op: job-control suspend is only supported on
+// Unix.
+func (h *Handler) notifySuspendLocked() {}
+
+// watchSuspend is a no-op, like notifySuspendLocked.
+func (h *Handler) watchSuspend(sigs chan os.Signal) {}
+
+// suspend is unsupported here; Ctrl+Z is delivered as an ordinary key.
+func (h *Handler) suspend() bool {
+	return false
+}
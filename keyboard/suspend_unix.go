@@ -0,0 +1,92 @@
+//go:build unix
+
+package keyboard
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifySuspendLocked starts intercepting SIGTSTP. Start calls it before
+// any goroutine runs, so suspend always has the channel to stop and resume.
+func (h *Handler) notifySuspendLocked() {
+	h.suspendSigs = make(chan os.Signal, 1)
+	signal.Notify(h.suspendSigs, syscall.SIGTSTP)
+}
+
+// watchSuspend handles SIGTSTP sent from outside (e.g. kill -TSTP) so the
+// terminal is restored before the process stops, just like Ctrl+Z.
+func (h *Handler) watchSuspend(sigs chan os.Signal) {
+	defer h.RestoreOnPanic()
+	defer signal.Stop(sigs)
+
+	for {
+		select {
+		case <-h.stopChan:
+			return
+		case <-sigs:
+			h.suspend()
+		}
+	}
+}
+
+// suspend restores the terminal, stops the process group with SIGTSTP, and
+// once continued re-enters raw mode and re-pushes the protocol modes.
+// Returns false if the handler is not running.
+func (h *Handler) suspend() bool {
+	h.mu.Lock()
+	if !h.running {
+		h.mu.Unlock()
+		return false
+	}
+	// If the terminal was released to a child program it is already in
+	// cooked mode, and must stay that way on resume.
+	released := h.released
+	if err := h.restoreTerminalLocked(); err != nil {
+		h.logAt(slog.LevelError, "Suspend failed to restore terminal", "err", err)
+	}
+	sigs := h.suspendSigs
+	h.mu.Unlock()
+
+	if h.OnSuspend != nil {
+		h.OnSuspend()
+	}
+
+	cont := make(chan os.Signal, 1)
+	signal.Notify(cont, syscall.SIGCONT)
+	defer signal.Stop(cont)
+
+	// Stop intercepting SIGTSTP while raising it so the default action
+	// (stop the process) applies, then pick interception back up.
+	if sigs != nil {
+		signal.Stop(sigs)
+	}
+	h.logAt(slog.LevelInfo, "Suspending")
+	syscall.Kill(0, syscall.SIGTSTP)
+
+	select {
+	case <-cont:
+	case <-h.stopChan:
+		return true
+	}
+	if sigs != nil {
+		signal.Notify(sigs, syscall.SIGTSTP)
+	}
+	h.logAt(slog.LevelInfo, "Resumed from suspend")
+
+	if !released {
+		h.mu.Lock()
+		err := h.enterTerminalLocked()
+		h.mu.Unlock()
+		if err != nil {
+			h.logAt(slog.LevelError, "Resume failed to enter raw mode", "err", err)
+		}
+	}
+
+	if h.OnResume != nil {
+		h.OnResume()
+	}
+	return true
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package keyboard
+
+import "testing"
+
+// TestSuspendSignalsRegisteredByStart: SIGTSTP interception is in place
+// when Start returns, before any key can ask for a suspend.
+func TestSuspendSignalsRegisteredByStart(t *testing.T) {
+	h, _, cleanup := newPipedHandlerWith(t, Options{HandleSuspend: true})
+	defer cleanup()
+
+	h.mu.Lock()
+	sigs := h.suspendSigs
+	h.mu.Unlock()
+	if sigs == nil {
+		t.Fatal("no SIGTSTP channel after Start")
+	}
+}
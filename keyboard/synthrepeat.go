@@ -0,0 +1,99 @@
+package keyboard
+
+import (
+	"strings"
+	"time"
+)
+
+// KeyRepeat configures repeat synthesis (Options.SynthesizeRepeat)
+type KeyRepeat struct {
+	Delay    time.Duration // From the press to the first repeat
+	Interval time.Duration // Between repeats; 0 turns synthesis off
+	Keys     []string      // Keys to repeat, by name without modifiers (default navigationKeys)
+}
+
+// navigationKeys are the keys repeat synthesis applies to by default
+var navigationKeys = []string{"Up", "Down", "Left", "Right", "PageUp", "PageDown", "Home", "End"}
+
+const (
+	// repeatFirstGap is the longest wait for a held key's first
+	// auto-repeat, covering the usual OS repeat delays; a copy arriving
+	// later is a new press
+	repeatFirstGap = 750 * time.Millisecond
+	// repeatGap is the longest gap between auto-repeats before the key
+	// counts as released, covering repeat rates down to about 7 per second
+	repeatGap = 150 * time.Millisecond
+)
+
+// synthesizeRepeat tracks presses of the keys repeat synthesis applies to
+// and reports whether key is a physical auto-repeat to swallow. Once a
+// held key's auto-repeat starts, repeats are emitted on the configured
+// schedule instead, as "key:Repeat", until the auto-repeat stops.
+func (h *Handler) synthesizeRepeat(key string) bool {
+	// Repeats, reported by the terminal or synthesized, change nothing
+	if strings.HasSuffix(key, ":Repeat") {
+		return false
+	}
+	now := time.Now()
+	if strings.Contains(key, ":") || !h.repeatKeys[keyClass(key)] {
+		h.stopRepeat()
+		return false
+	}
+
+	gap := repeatFirstGap
+	if h.synthRepeating {
+		gap = repeatGap
+	}
+	if key != h.synthKey || now.Sub(h.synthLast) > gap {
+		// A new press
+		h.stopRepeat()
+		h.synthKey, h.synthPress, h.synthLast = key, now, now
+		return false
+	}
+
+	if h.synthRepeating {
+		h.synthPeriod = now.Sub(h.synthLast)
+	}
+	h.synthLast = now
+	if !h.synthRepeating {
+		h.synthRepeating = true
+		h.synthGen++
+		h.scheduleRepeat(h.synthGen, max(h.synthPress.Add(h.keyRepeat.Delay).Sub(now), 0))
+	}
+	return true
+}
+
+// scheduleRepeat emits the next synthesized repeat after d, unless
+// synthesis has stopped or the key has been released by then
+func (h *Handler) scheduleRepeat(gen int, d time.Duration) {
+	h.after(d, func() {
+		if h.synthGen != gen {
+			return
+		}
+		if time.Since(h.synthLast) > h.releaseGap() {
+			h.stopRepeat()
+			return
+		}
+		h.emitKey(h.synthKey + ":Repeat")
+		h.scheduleRepeat(gen, h.keyRepeat.Interval)
+	})
+}
+
+// releaseGap is how long after the latest auto-repeat copy the key counts
+// as released: three of the observed repeat periods, up to repeatGap
+func (h *Handler) releaseGap() time.Duration {
+	if h.synthPeriod <= 0 {
+		return repeatGap
+	}
+	return min(3*h.synthPeriod, repeatGap)
+}
+
+// stopRepeat ends repeat synthesis and forgets the held key
+func (h *Handler) stopRepeat() {
+	if h.synthRepeating {
+		h.synthGen++
+		h.synthRepeating = false
+	}
+	h.synthPeriod = 0
+	h.synthKey = ""
+}
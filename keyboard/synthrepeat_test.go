@@ -0,0 +1,57 @@
+package keyboard
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSynthesizeRepeat: a held navigation key's auto-repeat copies are
+// swallowed and replaced by repeats on the configured schedule, which stop
+// once the copies do. Other keys pass through.
+func TestSynthesizeRepeat(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{SynthesizeRepeat: KeyRepeat{
+		Delay:    40 * time.Millisecond,
+		Interval: 20 * time.Millisecond,
+	}})
+	defer cleanup()
+
+	// Hold Down for ~200ms, auto-repeating every 10ms
+	start := time.Now()
+	for time.Since(start) < 200*time.Millisecond {
+		if _, err := pw.Write([]byte("\x1b[B")); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	expectKeys(t, h, "Down")
+	repeats := 0
+	timeout := time.After(2 * time.Second)
+	for done := false; !done; {
+		select {
+		case k := <-h.Keys:
+			switch k {
+			case "Down:Repeat":
+				repeats++
+			case "Down":
+				// A scheduling hiccup longer than the release gap reads
+				// as a release and a new press
+			default:
+				t.Fatalf("key = %q, want Down:Repeat", k)
+			}
+		case <-time.After(3 * repeatGap):
+			done = true
+		case <-timeout:
+			t.Fatal("repeats never stopped")
+		}
+	}
+	// ~160ms of repeating at 20ms; allow for scheduling slack
+	if repeats < 3 || repeats > 12 {
+		t.Errorf("%d repeats, want about 8", repeats)
+	}
+
+	// Letters aren't repeated by default, and a fresh press is a press
+	if _, err := pw.Write([]byte("xx\x1b[B")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "x", "x", "Down")
+}
@@ -0,0 +1,60 @@
+package keyboard
+
+import (
+	"strings"
+	"time"
+)
+
+// trackModifierTap watches the kitty protocol's modifier key events for
+// taps: a modifier pressed and released within Options.ModifierTap with no
+// other key in between is reported as e.g. S-Tap (any side), and a second
+// tap of the same modifier within the window also as S-DoubleTap
+func (h *Handler) trackModifierTap(key string) {
+	if h.tapEmitting {
+		return
+	}
+	now := time.Now()
+	prefix, event, ok := modifierEvent(key)
+	switch {
+	case !ok:
+		// Any other key in between spoils both the tap and a double tap
+		h.tapMod, h.lastTapMod = "", ""
+	case event == "Press":
+		h.tapMod, h.tapStart = prefix, now
+	case event == "Release":
+		if h.tapMod != prefix || now.Sub(h.tapStart) > h.modifierTap {
+			h.tapMod = ""
+			return
+		}
+		h.tapMod = ""
+		double := h.lastTapMod == prefix && now.Sub(h.lastTapTime) <= h.modifierTap
+		if double {
+			h.lastTapMod = ""
+		} else {
+			h.lastTapMod, h.lastTapTime = prefix, now
+		}
+
+		h.tapEmitting = true
+		h.emitKey(prefix + "-Tap")
+		if double {
+			h.emitKey(prefix + "-DoubleTap")
+		}
+		h.tapEmitting = false
+	}
+	// Repeats (the modifier held down) change nothing: holding too long
+	// is caught at release
+}
+
+// modifierEvent splits a kitty modifier key event ("S-Press:Left") into its
+// modifier prefix and event ("S", "Press")
+func modifierEvent(key string) (prefix, event string, ok bool) {
+	body, side, found := strings.Cut(key, ":")
+	if !found || (side != "Left" && side != "Right") {
+		return "", "", false
+	}
+	prefix, event, found = strings.Cut(body, "-")
+	if !found || len(prefix) != 1 || (event != "Press" && event != "Repeat" && event != "Release") {
+		return "", "", false
+	}
+	return prefix, event, true
+}
@@ -0,0 +1,27 @@
+package keyboard
+
+import (
+	"testing"
+	"time"
+)
+
+// TestModifierTap: a lone press and release of a modifier is a tap, two in
+// a row a double tap, and a key in between spoils it.
+func TestModifierTap(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{ModifierTap: time.Second})
+	defer cleanup()
+
+	shift := "\x1b[57441;2u\x1b[57441;1:3u"
+	if _, err := pw.Write([]byte(shift + shift)); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h,
+		"S-Press:Left", "S-Release:Left", "S-Tap",
+		"S-Press:Left", "S-Release:Left", "S-Tap", "S-DoubleTap")
+
+	// Ctrl held for Ctrl+C is not a tap
+	if _, err := pw.Write([]byte("\x1b[57442;5u\x1b[99;5u\x1b[57442;1:3ux")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "C-Press:Left", "^C", "C-Release:Left", "x")
+}
@@ -0,0 +1,125 @@
+package keyboard
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+)
+
+// isXTGETTCAPReply reports whether a DCS body is an XTGETTCAP answer:
+// "1+r<hex name>=<hex value>" (known) or "0+r<hex name>" (unknown)
+func isXTGETTCAPReply(payload []byte) bool {
+	return bytes.HasPrefix(payload, []byte("1+r")) || bytes.HasPrefix(payload, []byte("0+r"))
+}
+
+// QueryTermcap asks the terminal for terminfo capabilities by name using
+// XTGETTCAP (DCS + q), e.g. "kcuu1", "kf1", or "TN" (the terminal's name).
+// It returns the capabilities the terminal knows; unknown names are simply
+// absent. The replies are consumed rather than emitted as keys. Each name
+// is asked separately, followed by a DA1 request as a fence, so the call
+// returns as soon as the terminal has answered everything. Requires
+// ModeWriter; must not be called from a handler callback.
+func (h *Handler) QueryTermcap(names ...string) (map[string]string, error) {
+	caps, cancelCaps := h.expectReplies(replyXTGETTCAP, len(names), true, nil)
+	defer cancelCaps()
+	fence, cancelFence := h.expectReply(replyDA1)
+	defer cancelFence()
+
+	var q strings.Builder
+	for _, name := range names {
+		q.WriteString("\x1bP+q")
+		q.WriteString(strings.ToUpper(hex.EncodeToString([]byte(name))))
+		q.WriteString("\x1b\\")
+	}
+	q.WriteString("\x1b[c")
+	if err := h.sendQuery(q.String()); err != nil {
+		return nil, err
+	}
+	if _, err := h.awaitReply(fence); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string)
+	for {
+		select {
+		case r := <-caps:
+			if name, value, ok := decodeXTGETTCAP(r.payload); ok {
+				result[name] = value
+			}
+		default:
+			return result, nil
+		}
+	}
+}
+
+// decodeXTGETTCAP decodes a "1+r<hex name>=<hex value>" reply body
+func decodeXTGETTCAP(payload []byte) (name, value string, ok bool) {
+	if !bytes.HasPrefix(payload, []byte("1+r")) {
+		return "", "", false
+	}
+	nameHex, valueHex, found := strings.Cut(string(payload[3:]), "=")
+	if !found {
+		return "", "", false
+	}
+	n, err := hex.DecodeString(nameHex)
+	if err != nil {
+		return "", "", false
+	}
+	v, err := hex.DecodeString(valueHex)
+	if err != nil {
+		return "", "", false
+	}
+	return string(n), string(v), true
+}
+
+// termcapKeys maps terminfo key capabilities to key names
+var termcapKeys = map[string]string{
+	"kcuu1": "Up",
+	"kcud1": "Down",
+	"kcuf1": "Right",
+	"kcub1": "Left",
+	"khome": "Home",
+	"kend":  "End",
+	"kich1": "Insert",
+	"kdch1": "Delete",
+	"kpp":   "PageUp",
+	"knp":   "PageDown",
+	"kcbt":  "S-Tab",
+	"kf1":   "F1",
+	"kf2":   "F2",
+	"kf3":   "F3",
+	"kf4":   "F4",
+	"kf5":   "F5",
+	"kf6":   "F6",
+	"kf7":   "F7",
+	"kf8":   "F8",
+	"kf9":   "F9",
+	"kf10":  "F10",
+	"kf11":  "F11",
+	"kf12":  "F12",
+}
+
+// ConfigureKeysFromTerminal asks the terminal (via QueryTermcap) which
+// sequences its navigation and function keys send, and binds any escape
+// sequences it reports with BindSequence. This lets the handler learn the
+// key table from the terminal itself when terminfo files are unavailable.
+// Returns the number of bindings added.
+func (h *Handler) ConfigureKeysFromTerminal() (int, error) {
+	names := make([]string, 0, len(termcapKeys))
+	for name := range termcapKeys {
+		names = append(names, name)
+	}
+	caps, err := h.QueryTermcap(names...)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for name, seq := range caps {
+		if len(seq) > 1 && seq[0] == 0x1b {
+			h.BindSequence(seq, termcapKeys[name])
+			added++
+		}
+	}
+	h.debug("Keys configured from terminal", "bindings", added)
+	return added, nil
+}
@@ -0,0 +1,38 @@
+package keyboard
+
+import "time"
+
+// Stage names a step of the input pipeline, for OnStageTiming
+type Stage int
+
+const (
+	// StageParse is parsing one chunk of input, including delivering the
+	// keys it produced
+	StageParse Stage = iota
+	// StageDeliver is delivering one key: macOS Option decoding,
+	// OnKeyFilter, middleware, OnKey, and line assembly or the Keys channel
+	StageDeliver
+)
+
+// String returns the stage name ("parse", "deliver")
+func (s Stage) String() string {
+	switch s {
+	case StageParse:
+		return "parse"
+	case StageDeliver:
+		return "deliver"
+	}
+	return "unknown"
+}
+
+// StageTiming is one measurement reported on OnStageTiming
+type StageTiming struct {
+	Stage    Stage
+	Duration time.Duration
+	Bytes    int // Input bytes handled (StageParse only)
+}
+
+// timeStage reports the time since start on OnStageTiming
+func (h *Handler) timeStage(stage Stage, bytes int, start time.Time) {
+	h.OnStageTiming(StageTiming{Stage: stage, Duration: time.Since(start), Bytes: bytes})
+}
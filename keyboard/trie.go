@@ -0,0 +1,50 @@
+package keyboard
+
+// seqTrie is a byte trie of escape sequences. Walking it answers "could
+// this input still grow into a known sequence?" in time proportional to the
+// input, where scanning the binding tables costs time proportional to
+// their size - per input byte.
+type seqTrie struct {
+	children map[byte]*seqTrie
+}
+
+// newSeqTrie builds a trie of the sequences in bindings
+func newSeqTrie(bindings map[string]string) *seqTrie {
+	t := &seqTrie{}
+	for seq := range bindings {
+		t.insert(seq)
+	}
+	return t
+}
+
+// insert adds seq to the trie
+func (t *seqTrie) insert(seq string) {
+	node := t
+	for i := 0; i < len(seq); i++ {
+		if node.children == nil {
+			node.children = make(map[byte]*seqTrie)
+		}
+		next, ok := node.children[seq[i]]
+		if !ok {
+			next = &seqTrie{}
+			node.children[seq[i]] = next
+		}
+		node = next
+	}
+}
+
+// isProperPrefix reports whether seq is a proper prefix of a sequence in
+// the trie
+func (t *seqTrie) isProperPrefix(seq string) bool {
+	node := t
+	for i := 0; i < len(seq); i++ {
+		node = node.children[seq[i]]
+		if node == nil {
+			return false
+		}
+	}
+	return len(node.children) > 0
+}
+
+// escTrie indexes the built-in escBindings table
+var escTrie = newSeqTrie(escBindings)
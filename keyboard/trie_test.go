@@ -0,0 +1,73 @@
+package keyboard
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSeqTrieMatchesScan: the trie agrees with a scan of the table for
+// every prefix of every built-in sequence, and for non-prefixes.
+func TestSeqTrieMatchesScan(t *testing.T) {
+	scan := func(seq string) bool {
+		for key := range escBindings {
+			if len(seq) < len(key) && strings.HasPrefix(key, seq) {
+				return true
+			}
+		}
+		return false
+	}
+	for key := range escBindings {
+		for i := 1; i <= len(key); i++ {
+			if got, want := escTrie.isProperPrefix(key[:i]), scan(key[:i]); got != want {
+				t.Errorf("isProperPrefix(%q) = %v, want %v", key[:i], got, want)
+			}
+		}
+	}
+	for _, seq := range []string{"x", "\x1b[999", "\x1b[A~"} {
+		if escTrie.isProperPrefix(seq) {
+			t.Errorf("isProperPrefix(%q) = true, want false", seq)
+		}
+	}
+}
+
+// TestBindSequencePrefix: a bound multi-byte sequence waits for its last
+// byte instead of being emitted piecemeal.
+func TestBindSequencePrefix(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+	h.BindSequence("\x1b[99;7z", "Custom")
+
+	for _, b := range []byte("\x1b[99;7z") {
+		if _, err := pw.Write([]byte{b}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectKeys(t, h, "Custom")
+}
+
+// TestBindingLookupWithoutLock: the parser's binding lookups don't wait for
+// h.mu, and see a binding as soon as BindSequence returns.
+func TestBindingLookupWithoutLock(t *testing.T) {
+	h, _, cleanup := newPipedHandler(t)
+	defer cleanup()
+	h.BindSequence("\x1b[99;7z", "Custom")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if key, ok := h.lookupBinding("\x1b[99;7z"); !ok || key != "Custom" {
+			t.Errorf("lookupBinding = %q, %v; want Custom", key, ok)
+		}
+		if !h.isBindingPrefix("\x1b[99;") {
+			t.Error("isBindingPrefix = false, want true")
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("binding lookup waited for the handler lock")
+	}
+}
@@ -0,0 +1,90 @@
+package keyboard
+
+import (
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// TypingStats is a snapshot of typing statistics, collected when
+// Options.TypingStats is set. Counts are cumulative since New or the last
+// ResetTypingStats. Secret input (SetSecret) and mouse keys are not
+// counted.
+type TypingStats struct {
+	Keys      uint64            // Keys delivered
+	Chars     uint64            // Of those, printable characters (including Space)
+	First     time.Time         // When the first key was delivered
+	Last      time.Time         // When the latest key was delivered
+	Frequency map[string]uint64 // Deliveries of each key name
+}
+
+// Elapsed is the time from the first key to the latest
+func (s TypingStats) Elapsed() time.Duration {
+	return s.Last.Sub(s.First)
+}
+
+// WPM estimates typing speed in words per minute over Elapsed, counting
+// five characters as a word. It is 0 until some time has passed.
+func (s TypingStats) WPM() float64 {
+	minutes := s.Elapsed().Minutes()
+	if minutes <= 0 {
+		return 0
+	}
+	return float64(s.Chars) / 5 / minutes
+}
+
+// typingStats holds the live counters behind TypingStats. It has its own
+// lock, since keys are recorded on the processing goroutine.
+type typingStats struct {
+	mu    sync.Mutex
+	stats TypingStats
+}
+
+// record counts one delivered key. Mouse keys are not typing, and their
+// positions would grow Frequency without bound, so they are skipped.
+func (t *typingStats) record(key string, now time.Time) {
+	if isMouseKey(key) {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stats.Keys == 0 {
+		t.stats.First = now
+	}
+	t.stats.Keys++
+	t.stats.Last = now
+	if key == "Space" || (utf8.RuneCountInString(key) == 1 && key[0] >= ' ' && key != "\x7f") {
+		t.stats.Chars++
+	}
+	if t.stats.Frequency == nil {
+		t.stats.Frequency = make(map[string]uint64)
+	}
+	t.stats.Frequency[key]++
+}
+
+// TypingStats returns a snapshot of the typing statistics. It is empty
+// unless Options.TypingStats is set.
+func (h *Handler) TypingStats() TypingStats {
+	if h.typing == nil {
+		return TypingStats{}
+	}
+	h.typing.mu.Lock()
+	defer h.typing.mu.Unlock()
+	s := h.typing.stats
+	s.Frequency = make(map[string]uint64, len(h.typing.stats.Frequency))
+	for k, n := range h.typing.stats.Frequency {
+		s.Frequency[k] = n
+	}
+	return s
+}
+
+// ResetTypingStats clears the typing statistics, e.g. at the start of a
+// typing test.
+func (h *Handler) ResetTypingStats() {
+	if h.typing == nil {
+		return
+	}
+	h.typing.mu.Lock()
+	h.typing.stats = TypingStats{}
+	h.typing.mu.Unlock()
+}
@@ -0,0 +1,67 @@
+package keyboard
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTypingStats: keys and printable characters are counted per key, and
+// ResetTypingStats starts over.
+func TestTypingStats(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{TypingStats: true})
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("ab a\x1b[A")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "a", "b", " ", "a", "Up")
+
+	s := h.TypingStats()
+	if s.Keys != 5 || s.Chars != 4 {
+		t.Errorf("Keys, Chars = %d, %d; want 5, 4", s.Keys, s.Chars)
+	}
+	if s.Frequency["a"] != 2 || s.Frequency["Up"] != 1 {
+		t.Errorf("Frequency = %v", s.Frequency)
+	}
+	if s.First.IsZero() || s.Last.Before(s.First) {
+		t.Errorf("First, Last = %v, %v", s.First, s.Last)
+	}
+
+	s.First = s.Last.Add(-time.Minute)
+	if wpm := s.WPM(); wpm != 0.8 {
+		t.Errorf("WPM = %v, want 0.8", wpm)
+	}
+
+	h.ResetTypingStats()
+	if s := h.TypingStats(); s.Keys != 0 || len(s.Frequency) != 0 {
+		t.Errorf("after reset: %+v", s)
+	}
+}
+
+// TestTypingStatsSkipsMouse: mouse keys, with their positions, are not
+// counted.
+func TestTypingStatsSkipsMouse(t *testing.T) {
+	h, pw, cleanup := newPipedHandlerWith(t, Options{TypingStats: true})
+	defer cleanup()
+
+	if _, err := pw.Write([]byte("\x1b[<0;1;1M\x1b[<0;80;24Ma")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "Mouse@1,1", "MouseLeftPress", "Mouse@80,24", "MouseLeftPress", "a")
+
+	if s := h.TypingStats(); s.Keys != 1 || len(s.Frequency) != 1 {
+		t.Errorf("Keys = %d, Frequency = %v; want only a", s.Keys, s.Frequency)
+	}
+}
+
+// TestTypingStatsOff: nothing is collected without the option.
+func TestTypingStatsOff(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+
+	pw.Write([]byte("a"))
+	expectKeys(t, h, "a")
+	if s := h.TypingStats(); s.Keys != 0 {
+		t.Errorf("Keys = %d, want 0", s.Keys)
+	}
+}
@@ -0,0 +1,50 @@
+package keyboard
+
+// verbatimBufSize is the number of chunks the verbatim channel buffers
+const verbatimBufSize = 16
+
+// StartVerbatim switches to verbatim input: parsing stops, and each chunk
+// of input read is delivered unaltered on the returned channel, e.g. to
+// pipe to a subprocess. The terminal stays in raw mode. Read the channel
+// until it is closed after StopVerbatim (or until Done, if the handler is
+// stopped), since input waits for it to be received. Calling StartVerbatim
+// again returns the channel already in use.
+func (h *Handler) StartVerbatim() <-chan []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.verbatim == nil {
+		h.verbatim = make(chan []byte, verbatimBufSize)
+	}
+	return h.verbatim
+}
+
+// StopVerbatim ends verbatim input: input that follows is parsed as usual,
+// and the channel from StartVerbatim is closed once the chunks before it
+// have been delivered.
+func (h *Handler) StopVerbatim() {
+	h.mu.Lock()
+	ch := h.verbatim
+	h.verbatim = nil
+	h.mu.Unlock()
+	if ch != nil {
+		// Closed on the processing goroutine, after any send in progress
+		h.after(0, func() { close(ch) })
+	}
+}
+
+// IsVerbatim reports whether verbatim input is on.
+func (h *Handler) IsVerbatim() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.verbatim != nil
+}
+
+// sendVerbatim delivers a copy of data, which the input queue reuses, on
+// ch
+func (h *Handler) sendVerbatim(ch chan []byte, data []byte) {
+	chunk := append([]byte(nil), data...)
+	select {
+	case ch <- chunk:
+	case <-h.stopChan:
+	}
+}
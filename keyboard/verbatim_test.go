@@ -0,0 +1,49 @@
+package keyboard
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// TestVerbatim: input arrives unparsed while verbatim, the channel closes
+// after StopVerbatim, and parsing resumes.
+func TestVerbatim(t *testing.T) {
+	h, pw, cleanup := newPipedHandler(t)
+	defer cleanup()
+
+	ch := h.StartVerbatim()
+	if !h.IsVerbatim() || h.StartVerbatim() != ch {
+		t.Fatal("verbatim input not started once")
+	}
+	want := []byte("\x1b[A\x00\xff\x1b[200~x")
+	if _, err := pw.Write(want); err != nil {
+		t.Fatal(err)
+	}
+	var got []byte
+	for len(got) < len(want) {
+		select {
+		case chunk := <-ch:
+			got = append(got, chunk...)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	h.StopVerbatim()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Error("chunk after StopVerbatim")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("channel not closed")
+	}
+	if _, err := pw.Write([]byte("\x1b[A")); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, h, "Up")
+}
@@ -0,0 +1,183 @@
+package keymap
+
+import (
+	"context"
+
+	"github.com/phroun/direct-key-handler/keyboard"
+)
+
+// Dispatcher resolves keys one at a time against a Keymap, following
+// multi-key sequences such as "^X ^S". It is meant for one goroutine,
+// the one reading keys.
+type Dispatcher struct {
+	// OnPending, if set, is called when a key starts or extends a
+	// sequence, with the sequence so far and the bindings that can complete
+	// it, e.g. to show a which-key popup.
+	OnPending func(prefix string, continuations []Binding)
+
+	// OnResolved, if set, is called when a pending sequence ends, with the
+	// keys typed: with the action bound to them, or with "" when the
+	// sequence was abandoned (a key that continues nothing, or Cancel).
+	OnResolved func(keys, action string)
+
+	// BeforeDispatch, if set, sees each key before it is resolved, and
+	// drops it by returning false.
+	BeforeDispatch func(key string) bool
+
+	// AfterDispatch, if set, is called after each key has been resolved
+	// and the action it completed, if any, has run; action is "" for a key
+	// that completed no binding.
+	AfterDispatch func(key, action string)
+
+	// OnUnbound, if set, gets the keys that are neither bound nor start a
+	// sequence, e.g. text for the application to insert.
+	OnUnbound func(key string)
+
+	actions map[string]func()
+	layers  []layer // The keymap stack, the base keymap first
+	pending string  // The sequence so far, "" when none
+}
+
+// layer is a keymap on the dispatcher's stack
+type layer struct {
+	keymap      *Keymap
+	fallThrough bool // Keys it doesn't bind go on to the layer below
+}
+
+// NewDispatcher returns a dispatcher for km.
+func NewDispatcher(km *Keymap) *Dispatcher {
+	return &Dispatcher{layers: []layer{{keymap: km}}}
+}
+
+// Handle sets the function Dispatch runs for action. Call it before Run.
+func (d *Dispatcher) Handle(action string, fn func()) {
+	if d.actions == nil {
+		d.actions = make(map[string]func())
+	}
+	d.actions[action] = fn
+}
+
+// Run dispatches the handler's keys until ctx is done or the handler is
+// stopped, returning ctx.Err() or keyboard.ErrStopped.
+func (d *Dispatcher) Run(ctx context.Context, h *keyboard.Handler) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-h.Done():
+			return keyboard.ErrStopped
+		case key := <-h.Keys:
+			d.Dispatch(key)
+		}
+	}
+}
+
+// Dispatch takes one key through BeforeDispatch, resolution, the action it
+// completes, and AfterDispatch. Actions without a Handle function are
+// only reported to AfterDispatch.
+func (d *Dispatcher) Dispatch(key string) {
+	if d.BeforeDispatch != nil && !d.BeforeDispatch(key) {
+		return
+	}
+	wasPending := d.pending != ""
+	action, ok := d.Key(key)
+	switch {
+	case ok:
+		if fn := d.actions[action]; fn != nil {
+			fn()
+		}
+	case !wasPending && d.pending == "":
+		if d.OnUnbound != nil {
+			d.OnUnbound(key)
+		}
+	}
+	if d.AfterDispatch != nil {
+		d.AfterDispatch(key, action)
+	}
+}
+
+// PushKeymap puts km on top of the keymap stack, e.g. for a dialog or a
+// modal overlay, until PopKeymap. Keys km doesn't bind go on to the
+// keymaps below if fallThrough is set, and are captured otherwise. A
+// pending sequence is abandoned.
+func (d *Dispatcher) PushKeymap(km *Keymap, fallThrough bool) {
+	d.Cancel()
+	d.layers = append(d.layers, layer{keymap: km, fallThrough: fallThrough})
+}
+
+// PopKeymap removes the keymap on top of the stack, restoring the bindings
+// below it, and returns it. The base keymap is never removed: PopKeymap
+// returns nil instead. A pending sequence is abandoned.
+func (d *Dispatcher) PopKeymap() *Keymap {
+	if len(d.layers) == 1 {
+		return nil
+	}
+	d.Cancel()
+	top := d.layers[len(d.layers)-1]
+	d.layers = d.layers[:len(d.layers)-1]
+	return top.keymap
+}
+
+// Keymap returns the keymap on top of the stack.
+func (d *Dispatcher) Keymap() *Keymap {
+	return d.layers[len(d.layers)-1].keymap
+}
+
+// Key resolves the next key and returns the action it completes. A key
+// that starts or extends a sequence returns ok false while the sequence
+// is pending, and so does a key that continues a pending sequence nowhere:
+// the sequence is abandoned and the key is consumed. A binding resolves as
+// soon as it is typed, shadowing longer sequences that start with it.
+// The keymap stack is searched from the top down, as far as the keymaps
+// let keys fall through.
+func (d *Dispatcher) Key(key string) (action string, ok bool) {
+	if key == " " {
+		key = spaceKey
+	}
+	seq := key
+	if d.pending != "" {
+		seq = d.pending + " " + key
+	}
+	for i := len(d.layers) - 1; i >= 0; i-- {
+		l := d.layers[i]
+		if action, ok := l.keymap.Lookup(seq); ok {
+			d.resolve(seq, action)
+			return action, true
+		}
+		if cont := l.keymap.Continuations(seq); len(cont) > 0 {
+			d.pending = seq
+			if d.OnPending != nil {
+				d.OnPending(seq, cont)
+			}
+			return "", false
+		}
+		if !l.fallThrough {
+			break
+		}
+	}
+	d.resolve(seq, "")
+	return "", false
+}
+
+// Pending returns the sequence typed so far, or "" when none is pending.
+func (d *Dispatcher) Pending() string {
+	return d.pending
+}
+
+// Cancel abandons the pending sequence, if any.
+func (d *Dispatcher) Cancel() {
+	if d.pending != "" {
+		d.resolve(d.pending, "")
+	}
+}
+
+// resolve ends the pending sequence, reporting it on OnResolved
+func (d *Dispatcher) resolve(keys, action string) {
+	if d.pending == "" {
+		return
+	}
+	d.pending = ""
+	if d.OnResolved != nil {
+		d.OnResolved(keys, action)
+	}
+}
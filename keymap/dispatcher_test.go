@@ -0,0 +1,188 @@
+package keymap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/phroun/direct-key-handler/keyboard"
+)
+
+// TestDispatcher: single keys and sequences resolve, pending sequences
+// are reported with their continuations, and dead ends and Cancel abandon
+// them.
+func TestDispatcher(t *testing.T) {
+	km := New(map[string]string{"^S": "save", "C-x C-s": "save", "C-x C-c": "quit"})
+	d := NewDispatcher(km)
+	var log []string
+	d.OnPending = func(prefix string, cont []Binding) {
+		log = append(log, fmt.Sprintf("pending %s (%d)", prefix, len(cont)))
+	}
+	d.OnResolved = func(keys, action string) {
+		log = append(log, fmt.Sprintf("resolved %s = %q", keys, action))
+	}
+
+	for _, tc := range []struct {
+		key    string
+		action string
+	}{
+		{"^S", "save"},
+		{"a", ""},
+		{"C-x", ""},
+		{"C-c", "quit"},
+		{"C-x", ""},
+		{"z", ""},
+		{"C-x", ""},
+	} {
+		action, ok := d.Key(tc.key)
+		if action != tc.action || ok != (tc.action != "") {
+			t.Errorf("Key(%q) = %q, %v; want %q", tc.key, action, ok, tc.action)
+		}
+	}
+	if d.Pending() != "C-x" {
+		t.Errorf("Pending = %q, want C-x", d.Pending())
+	}
+	d.Cancel()
+	if d.Pending() != "" {
+		t.Errorf("Pending = %q after Cancel", d.Pending())
+	}
+
+	want := []string{
+		"pending C-x (2)",
+		`resolved C-x C-c = "quit"`,
+		"pending C-x (2)",
+		`resolved C-x z = ""`,
+		"pending C-x (2)",
+		`resolved C-x = ""`,
+	}
+	if !slices.Equal(log, want) {
+		t.Errorf("callbacks:\n%q\nwant\n%q", log, want)
+	}
+}
+
+// TestDispatcherSpace: the space key, delivered as " ", can be bound alone
+// and in a sequence, and ends a sequence it doesn't continue.
+func TestDispatcherSpace(t *testing.T) {
+	km := New(map[string]string{"^X ^S": "save", "^X Space": "mark"})
+	km.Bind(" ", "page-down")
+	d := NewDispatcher(km)
+
+	for _, tc := range []struct {
+		keys   []string
+		action string
+	}{
+		{[]string{" "}, "page-down"},
+		{[]string{"^X", " "}, "mark"},
+	} {
+		var action string
+		for _, key := range tc.keys {
+			action, _ = d.Key(key)
+		}
+		if action != tc.action {
+			t.Errorf("keys %q = %q, want %q", tc.keys, action, tc.action)
+		}
+	}
+
+	km.Unbind("^X Space")
+	d.Key("^X")
+	if action, ok := d.Key(" "); ok || d.Pending() != "" {
+		t.Errorf("^X Space resolved to %q, pending %q; want it abandoned", action, d.Pending())
+	}
+	if action, ok := d.Key("^S"); ok {
+		t.Errorf("^S after an abandoned ^X Space = %q", action)
+	}
+}
+
+// TestKeymapStack: a pushed keymap captures keys, or lets the ones it
+// doesn't bind fall through, until it is popped.
+func TestKeymapStack(t *testing.T) {
+	base := New(map[string]string{"^S": "save", "q": "quit", "C-x C-c": "quit"})
+	dialog := New(map[string]string{"Enter": "ok", "Escape": "cancel", "q": "cancel"})
+	d := NewDispatcher(base)
+
+	expect := func(key, want string) {
+		t.Helper()
+		if action, _ := d.Key(key); action != want {
+			t.Errorf("Key(%q) = %q, want %q", key, action, want)
+		}
+	}
+
+	d.PushKeymap(dialog, false)
+	if d.Keymap() != dialog {
+		t.Error("Keymap is not the dialog's")
+	}
+	expect("q", "cancel")
+	expect("Enter", "ok")
+	expect("^S", "")
+
+	d.PopKeymap()
+	d.PushKeymap(dialog, true)
+	expect("q", "cancel")
+	expect("^S", "save")
+	expect("C-x", "")
+	expect("C-c", "quit")
+
+	// Pushing abandons a pending sequence
+	expect("C-x", "")
+	d.PushKeymap(New(nil), true)
+	expect("C-c", "")
+
+	if d.PopKeymap() == nil || d.PopKeymap() != dialog || d.PopKeymap() != nil {
+		t.Error("PopKeymap didn't unwind the stack to the base")
+	}
+	expect("q", "quit")
+}
+
+// TestRun: Run resolves the handler's keys, runs their actions, calls the
+// hooks, and returns when the handler stops or the context is done.
+func TestRun(t *testing.T) {
+	noManage := false
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	h := keyboard.New(keyboard.Options{InputReader: pr, ManageTerminal: &noManage})
+	if err := h.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDispatcher(New(map[string]string{"^X ^S": "save", "x": "cut"}))
+	var log []string
+	saved := make(chan struct{})
+	d.Handle("save", func() { close(saved) })
+	d.BeforeDispatch = func(key string) bool { return key != "y" }
+	d.OnUnbound = func(key string) { log = append(log, "unbound "+key) }
+	d.AfterDispatch = func(key, action string) { log = append(log, key+"="+action) }
+
+	done := make(chan error, 1)
+	go func() { done <- d.Run(context.Background(), h) }()
+	if _, err := pw.Write([]byte("aybx\x18\x13")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-saved:
+	case <-time.After(2 * time.Second):
+		t.Fatal("save never ran")
+	}
+	h.Stop()
+	select {
+	case err := <-done:
+		if !errors.Is(err, keyboard.ErrStopped) {
+			t.Errorf("Run = %v, want ErrStopped", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run didn't return")
+	}
+	want := []string{"unbound a", "a=", "unbound b", "b=", "x=cut", "^X=", "^S=save"}
+	if !slices.Equal(log, want) {
+		t.Errorf("hooks:\n%q\nwant\n%q", log, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := d.Run(ctx, keyboard.New(keyboard.Options{InputReader: pr, ManageTerminal: &noManage})); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,94 @@
+package keymap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Binding is one key binding, as listed for help screens.
+type Binding struct {
+	Keys        string `json:"keys"`                  // Key sequence, names separated by spaces
+	Action      string `json:"action"`                // Action name
+	Description string `json:"description,omitempty"` // From Describe
+}
+
+// Describe sets the description listed for an action, e.g. "Save the
+// file" for "save".
+func (k *Keymap) Describe(action, description string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.descriptions == nil {
+		k.descriptions = make(map[string]string)
+	}
+	k.descriptions[action] = description
+}
+
+// List returns the current bindings sorted by key sequence.
+func (k *Keymap) List() []Binding {
+	all := k.Bindings()
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	list := make([]Binding, 0, len(all))
+	for seq, action := range all {
+		list = append(list, Binding{Keys: seq, Action: action, Description: k.descriptions[action]})
+	}
+	slices.SortFunc(list, func(a, b Binding) int { return strings.Compare(a.Keys, b.Keys) })
+	return list
+}
+
+// Prefixes returns the sorted prefixes of multi-key sequences: "^X" for
+// "^X ^S", and both "^C" and "^C p" for "^C p f".
+func (k *Keymap) Prefixes() []string {
+	seen := make(map[string]bool)
+	for seq := range k.Bindings() {
+		for i, c := range seq {
+			if c == ' ' {
+				seen[seq[:i]] = true
+			}
+		}
+	}
+	prefixes := make([]string, 0, len(seen))
+	for p := range seen {
+		prefixes = append(prefixes, p)
+	}
+	slices.Sort(prefixes)
+	return prefixes
+}
+
+// Continuations returns the bindings that continue prefix, sorted by key
+// sequence, e.g. "^X ^S" and "^X ^C" for "^X".
+func (k *Keymap) Continuations(prefix string) []Binding {
+	prefix = normalize(prefix) + " "
+	var cont []Binding
+	for _, b := range k.List() {
+		if strings.HasPrefix(b.Keys, prefix) {
+			cont = append(cont, b)
+		}
+	}
+	return cont
+}
+
+// ExportJSON writes the current bindings to w as an indented JSON array of
+// Binding.
+func (k *Keymap) ExportJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(k.List())
+}
+
+// ExportMarkdown writes the current bindings to w as a Markdown table.
+func (k *Keymap) ExportMarkdown(w io.Writer) error {
+	cell := strings.NewReplacer("|", `\|`, "`", "")
+	if _, err := io.WriteString(w, "| Keys | Action | Description |\n| --- | --- | --- |\n"); err != nil {
+		return err
+	}
+	for _, b := range k.List() {
+		if _, err := fmt.Fprintf(w, "| `%s` | %s | %s |\n", cell.Replace(b.Keys), cell.Replace(b.Action), cell.Replace(b.Description)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package keymap
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func testKeymap() *Keymap {
+	km := New(map[string]string{"^S": "save", "C-x C-c": "quit", "C-x C-s": "save", "C-c p f": "find"})
+	km.Describe("save", "Save the file")
+	km.Describe("find", "Find | open")
+	return km
+}
+
+// TestList: bindings come sorted with their actions' descriptions, and
+// prefixes and continuations are found.
+func TestList(t *testing.T) {
+	km := testKeymap()
+	want := []Binding{
+		{"C-c p f", "find", "Find | open"},
+		{"C-x C-c", "quit", ""},
+		{"C-x C-s", "save", "Save the file"},
+		{"^S", "save", "Save the file"},
+	}
+	if got := km.List(); !slices.Equal(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+	if got := km.Prefixes(); !slices.Equal(got, []string{"C-c", "C-c p", "C-x"}) {
+		t.Errorf("Prefixes = %v", got)
+	}
+	if got := km.Continuations("C-x"); !slices.Equal(got, want[1:3]) {
+		t.Errorf("Continuations(C-x) = %v", got)
+	}
+}
+
+// TestExport: JSON round-trips and Markdown escapes table cells.
+func TestExport(t *testing.T) {
+	km := testKeymap()
+
+	var buf bytes.Buffer
+	if err := km.ExportJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got []Binding
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, km.List()) {
+		t.Errorf("JSON = %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := km.ExportMarkdown(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 6 || lines[2] != "| `C-c p f` | find | Find \\| open |" {
+		t.Errorf("Markdown =\n%s", buf.String())
+	}
+}
@@ -0,0 +1,185 @@
+// Package keymap maps the handler's key names to application actions, so
+// end users can customize shortcuts in a configuration file instead of
+// recompiling. Defaults come from the application; the file overrides
+// them, and Watch reloads it when it changes:
+//
+//	km := keymap.New(map[string]string{"^S": "save", "^Q": "quit"})
+//	if err := km.LoadFile(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+//		log.Print(err)
+//	}
+//	km.Watch(ctx, path, time.Second, func(err error) { ... })
+//	...
+//	if action, ok := km.Lookup(key); ok { ... }
+//
+// The file is a JSON object of key sequences to action names:
+//
+//	{"^S": "write", "^X ^C": "quit", "^Q": ""}
+//
+// A sequence is handler key names separated by spaces, with the space key
+// written Space, and an empty action removes a default binding.
+package keymap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Keymap is a set of key bindings: the application's defaults overlaid
+// with those loaded from a file. It is safe for concurrent use.
+type Keymap struct {
+	// Unmarshal decodes a keymap file into a map[string]string. Set it to
+	// yaml.Unmarshal or toml.Unmarshal for those formats. Default:
+	// json.Unmarshal
+	Unmarshal func(data []byte, v any) error
+
+	mu           sync.RWMutex
+	defaults     map[string]string
+	loaded       map[string]string
+	descriptions map[string]string // Action to description (Describe)
+}
+
+// New returns a keymap with the given default bindings.
+func New(defaults map[string]string) *Keymap {
+	k := &Keymap{defaults: make(map[string]string, len(defaults))}
+	for seq, action := range defaults {
+		k.defaults[normalize(seq)] = action
+	}
+	return k
+}
+
+// spaceKey names the space key in a sequence: the handler delivers it as
+// " ", which would read as a separator
+const spaceKey = "Space"
+
+// normalize puts a key sequence in canonical form: names separated by
+// single spaces. A lone " " is the space key.
+func normalize(seq string) string {
+	if seq == " " {
+		return spaceKey
+	}
+	return strings.Join(strings.Fields(seq), " ")
+}
+
+// Bind adds or replaces a default binding. A binding loaded from the file
+// still takes precedence.
+func (k *Keymap) Bind(seq, action string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.defaults[normalize(seq)] = action
+}
+
+// Unbind removes a default binding.
+func (k *Keymap) Unbind(seq string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.defaults, normalize(seq))
+}
+
+// Lookup returns the action bound to a key sequence.
+func (k *Keymap) Lookup(seq string) (string, bool) {
+	seq = normalize(seq)
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	action, ok := k.loaded[seq]
+	if !ok {
+		action, ok = k.defaults[seq]
+	}
+	return action, ok && action != ""
+}
+
+// Bindings returns all current bindings, sequence to action.
+func (k *Keymap) Bindings() map[string]string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	all := make(map[string]string, len(k.defaults)+len(k.loaded))
+	for seq, action := range k.defaults {
+		all[seq] = action
+	}
+	for seq, action := range k.loaded {
+		all[seq] = action
+	}
+	for seq, action := range all {
+		if action == "" {
+			delete(all, seq)
+		}
+	}
+	return all
+}
+
+// Load replaces the loaded bindings with those read from r. On error the
+// current bindings are kept.
+func (k *Keymap) Load(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("keymap: %w", err)
+	}
+	unmarshal := k.Unmarshal
+	if unmarshal == nil {
+		unmarshal = json.Unmarshal
+	}
+	var raw map[string]string
+	if err := unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("keymap: %w", err)
+	}
+	loaded := make(map[string]string, len(raw))
+	for seq, action := range raw {
+		loaded[normalize(seq)] = action
+	}
+	k.mu.Lock()
+	k.loaded = loaded
+	k.mu.Unlock()
+	return nil
+}
+
+// LoadFile replaces the loaded bindings with those in the file at path.
+func (k *Keymap) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("keymap: %w", err)
+	}
+	defer f.Close()
+	if err := k.Load(f); err != nil {
+		return fmt.Errorf("%w (%s)", err, path)
+	}
+	return nil
+}
+
+// Watch checks the file at path every interval until ctx is done, and
+// reloads it when its size or modification time changes. onReload, if
+// not nil, is called after each reload with its error; a file that fails
+// to load or is removed leaves the bindings as they were.
+func (k *Keymap) Watch(ctx context.Context, path string, interval time.Duration, onReload func(error)) {
+	var last os.FileInfo
+	if fi, err := os.Stat(path); err == nil {
+		last = fi
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			fi, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			if last != nil && fi.Size() == last.Size() && fi.ModTime().Equal(last.ModTime()) {
+				continue
+			}
+			last = fi
+			err = k.LoadFile(path)
+			if onReload != nil {
+				onReload(err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,72 @@
+package keymap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLoad: the file overrides and removes defaults, sequences are
+// normalized, and a bad file leaves the bindings alone.
+func TestLoad(t *testing.T) {
+	km := New(map[string]string{"^S": "save", "^Q": "quit", "C-x  C-c": "quit"})
+	if err := km.Load(strings.NewReader(`{"^S": "write", "^Q": "", "C-x k": "kill"}`)); err != nil {
+		t.Fatal(err)
+	}
+	for seq, want := range map[string]string{"^S": "write", "^Q": "", "C-x C-c": "quit", " C-x   k ": "kill"} {
+		if got, _ := km.Lookup(seq); got != want {
+			t.Errorf("Lookup(%q) = %q, want %q", seq, got, want)
+		}
+	}
+	if _, ok := km.Lookup("^Q"); ok {
+		t.Error("^Q still bound")
+	}
+	if n := len(km.Bindings()); n != 3 {
+		t.Errorf("%d bindings, want 3", n)
+	}
+
+	if err := km.Load(strings.NewReader(`{"^S": 1}`)); err == nil {
+		t.Error("bad file loaded")
+	}
+	if got, _ := km.Lookup("^S"); got != "write" {
+		t.Errorf("after bad load, ^S = %q, want write", got)
+	}
+}
+
+// TestWatch: a changed file is reloaded.
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, []byte(`{"^S": "save"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	km := New(nil)
+	if err := km.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloaded := make(chan error, 1)
+	km.Watch(ctx, path, 10*time.Millisecond, func(err error) { reloaded <- err })
+
+	if err := os.WriteFile(path, []byte(`{"^S": "save-all", "^W": "close"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("file never reloaded")
+	}
+	if got, _ := km.Lookup("^S"); got != "save-all" {
+		t.Errorf("^S = %q, want save-all", got)
+	}
+	if got, _ := km.Lookup("^W"); got != "close" {
+		t.Errorf("^W = %q, want close", got)
+	}
+}
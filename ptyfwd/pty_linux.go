@@ -0,0 +1,76 @@
+//go:build linux
+
+package ptyfwd
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// openPTY allocates a pseudo-terminal pair
+func openPTY() (ptmx, tty *os.File, err error) {
+	ptmx, err = os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	fd := int(ptmx.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		ptmx.Close()
+		return nil, nil, err
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		ptmx.Close()
+		return nil, nil, err
+	}
+	tty, err = os.OpenFile("/dev/pts/"+strconv.Itoa(n), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		ptmx.Close()
+		return nil, nil, err
+	}
+	return ptmx, tty, nil
+}
+
+// setControllingTTY makes the pty (the child's stdin) its controlling
+// terminal in a new session
+func setControllingTTY(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+}
+
+// setSize sets the pty's window size
+func setSize(ptmx *os.File, cols, rows int) error {
+	return unix.IoctlSetWinsize(int(ptmx.Fd()), unix.TIOCSWINSZ, &unix.Winsize{Col: uint16(cols), Row: uint16(rows)})
+}
+
+// watchResize passes size changes of the real terminal (SIGWINCH) on to the
+// handler, and so to the child, until the child exits
+func watchResize(s *Session) {
+	in, ok := s.handler.TerminalFd()
+	if !ok {
+		return
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGWINCH)
+	defer signal.Stop(sigs)
+	for {
+		select {
+		case <-sigs:
+			if cols, rows, err := term.GetSize(in); err == nil {
+				s.handler.Resize(cols, rows)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
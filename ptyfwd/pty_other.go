@@ -0,0 +1,20 @@
+//go:build !linux
+
+package ptyfwd
+
+import (
+	"os"
+	"os/exec"
+)
+
+func openPTY() (ptmx, tty *os.File, err error) {
+	return nil, nil, ErrUnsupported
+}
+
+func setControllingTTY(cmd *exec.Cmd) {}
+
+func setSize(ptmx *os.File, cols, rows int) error {
+	return ErrUnsupported
+}
+
+func watchResize(s *Session) {}
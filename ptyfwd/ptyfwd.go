@@ -0,0 +1,213 @@
+// Package ptyfwd runs a child program on a pseudo-terminal and forwards the
+// user's input to it through a keyboard.Handler, so a wrapper can intercept
+// some keys (a prefix key, a hotkey) and pass the rest through untouched:
+//
+//	s, err := ptyfwd.Start(exec.Command("bash"), ptyfwd.Options{
+//		Handler: keyboard.Options{InputReader: os.Stdin},
+//		Intercept: func(key string) bool {
+//			if key == "^]" {
+//				showMenu()
+//				return true
+//			}
+//			return false
+//		},
+//	})
+//	...
+//	err = s.Wait()
+//
+// Input is forwarded as the exact bytes the terminal sent (via RawEvents),
+// so the child sees escape sequences in the form it expects. The child's
+// output is relayed, and size changes of the real terminal are passed on.
+// Only Linux is supported.
+package ptyfwd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/phroun/direct-key-handler/keyboard"
+)
+
+// relayGrace bounds how long an exited child's remaining output is relayed
+// before the pty is closed
+const relayGrace = 200 * time.Millisecond
+
+// ErrUnsupported is returned by Start on platforms without pty support.
+var ErrUnsupported = errors.New("ptyfwd: pseudo-terminals not supported on this platform")
+
+// Options configures a Session.
+type Options struct {
+	// Handler configures the input handler. EmitRawEvents is turned on, as
+	// forwarding uses RawEvents, and a KeyOverflow left at the default
+	// becomes OverflowBlock, so a slow child holds input back instead of
+	// losing it.
+	Handler keyboard.Options
+
+	// Intercept is called with each key before it is forwarded; returning
+	// true consumes it. An input chunk that parses into several keys is
+	// withheld if any of them is consumed. Nil forwards everything.
+	Intercept func(key string) bool
+
+	// Output receives the child's output (default: os.Stdout)
+	Output io.Writer
+}
+
+// Session is a child program running on a pty with input forwarded to it.
+type Session struct {
+	cmd     *exec.Cmd
+	pty     *os.File
+	handler *keyboard.Handler
+
+	intercept func(key string) bool
+	done      chan struct{} // Closed when the child has exited
+	waitErr   error
+	closeOnce sync.Once
+}
+
+// Start starts cmd on a new pty, starts a handler for the input, and begins
+// forwarding. cmd's Stdin, Stdout, and Stderr are replaced by the pty.
+func Start(cmd *exec.Cmd, opts Options) (*Session, error) {
+	ptmx, tty, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, tty, tty
+	setControllingTTY(cmd)
+	if err := cmd.Start(); err != nil {
+		ptmx.Close()
+		tty.Close()
+		return nil, err
+	}
+	tty.Close() // The child has its own copy
+
+	hopts := opts.Handler
+	hopts.EmitRawEvents = true
+	if hopts.KeyOverflow == keyboard.OverflowDropOldest {
+		hopts.KeyOverflow = keyboard.OverflowBlock
+	}
+	s := &Session{
+		cmd:       cmd,
+		pty:       ptmx,
+		handler:   keyboard.New(hopts),
+		intercept: opts.Intercept,
+		done:      make(chan struct{}),
+	}
+	s.handler.OnResize = func(cols, rows int) { s.Resize(cols, rows) }
+	if err := s.handler.Start(); err != nil {
+		cmd.Process.Kill()
+		ptmx.Close()
+		return nil, err
+	}
+	if cols, rows := s.handler.TerminalSize(); cols > 0 && rows > 0 {
+		s.Resize(cols, rows)
+	}
+
+	out := opts.Output
+	if out == nil {
+		out = os.Stdout
+	}
+	relayed := make(chan struct{})
+	go func() {
+		io.Copy(out, ptmx)
+		close(relayed)
+	}()
+	go s.forward()
+	go s.drainKeys()
+	go watchResize(s)
+	go func() {
+		s.waitErr = cmd.Wait()
+		// The relay ends once the output left in the pty has been read,
+		// unless a background process still holds the terminal
+		select {
+		case <-relayed:
+		case <-time.After(relayGrace):
+		}
+		close(s.done)
+		s.Close()
+	}()
+	return s, nil
+}
+
+// Handler returns the session's input handler.
+func (s *Session) Handler() *keyboard.Handler {
+	return s.handler
+}
+
+// Resize sets the child's terminal size.
+func (s *Session) Resize(cols, rows int) error {
+	return setSize(s.pty, cols, rows)
+}
+
+// Write sends bytes to the child as if typed, e.g. to inject keys.
+func (s *Session) Write(p []byte) (int, error) {
+	return s.pty.Write(p)
+}
+
+// Wait waits for the child to exit and returns its exit error.
+func (s *Session) Wait() error {
+	<-s.done
+	return s.waitErr
+}
+
+// Done is closed when the child has exited.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close stops the handler (restoring the terminal) and closes the pty,
+// which hangs up the child if it is still running.
+func (s *Session) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		s.handler.Stop()
+		err = s.pty.Close()
+	})
+	return err
+}
+
+// forward writes each raw input event to the child unless a key in it is
+// intercepted
+func (s *Session) forward() {
+	for {
+		select {
+		case ev := <-s.handler.RawEvents:
+			if s.intercepted(ev.Keys) {
+				continue
+			}
+			if _, err := s.pty.Write(ev.Bytes); err != nil {
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// intercepted reports whether Intercept consumes any of keys
+func (s *Session) intercepted(keys []string) bool {
+	if s.intercept == nil {
+		return false
+	}
+	consumed := false
+	for _, k := range keys {
+		if s.intercept(k) {
+			consumed = true
+		}
+	}
+	return consumed
+}
+
+// drainKeys discards the Keys channel, which forwarding doesn't use
+func (s *Session) drainKeys() {
+	for {
+		select {
+		case <-s.handler.Keys:
+		case <-s.done:
+			return
+		}
+	}
+}
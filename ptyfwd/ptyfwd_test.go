@@ -0,0 +1,118 @@
+package ptyfwd
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phroun/direct-key-handler/keyboard"
+)
+
+// syncBuffer is a bytes.Buffer safe for the relay goroutine.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+// TestForward: typed input reaches the child except intercepted keys, and
+// the child's output is relayed.
+func TestForward(t *testing.T) {
+	var out syncBuffer
+	intercepted := make(chan string, 1)
+	s, err := Start(exec.Command("cat"), Options{
+		Handler: keyboard.Options{},
+		Intercept: func(key string) bool {
+			if key == "^]" {
+				intercepted <- key
+				return true
+			}
+			return false
+		},
+		Output: &out,
+	})
+	if errors.Is(err, ErrUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.Handler().Feed([]byte("hello\x1d world\r"))
+	select {
+	case <-intercepted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("^] never intercepted")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(out.String(), "hello world") {
+		if time.Now().After(deadline) {
+			t.Fatalf("child output = %q, want it to contain \"hello world\"", out.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.Handler().Feed([]byte{4}) // ^D at line start: cat exits
+	select {
+	case <-s.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("child did not exit")
+	}
+}
+
+// TestForwardSlowWriter: keys are held back, not dropped, while forwarding
+// to the child is slow.
+func TestForwardSlowWriter(t *testing.T) {
+	const n = 200
+	var mu sync.Mutex
+	seen := 0
+	s, err := Start(exec.Command("cat"), Options{
+		Handler: keyboard.Options{},
+		Intercept: func(key string) bool {
+			time.Sleep(time.Millisecond) // A slow pty write
+			mu.Lock()
+			seen++
+			mu.Unlock()
+			return false
+		},
+		Output: &syncBuffer{},
+	})
+	if errors.Is(err, ErrUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.Handler().Feed(bytes.Repeat([]byte("x"), n))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		got := seen
+		mu.Unlock()
+		if got == n {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("forwarded %d of %d keys", got, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,200 @@
+// Package sshsession wires an SSH session into a keyboard.Handler, for
+// servers that give each connection its own terminal UI. There is no local
+// tty: the session channel is both the input and the terminal output, the
+// client's TERM selects the terminal profile, and window-change requests
+// become Handler.Resize calls.
+//
+// It depends on neither SSH library. With golang.org/x/crypto/ssh, parse
+// the request payloads here:
+//
+//	case "pty-req":
+//		pty, _ = sshsession.ParsePtyRequest(req.Payload)
+//	case "shell":
+//		h = sshsession.New(channel, pty, keyboard.Options{})
+//		h.Start()
+//	case "window-change":
+//		cols, rows, _ := sshsession.ParseWindowChange(req.Payload)
+//		h.Resize(cols, rows)
+//
+// With gliderlabs/ssh, fill in a PtyRequest from Session.Pty:
+//
+//	p, winCh, _ := s.Pty()
+//	h := sshsession.New(s, sshsession.PtyRequest{Term: p.Term, Cols: p.Window.Width, Rows: p.Window.Height}, keyboard.Options{})
+//	h.Start()
+//	go func() {
+//		for w := range winCh {
+//			h.Resize(w.Width, w.Height)
+//		}
+//	}()
+package sshsession
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/phroun/direct-key-handler/keyboard"
+)
+
+// Terminal mode opcodes (RFC 4254 section 8) that matter for key input
+const (
+	TTYOpEnd    = 0
+	TTYOpVINTR  = 1
+	TTYOpVERASE = 3
+	TTYOpVEOF   = 4
+	TTYOpVSUSP  = 10
+	TTYOpISIG   = 50
+	TTYOpICANON = 51
+	TTYOpECHO   = 53
+)
+
+// ErrMalformed is returned for a request payload that doesn't parse.
+var ErrMalformed = errors.New("sshsession: malformed request payload")
+
+// PtyRequest is a decoded "pty-req" channel request.
+type PtyRequest struct {
+	Term              string
+	Cols, Rows        int
+	WidthPx, HeightPx int
+
+	// Modes holds the client's terminal modes by opcode (TTYOp*). The
+	// handler always treats the session as a raw terminal; New applies the
+	// erase, interrupt, and suspend characters (see ModeMiddleware), and the
+	// rest are informational.
+	Modes map[uint8]uint32
+}
+
+// ParsePtyRequest decodes the payload of a "pty-req" request.
+func ParsePtyRequest(payload []byte) (PtyRequest, error) {
+	var p PtyRequest
+	term, rest, ok := parseString(payload)
+	if !ok || len(rest) < 16 {
+		return p, ErrMalformed
+	}
+	p.Term = string(term)
+	p.Cols = int(binary.BigEndian.Uint32(rest[0:]))
+	p.Rows = int(binary.BigEndian.Uint32(rest[4:]))
+	p.WidthPx = int(binary.BigEndian.Uint32(rest[8:]))
+	p.HeightPx = int(binary.BigEndian.Uint32(rest[12:]))
+	modes, _, ok := parseString(rest[16:])
+	if !ok {
+		return p, ErrMalformed
+	}
+	p.Modes = parseModes(modes)
+	return p, nil
+}
+
+// ParseWindowChange decodes the payload of a "window-change" request into
+// the new size in cells.
+func ParseWindowChange(payload []byte) (cols, rows int, err error) {
+	if len(payload) < 8 {
+		return 0, 0, ErrMalformed
+	}
+	return int(binary.BigEndian.Uint32(payload[0:])), int(binary.BigEndian.Uint32(payload[4:])), nil
+}
+
+// Options fills in the options for a handler reading from sess: the session
+// is the input and the ModeWriter, the terminal isn't managed (there is no
+// local tty), and unless opts.Terminal is set the profile is chosen from
+// the client's TERM. HandleSuspend and RestoreOnSignal are turned off, as
+// they act on the server process rather than the session.
+func Options(sess io.ReadWriter, pty PtyRequest, opts keyboard.Options) keyboard.Options {
+	noManage := false
+	opts.InputReader = sess
+	opts.ModeWriter = sess
+	opts.ManageTerminal = &noManage
+	if opts.Terminal == nil {
+		profile := keyboard.TerminalProfileForTERM(pty.Term)
+		opts.Terminal = &profile
+	}
+	opts.HandleSuspend = false
+	opts.RestoreOnSignal = false
+	return opts
+}
+
+// New creates a handler for an SSH session (see Options) with the terminal
+// size from the pty request and the client's control characters applied
+// (see ModeMiddleware). Call Start on it as usual.
+func New(sess io.ReadWriter, pty PtyRequest, opts keyboard.Options) *keyboard.Handler {
+	h := keyboard.New(Options(sess, pty, opts))
+	if pty.Cols > 0 && pty.Rows > 0 {
+		h.SetTerminalSize(pty.Cols, pty.Rows)
+	}
+	if mw := ModeMiddleware(pty); mw != nil {
+		h.Use(mw)
+	}
+	return h
+}
+
+// posixVDisable is the value of a disabled control character on BSD; Linux
+// uses 0
+const posixVDisable = 0xff
+
+// ModeMiddleware returns middleware that gives the client's control
+// characters their usual key names: the VERASE character arrives as
+// Backspace and, unless the client turned ISIG off, the VINTR and VSUSP
+// characters as ^C and ^Z. It returns nil when the client's characters are
+// the usual ones already. A character that shares its key name with an
+// earlier one (erase, then interrupt, then suspend) is left alone.
+func ModeMiddleware(pty PtyRequest) keyboard.Middleware {
+	remap := make(map[string]string)
+	add := func(op uint8, to string) {
+		c, ok := pty.Modes[op]
+		if !ok || c == 0 || c >= posixVDisable {
+			return
+		}
+		from, ok := keyName(byte(c))
+		if !ok || from == to {
+			return
+		}
+		if _, taken := remap[from]; !taken {
+			remap[from] = to
+		}
+	}
+	add(TTYOpVERASE, "Backspace")
+	if isig, ok := pty.Modes[TTYOpISIG]; !ok || isig != 0 {
+		add(TTYOpVINTR, "^C")
+		add(TTYOpVSUSP, "^Z")
+	}
+	if len(remap) == 0 {
+		return nil
+	}
+	return func(ev keyboard.Event, next func(keyboard.Event)) {
+		if to, ok := remap[ev.Key]; ok {
+			ev.Key = to
+		}
+		next(ev)
+	}
+}
+
+// keyName returns the key the handler delivers for the single byte c
+func keyName(c byte) (string, bool) {
+	events := keyboard.NewParser(keyboard.Options{Terminal: &keyboard.TerminalProfile{}}).Feed([]byte{c})
+	if len(events) != 1 || events[0].Kind != keyboard.EventKey {
+		return "", false
+	}
+	return events[0].Key, true
+}
+
+// parseString reads an SSH string (uint32 length, then bytes)
+func parseString(b []byte) (s, rest []byte, ok bool) {
+	if len(b) < 4 {
+		return nil, nil, false
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint64(len(b)-4) < uint64(n) {
+		return nil, nil, false
+	}
+	return b[4 : 4+n], b[4+n:], true
+}
+
+// parseModes decodes encoded terminal modes: opcode/uint32 pairs ended by
+// TTY_OP_END. Opcodes 160 and up have undefined arguments and end parsing.
+func parseModes(b []byte) map[uint8]uint32 {
+	modes := make(map[uint8]uint32)
+	for len(b) >= 5 && b[0] != TTYOpEnd && b[0] < 160 {
+		modes[b[0]] = binary.BigEndian.Uint32(b[1:])
+		b = b[5:]
+	}
+	return modes
+}
@@ -0,0 +1,82 @@
+package sshsession
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/phroun/direct-key-handler/keyboard"
+)
+
+// sshString encodes s as an SSH string.
+func sshString(s []byte) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(s))), s...)
+}
+
+// TestParsePtyRequest: TERM, size, and modes are decoded from the payload.
+func TestParsePtyRequest(t *testing.T) {
+	modes := []byte{TTYOpVERASE, 0, 0, 0, 0x7f, TTYOpECHO, 0, 0, 0, 1, TTYOpEnd}
+	payload := sshString([]byte("xterm-256color"))
+	for _, v := range []uint32{120, 40, 960, 640} {
+		payload = binary.BigEndian.AppendUint32(payload, v)
+	}
+	payload = append(payload, sshString(modes)...)
+
+	p, err := ParsePtyRequest(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Term != "xterm-256color" || p.Cols != 120 || p.Rows != 40 || p.WidthPx != 960 || p.HeightPx != 640 {
+		t.Errorf("ParsePtyRequest = %+v", p)
+	}
+	if p.Modes[TTYOpVERASE] != 0x7f || p.Modes[TTYOpECHO] != 1 || len(p.Modes) != 2 {
+		t.Errorf("Modes = %v", p.Modes)
+	}
+
+	if _, err := ParsePtyRequest(payload[:10]); err != ErrMalformed {
+		t.Errorf("truncated payload: err = %v, want ErrMalformed", err)
+	}
+}
+
+// TestParseWindowChange: the new size comes from the first two fields.
+func TestParseWindowChange(t *testing.T) {
+	var payload []byte
+	for _, v := range []uint32{100, 30, 0, 0} {
+		payload = binary.BigEndian.AppendUint32(payload, v)
+	}
+	cols, rows, err := ParseWindowChange(payload)
+	if err != nil || cols != 100 || rows != 30 {
+		t.Errorf("ParseWindowChange = %d, %d, %v; want 100, 30", cols, rows, err)
+	}
+}
+
+// TestModeMiddleware: the client's erase, interrupt, and suspend
+// characters are renamed, unless they are the usual ones or ISIG is off.
+func TestModeMiddleware(t *testing.T) {
+	run := func(mw keyboard.Middleware, key string) string {
+		var got string
+		mw(keyboard.Event{Kind: keyboard.EventKey, Key: key}, func(ev keyboard.Event) { got = ev.Key })
+		return got
+	}
+
+	mw := ModeMiddleware(PtyRequest{Modes: map[uint8]uint32{
+		TTYOpVERASE: 0x15, // ^U
+		TTYOpVINTR:  0x18, // ^X
+		TTYOpVSUSP:  0x1a, // ^Z, the usual
+	}})
+	if mw == nil {
+		t.Fatal("no middleware for unusual modes")
+	}
+	for key, want := range map[string]string{"^U": "Backspace", "^X": "^C", "^Z": "^Z", "a": "a"} {
+		if got := run(mw, key); got != want {
+			t.Errorf("%q became %q, want %q", key, got, want)
+		}
+	}
+
+	mw = ModeMiddleware(PtyRequest{Modes: map[uint8]uint32{TTYOpVINTR: 0x18, TTYOpISIG: 0}})
+	if mw != nil {
+		t.Errorf("middleware with ISIG off: ^X became %q", run(mw, "^X"))
+	}
+	if mw := ModeMiddleware(PtyRequest{Modes: map[uint8]uint32{TTYOpVERASE: 0x7f, TTYOpVINTR: 3, TTYOpVSUSP: 0}}); mw != nil {
+		t.Error("middleware for the usual modes")
+	}
+}
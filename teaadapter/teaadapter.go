@@ -0,0 +1,166 @@
+// Package teaadapter feeds direct-key-handler events into Bubble Tea
+// programs, so they get kitty keyboard protocol and macOS Option support.
+//
+// It does not import Bubble Tea: tea.Msg is an empty interface and tea.Cmd
+// a func() tea.Msg, so the commands and messages here plug straight in.
+// Run the program with tea.WithInput(nil) so that only the handler reads
+// stdin, then:
+//
+//	events := handler.Subscribe(0)
+//
+//	func (m model) Init() tea.Cmd { return teaadapter.Next(events) }
+//
+//	func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+//		switch msg := msg.(type) {
+//		case teaadapter.KeyMsg:
+//			switch msg.String() {
+//			case "ctrl+c":
+//				return m, tea.Quit
+//			case "alt+enter":
+//				...
+//			}
+//		}
+//		return m, teaadapter.Next(events)
+//	}
+package teaadapter
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/phroun/direct-key-handler/keyboard"
+)
+
+// KeyMsg is a key press, the counterpart of tea.KeyMsg. String returns the
+// key in Bubble Tea's notation ("a", "ctrl+c", "alt+enter", "shift+up").
+type KeyMsg struct {
+	Name  string // The handler's key name ("a", "^C", "M-Enter", "S-Up")
+	Runes []rune // The typed text, for printable keys
+	Alt   bool
+	Ctrl  bool
+	Shift bool
+	Super bool
+
+	// Key is the key without modifiers, in Bubble Tea's notation ("a",
+	// "enter", "up", "f5")
+	Key string
+}
+
+// String returns the key in Bubble Tea's notation: modifiers in the order
+// alt+ctrl+shift+, then the key.
+func (k KeyMsg) String() string {
+	var b strings.Builder
+	if k.Alt {
+		b.WriteString("alt+")
+	}
+	if k.Ctrl {
+		b.WriteString("ctrl+")
+	}
+	if k.Shift {
+		b.WriteString("shift+")
+	}
+	if k.Super {
+		b.WriteString("super+")
+	}
+	b.WriteString(k.Key)
+	return b.String()
+}
+
+// MouseMsg is a mouse report, the counterpart of tea.MouseMsg. Delivered
+// for subscription events of kind EventMouse; legacy Mouse* keys arrive as
+// KeyMsg.
+type MouseMsg keyboard.MouseEvent
+
+// PasteMsg is a complete bracketed paste.
+type PasteMsg []byte
+
+// LineMsg is a line completed in line mode.
+type LineMsg []byte
+
+// Next returns a command that waits for the next event on events (from
+// Handler.Subscribe) and converts it with Convert. Return it again from
+// Update after each message to keep listening. It yields nil once events
+// is closed.
+func Next(events <-chan keyboard.Event) func() interface{} {
+	return func() interface{} {
+		ev, ok := <-events
+		if !ok {
+			return nil
+		}
+		return Convert(ev)
+	}
+}
+
+// Convert turns a handler event into the matching message: KeyMsg,
+// MouseMsg, PasteMsg, or LineMsg.
+func Convert(ev keyboard.Event) interface{} {
+	switch ev.Kind {
+	case keyboard.EventMouse:
+		return MouseMsg(ev.Mouse)
+	case keyboard.EventPaste:
+		return PasteMsg(ev.Paste)
+	case keyboard.EventLine:
+		return LineMsg(ev.Line)
+	}
+	return ParseKey(ev.Key)
+}
+
+// specialKeys maps the handler's special key names to Bubble Tea's
+var specialKeys = map[string]string{
+	"Enter":     "enter",
+	"Tab":       "tab",
+	"Backspace": "backspace",
+	"Escape":    "esc",
+	"Space":     " ",
+	"Up":        "up",
+	"Down":      "down",
+	"Left":      "left",
+	"Right":     "right",
+	"Home":      "home",
+	"End":       "end",
+	"PageUp":    "pgup",
+	"PageDown":  "pgdown",
+	"Insert":    "insert",
+	"Delete":    "delete",
+}
+
+// ParseKey converts a handler key name into a KeyMsg.
+func ParseKey(name string) KeyMsg {
+	k := KeyMsg{Name: name}
+	rest := name
+	for len(rest) > 2 && rest[1] == '-' {
+		switch rest[0] {
+		case 'M':
+			k.Alt = true
+		case 'C':
+			k.Ctrl = true
+		case 'S':
+			k.Shift = true
+		case 's':
+			k.Super = true
+		default:
+			k.Key = strings.ToLower(rest)
+			return k
+		}
+		rest = rest[2:]
+	}
+
+	switch {
+	case len(rest) == 2 && rest[0] == '^':
+		// ^A: Control + character
+		k.Ctrl = true
+		k.Key = strings.ToLower(rest[1:])
+	case specialKeys[rest] != "":
+		k.Key = specialKeys[rest]
+		if rest == "Space" {
+			k.Runes = []rune{' '}
+		}
+	case utf8.RuneCountInString(rest) == 1:
+		k.Key = rest
+		k.Runes = []rune(rest)
+	default:
+		// F1..F24 become f1..f24; anything else is passed through lowercased
+		k.Key = strings.ToLower(rest)
+	}
+	return k
+}
@@ -0,0 +1,49 @@
+package teaadapter
+
+import (
+	"testing"
+
+	"github.com/phroun/direct-key-handler/keyboard"
+)
+
+// TestParseKey: handler key names come out in Bubble Tea's notation.
+func TestParseKey(t *testing.T) {
+	cases := map[string]string{
+		"a":       "a",
+		"^C":      "ctrl+c",
+		"M-a":     "alt+a",
+		"M-Enter": "alt+enter",
+		"S-Up":    "shift+up",
+		"C-S-Up":  "ctrl+shift+up",
+		"S-M-Tab": "alt+shift+tab",
+		"PageUp":  "pgup",
+		"Escape":  "esc",
+		"F5":      "f5",
+		"é":       "é",
+		"s-c":     "super+c",
+	}
+	for name, want := range cases {
+		if got := ParseKey(name).String(); got != want {
+			t.Errorf("ParseKey(%q).String() = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// TestNext: Next yields converted messages and nil once the channel closes.
+func TestNext(t *testing.T) {
+	events := make(chan keyboard.Event, 2)
+	events <- keyboard.Event{Kind: keyboard.EventKey, Key: "x"}
+	events <- keyboard.Event{Kind: keyboard.EventPaste, Paste: []byte("hi")}
+	close(events)
+
+	next := Next(events)
+	if msg, ok := next().(KeyMsg); !ok || string(msg.Runes) != "x" {
+		t.Errorf("first message = %#v, want KeyMsg x", msg)
+	}
+	if msg, ok := next().(PasteMsg); !ok || string(msg) != "hi" {
+		t.Errorf("second message = %#v, want PasteMsg hi", msg)
+	}
+	if msg := next(); msg != nil {
+		t.Errorf("message after close = %#v, want nil", msg)
+	}
+}
@@ -0,0 +1,226 @@
+// Package telnet strips Telnet protocol bytes from a raw connection so it
+// can feed a keyboard.Handler directly, for BBS/MUD style servers:
+//
+//	tc := telnet.NewConn(conn, telnet.Options{Negotiate: true})
+//	h := keyboard.New(tc.Options(keyboard.Options{}))
+//	tc.OnResize = h.Resize
+//	h.Start()
+//
+// Reads return the user's keystrokes with IAC commands and option
+// negotiation removed, and the Telnet newline (CR NUL or CR LF) reduced to
+// the CR a terminal sends for Enter. Writes escape 0xFF bytes.
+package telnet
+
+import (
+	"io"
+	"sync"
+
+	"github.com/phroun/direct-key-handler/keyboard"
+)
+
+// Telnet command and option codes (RFC 854, 857, 858, 1073, 1184)
+const (
+	SE   = 240
+	SB   = 250
+	WILL = 251
+	WONT = 252
+	DO   = 253
+	DONT = 254
+	IAC  = 255
+
+	OptEcho     = 1
+	OptSGA      = 3 // Suppress go-ahead
+	OptNAWS     = 31
+	OptLinemode = 34
+)
+
+// Options configures a Conn.
+type Options struct {
+	// Negotiate puts the client in character-at-a-time mode with server
+	// echo (WILL ECHO, WILL SGA), asks for window size reports (DO NAWS),
+	// and answers the client's option requests: LINEMODE is refused, and
+	// so is anything else not listed here. Without it, negotiation is
+	// stripped and left unanswered. Default: false
+	Negotiate bool
+}
+
+// Conn is a Telnet connection with the protocol removed from the input.
+type Conn struct {
+	rw        io.ReadWriter
+	negotiate bool
+
+	// OnResize is called with the window size from each NAWS report, on the
+	// goroutine calling Read. Set it before reading starts.
+	OnResize func(cols, rows int)
+
+	wmu sync.Mutex // Serializes writes to rw (replies and Write)
+
+	// Parser state, carried across reads
+	state   int
+	cmd     byte   // WILL/WONT/DO/DONT awaiting its option byte
+	sub     []byte // Subnegotiation being gathered
+	afterCR bool   // Last data byte was CR
+	buf     []byte
+}
+
+// Parser states
+const (
+	stData = iota
+	stIAC
+	stOption
+	stSub
+	stSubIAC
+)
+
+// NewConn wraps rw. With Options.Negotiate the opening negotiation is sent
+// immediately.
+func NewConn(rw io.ReadWriter, opts Options) *Conn {
+	c := &Conn{rw: rw, negotiate: opts.Negotiate}
+	if c.negotiate {
+		c.send(IAC, WILL, OptEcho, IAC, WILL, OptSGA, IAC, DO, OptNAWS)
+	}
+	return c
+}
+
+// Options fills in the options for a handler reading from c: the
+// connection is the input and the ModeWriter, the terminal isn't managed
+// (there is no local tty), and unless opts.Terminal is set no terminal
+// quirks apply, since the server's TERM says nothing about the client.
+// HandleSuspend and RestoreOnSignal are turned off, as they act on the
+// server process rather than the connection.
+func (c *Conn) Options(opts keyboard.Options) keyboard.Options {
+	noManage := false
+	opts.InputReader = c
+	opts.ModeWriter = c
+	opts.ManageTerminal = &noManage
+	if opts.Terminal == nil {
+		opts.Terminal = &keyboard.TerminalProfile{}
+	}
+	opts.HandleSuspend = false
+	opts.RestoreOnSignal = false
+	return opts
+}
+
+// Read reads user input with the Telnet protocol removed. It returns 0
+// bytes with a nil error only if everything read was protocol.
+func (c *Conn) Read(p []byte) (int, error) {
+	if len(c.buf) < len(p) {
+		c.buf = make([]byte, len(p))
+	}
+	n, err := c.rw.Read(c.buf[:len(p)])
+	return c.decode(p, c.buf[:n]), err
+}
+
+// Write writes output, escaping 0xFF bytes as IAC IAC.
+func (c *Conn) Write(p []byte) (int, error) {
+	out := p
+	for i, b := range p {
+		if b == IAC {
+			out = make([]byte, 0, len(p)+8)
+			out = append(out, p[:i]...)
+			for _, b := range p[i:] {
+				if b == IAC {
+					out = append(out, IAC)
+				}
+				out = append(out, b)
+			}
+			break
+		}
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if _, err := c.rw.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// decode copies the data bytes of in to out, handling protocol bytes, and
+// returns the number copied
+func (c *Conn) decode(out, in []byte) int {
+	n := 0
+	for _, b := range in {
+		switch c.state {
+		case stData:
+			if b == IAC {
+				c.state = stIAC
+				continue
+			}
+			if c.afterCR && (b == 0 || b == '\n') {
+				c.afterCR = false
+				continue
+			}
+			c.afterCR = b == '\r'
+			out[n] = b
+			n++
+		case stIAC:
+			c.state = stData
+			switch b {
+			case IAC:
+				out[n] = IAC
+				n++
+			case WILL, WONT, DO, DONT:
+				c.cmd = b
+				c.state = stOption
+			case SB:
+				c.sub = c.sub[:0]
+				c.state = stSub
+			}
+		case stOption:
+			c.state = stData
+			c.answer(c.cmd, b)
+		case stSub:
+			if b == IAC {
+				c.state = stSubIAC
+			} else {
+				c.sub = append(c.sub, b)
+			}
+		case stSubIAC:
+			switch b {
+			case IAC:
+				c.sub = append(c.sub, IAC)
+				c.state = stSub
+			case SE:
+				c.state = stData
+				c.subnegotiation(c.sub)
+			default:
+				c.state = stSub
+			}
+		}
+	}
+	return n
+}
+
+// answer replies to an option request when negotiating: the options we
+// asked for are acknowledged by the client, anything else is refused
+func (c *Conn) answer(cmd, opt byte) {
+	if !c.negotiate {
+		return
+	}
+	switch cmd {
+	case WILL:
+		if opt != OptNAWS {
+			c.send(IAC, DONT, opt)
+		}
+	case DO:
+		if opt != OptEcho && opt != OptSGA {
+			c.send(IAC, WONT, opt)
+		}
+	}
+}
+
+// subnegotiation handles a completed SB ... SE body
+func (c *Conn) subnegotiation(body []byte) {
+	if len(body) == 5 && body[0] == OptNAWS && c.OnResize != nil {
+		cols := int(body[1])<<8 | int(body[2])
+		rows := int(body[3])<<8 | int(body[4])
+		c.OnResize(cols, rows)
+	}
+}
+
+// send writes protocol bytes to the connection
+func (c *Conn) send(b ...byte) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.rw.Write(b)
+}
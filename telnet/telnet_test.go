@@ -0,0 +1,82 @@
+package telnet
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/phroun/direct-key-handler/keyboard"
+)
+
+// fakeConn reads from in and records writes.
+type fakeConn struct {
+	in  io.Reader
+	out bytes.Buffer
+}
+
+func (f *fakeConn) Read(p []byte) (int, error)  { return f.in.Read(p) }
+func (f *fakeConn) Write(p []byte) (int, error) { return f.out.Write(p) }
+
+// TestDecode: negotiation and subnegotiation are stripped, NAWS is
+// reported, IAC IAC is a data byte, and CR NUL / CR LF become CR.
+func TestDecode(t *testing.T) {
+	input := []byte{
+		'a', IAC, WILL, OptNAWS, IAC, SB, OptNAWS, 0, 132, 0, 43, IAC, SE,
+		'b', '\r', 0, 'c', '\r', '\n', IAC, IAC, IAC, WILL, OptLinemode,
+	}
+	f := &fakeConn{in: bytes.NewReader(input)}
+	c := NewConn(f, Options{Negotiate: true})
+	var cols, rows int
+	c.OnResize = func(w, h int) { cols, rows = w, h }
+
+	got, err := io.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{'a', 'b', '\r', 'c', '\r', IAC}; !bytes.Equal(got, want) {
+		t.Errorf("data = %q, want %q", got, want)
+	}
+	if cols != 132 || rows != 43 {
+		t.Errorf("NAWS size = %dx%d, want 132x43", cols, rows)
+	}
+	wantOut := []byte{IAC, WILL, OptEcho, IAC, WILL, OptSGA, IAC, DO, OptNAWS, IAC, DONT, OptLinemode}
+	if !bytes.Equal(f.out.Bytes(), wantOut) {
+		t.Errorf("negotiation sent = %v, want %v", f.out.Bytes(), wantOut)
+	}
+}
+
+// TestDecodeSplit: a command split across reads is still recognized.
+func TestDecodeSplit(t *testing.T) {
+	c := NewConn(&fakeConn{}, Options{})
+	out := make([]byte, 8)
+	n := c.decode(out, []byte{'x', IAC})
+	n += c.decode(out[n:], []byte{DO, OptEcho, 'y'})
+	if got := string(out[:n]); got != "xy" {
+		t.Errorf("data = %q, want \"xy\"", got)
+	}
+}
+
+// TestWriteEscapes: 0xFF in output is doubled.
+func TestWriteEscapes(t *testing.T) {
+	f := &fakeConn{}
+	c := NewConn(f, Options{})
+	if _, err := c.Write([]byte{'a', IAC, 'b'}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{'a', IAC, IAC, 'b'}; !bytes.Equal(f.out.Bytes(), want) {
+		t.Errorf("written = %v, want %v", f.out.Bytes(), want)
+	}
+}
+
+// TestOptions: the connection is the input and output, with no terminal
+// quirks unless a profile is given.
+func TestOptions(t *testing.T) {
+	c := NewConn(&bytes.Buffer{}, Options{})
+	opts := c.Options(keyboard.Options{})
+	if opts.InputReader != c || opts.ModeWriter != c {
+		t.Error("connection not set as input and ModeWriter")
+	}
+	if opts.Terminal == nil || opts.Terminal.Name != "" {
+		t.Errorf("Terminal = %+v, want an empty profile", opts.Terminal)
+	}
+}
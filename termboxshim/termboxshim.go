@@ -0,0 +1,352 @@
+// Package termboxshim exposes a termbox-go style input API (Init,
+// PollEvent, Interrupt, Close) on top of a keyboard.Handler, to ease moving
+// termbox applications onto this package. The types and constants have
+// termbox-go's names and values, so for input handling it is usually enough
+// to change the import:
+//
+//	import termbox "github.com/phroun/direct-key-handler/termboxshim"
+//
+// Only input is covered; keep using termbox (or anything else) for output.
+package termboxshim
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/phroun/direct-key-handler/keyboard"
+)
+
+type (
+	EventType uint8
+	Modifier  uint8
+	Key       uint16
+	InputMode int
+)
+
+// Event is an input event, as in termbox-go.
+type Event struct {
+	Type   EventType // one of Event* constants
+	Mod    Modifier  // one of Mod* constants or 0
+	Key    Key       // one of Key* constants, invalid if 'Ch' is not 0
+	Ch     rune      // a unicode character
+	Width  int       // width of the screen (unused: resize is not reported)
+	Height int       // height of the screen (unused: resize is not reported)
+	Err    error     // error in case if input failed
+	MouseX int       // x coord of mouse
+	MouseY int       // y coord of mouse
+	N      int       // number of bytes written when getting a raw event (unused)
+}
+
+// Event types
+const (
+	EventKey EventType = iota
+	EventResize
+	EventMouse
+	EventError
+	EventInterrupt
+	EventRaw
+	EventNone
+)
+
+// Modifiers
+const (
+	ModAlt Modifier = 1 << iota
+	ModMotion
+)
+
+// Input modes
+const (
+	InputEsc InputMode = 1 << iota
+	InputAlt
+	InputMouse
+	InputCurrent InputMode = 0
+)
+
+// Special keys and mouse buttons
+const (
+	KeyF1 Key = 0xFFFF - iota
+	KeyF2
+	KeyF3
+	KeyF4
+	KeyF5
+	KeyF6
+	KeyF7
+	KeyF8
+	KeyF9
+	KeyF10
+	KeyF11
+	KeyF12
+	KeyInsert
+	KeyDelete
+	KeyHome
+	KeyEnd
+	KeyPgup
+	KeyPgdn
+	KeyArrowUp
+	KeyArrowDown
+	KeyArrowLeft
+	KeyArrowRight
+	key_min // see terminfo
+	MouseLeft
+	MouseMiddle
+	MouseRight
+	MouseRelease
+	MouseWheelUp
+	MouseWheelDown
+)
+
+// Control keys
+const (
+	KeyCtrlTilde      Key = 0x00
+	KeyCtrl2          Key = 0x00
+	KeyCtrlSpace      Key = 0x00
+	KeyCtrlA          Key = 0x01
+	KeyCtrlB          Key = 0x02
+	KeyCtrlC          Key = 0x03
+	KeyCtrlD          Key = 0x04
+	KeyCtrlE          Key = 0x05
+	KeyCtrlF          Key = 0x06
+	KeyCtrlG          Key = 0x07
+	KeyBackspace      Key = 0x08
+	KeyCtrlH          Key = 0x08
+	KeyTab            Key = 0x09
+	KeyCtrlI          Key = 0x09
+	KeyCtrlJ          Key = 0x0A
+	KeyCtrlK          Key = 0x0B
+	KeyCtrlL          Key = 0x0C
+	KeyEnter          Key = 0x0D
+	KeyCtrlM          Key = 0x0D
+	KeyCtrlN          Key = 0x0E
+	KeyCtrlO          Key = 0x0F
+	KeyCtrlP          Key = 0x10
+	KeyCtrlQ          Key = 0x11
+	KeyCtrlR          Key = 0x12
+	KeyCtrlS          Key = 0x13
+	KeyCtrlT          Key = 0x14
+	KeyCtrlU          Key = 0x15
+	KeyCtrlV          Key = 0x16
+	KeyCtrlW          Key = 0x17
+	KeyCtrlX          Key = 0x18
+	KeyCtrlY          Key = 0x19
+	KeyCtrlZ          Key = 0x1A
+	KeyEsc            Key = 0x1B
+	KeyCtrlLsqBracket Key = 0x1B
+	KeyCtrl3          Key = 0x1B
+	KeyCtrl4          Key = 0x1C
+	KeyCtrlBackslash  Key = 0x1C
+	KeyCtrl5          Key = 0x1D
+	KeyCtrlRsqBracket Key = 0x1D
+	KeyCtrl6          Key = 0x1E
+	KeyCtrl7          Key = 0x1F
+	KeyCtrlSlash      Key = 0x1F
+	KeyCtrlUnderscore Key = 0x1F
+	KeySpace          Key = 0x20
+	KeyBackspace2     Key = 0x7F
+	KeyCtrl8          Key = 0x7F
+)
+
+// ErrNotInitialized is returned when the shim is used before Init.
+var ErrNotInitialized = errors.New("termboxshim: not initialized")
+
+var (
+	mu        sync.Mutex
+	handler   *keyboard.Handler
+	interrupt chan struct{}
+	inputMode = InputEsc
+)
+
+// Init starts a handler reading os.Stdin, with mouse reporting available
+// through SetInputMode(InputMouse).
+func Init() error {
+	return InitWith(keyboard.Options{InputReader: os.Stdin, ModeWriter: os.Stdout})
+}
+
+// InitWith starts a handler with the given options. Options the shim
+// depends on (the Mouse channel, 0-based mouse coordinates, and the mouse
+// modes, if not set) are filled in.
+func InitWith(opts keyboard.Options) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if handler != nil {
+		return errors.New("termboxshim: already initialized")
+	}
+	opts.MouseChannel = true
+	opts.MouseZeroBased = true
+	if opts.MouseEnterModes == "" {
+		opts.MouseEnterModes = keyboard.MouseTrackingOn + keyboard.MouseButtonMotionOn + keyboard.MouseSGROn
+		opts.MouseExitModes = keyboard.MouseSGROff + keyboard.MouseButtonMotionOff + keyboard.MouseTrackingOff
+	}
+	h := keyboard.New(opts)
+	h.SetMouseEnabled(false)
+	if err := h.Start(); err != nil {
+		return err
+	}
+	handler = h
+	interrupt = make(chan struct{}, 1)
+	inputMode = InputEsc
+	return nil
+}
+
+// Close stops the handler and restores the terminal.
+func Close() {
+	mu.Lock()
+	h := handler
+	handler = nil
+	mu.Unlock()
+	if h != nil {
+		h.Stop()
+	}
+}
+
+// Handler returns the underlying handler (nil before Init), for features
+// termbox doesn't have.
+func Handler() *keyboard.Handler {
+	mu.Lock()
+	defer mu.Unlock()
+	return handler
+}
+
+// SetInputMode sets the input mode and returns the current one. InputMouse
+// turns mouse reporting on; Alt is always reported as ModAlt, so InputEsc
+// and InputAlt make no difference. InputCurrent only queries the mode.
+func SetInputMode(mode InputMode) InputMode {
+	mu.Lock()
+	defer mu.Unlock()
+	if mode == InputCurrent {
+		return inputMode
+	}
+	if handler != nil {
+		handler.SetMouseEnabled(mode&InputMouse != 0)
+	}
+	inputMode = mode
+	return inputMode
+}
+
+// Interrupt makes a blocked PollEvent return an EventInterrupt event.
+func Interrupt() {
+	mu.Lock()
+	defer mu.Unlock()
+	if interrupt == nil {
+		return
+	}
+	select {
+	case interrupt <- struct{}{}:
+	default:
+	}
+}
+
+// PollEvent waits for the next input event. Keys termbox has no name for
+// (F13, modified arrows, ...) are skipped.
+func PollEvent() Event {
+	mu.Lock()
+	h, intr := handler, interrupt
+	mu.Unlock()
+	if h == nil {
+		return Event{Type: EventError, Err: ErrNotInitialized}
+	}
+
+	for {
+		select {
+		case key := <-h.Keys:
+			if ev, ok := keyEvent(key); ok {
+				return ev
+			}
+		case mev := <-h.Mouse:
+			if ev, ok := mouseEvent(mev); ok {
+				return ev
+			}
+		case <-intr:
+			return Event{Type: EventInterrupt}
+		}
+	}
+}
+
+// specialKeys maps the handler's key names to termbox keys
+var specialKeys = map[string]Key{
+	"F1": KeyF1, "F2": KeyF2, "F3": KeyF3, "F4": KeyF4,
+	"F5": KeyF5, "F6": KeyF6, "F7": KeyF7, "F8": KeyF8,
+	"F9": KeyF9, "F10": KeyF10, "F11": KeyF11, "F12": KeyF12,
+	"Insert": KeyInsert, "Delete": KeyDelete,
+	"Home": KeyHome, "End": KeyEnd,
+	"PageUp": KeyPgup, "PageDown": KeyPgdn,
+	"Up": KeyArrowUp, "Down": KeyArrowDown,
+	"Left": KeyArrowLeft, "Right": KeyArrowRight,
+	"Enter": KeyEnter, "Tab": KeyTab, "Escape": KeyEsc,
+	"Backspace": KeyBackspace2, "Space": KeySpace,
+}
+
+// keyEvent converts a handler key name to a termbox key event
+func keyEvent(name string) (Event, bool) {
+	ev := Event{Type: EventKey}
+	if len(name) > 2 && name[:2] == "M-" {
+		ev.Mod = ModAlt
+		name = name[2:]
+	}
+	if k, ok := specialKeys[name]; ok {
+		ev.Key = k
+		return ev, true
+	}
+	if len(name) == 2 && name[0] == '^' {
+		// ^@ .. ^_ are the control codes 0x00 .. 0x1F
+		if c := name[1]; c >= '@' && c <= '_' {
+			ev.Key = Key(c - '@')
+			return ev, true
+		}
+		if name[1] == '?' {
+			ev.Key = KeyBackspace2
+			return ev, true
+		}
+	}
+	if r, size := utf8.DecodeRuneInString(name); size == len(name) && r != utf8.RuneError {
+		if r == ' ' {
+			ev.Key = KeySpace
+		} else {
+			ev.Ch = r
+		}
+		return ev, true
+	}
+	return Event{}, false
+}
+
+// mouseEvent converts a typed mouse event to a termbox mouse event
+func mouseEvent(m keyboard.MouseEvent) (Event, bool) {
+	ev := Event{Type: EventMouse, MouseX: m.X, MouseY: m.Y}
+	if m.Alt {
+		ev.Mod |= ModAlt
+	}
+	switch m.Action {
+	case keyboard.MousePress, keyboard.MouseDrag:
+		switch m.Button {
+		case keyboard.MouseButtonLeft:
+			ev.Key = MouseLeft
+		case keyboard.MouseButtonMiddle:
+			ev.Key = MouseMiddle
+		case keyboard.MouseButtonRight:
+			ev.Key = MouseRight
+		default:
+			return Event{}, false
+		}
+		if m.Action == keyboard.MouseDrag {
+			ev.Mod |= ModMotion
+		}
+	case keyboard.MouseRelease:
+		ev.Key = MouseRelease
+	case keyboard.MouseMove:
+		ev.Key = MouseRelease
+		ev.Mod |= ModMotion
+	case keyboard.MouseScroll:
+		switch m.Button {
+		case keyboard.MouseWheelUp:
+			ev.Key = MouseWheelUp
+		case keyboard.MouseWheelDown:
+			ev.Key = MouseWheelDown
+		default:
+			return Event{}, false
+		}
+	default:
+		return Event{}, false
+	}
+	return ev, true
+}
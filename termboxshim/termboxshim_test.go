@@ -0,0 +1,49 @@
+package termboxshim
+
+import (
+	"testing"
+
+	"github.com/phroun/direct-key-handler/keyboard"
+)
+
+// TestKeyEvent: handler key names map onto termbox's Key/Ch/Mod.
+func TestKeyEvent(t *testing.T) {
+	cases := []struct {
+		name string
+		want Event
+	}{
+		{"a", Event{Ch: 'a'}},
+		{"é", Event{Ch: 'é'}},
+		{"M-x", Event{Mod: ModAlt, Ch: 'x'}},
+		{"^C", Event{Key: KeyCtrlC}},
+		{"^@", Event{Key: KeyCtrlSpace}},
+		{"Enter", Event{Key: KeyEnter}},
+		{"Backspace", Event{Key: KeyBackspace2}},
+		{"M-Up", Event{Mod: ModAlt, Key: KeyArrowUp}},
+		{"F12", Event{Key: KeyF12}},
+		{"Space", Event{Key: KeySpace}},
+	}
+	for _, c := range cases {
+		got, ok := keyEvent(c.name)
+		c.want.Type = EventKey
+		if !ok || got != c.want {
+			t.Errorf("keyEvent(%q) = %+v, %v; want %+v", c.name, got, ok, c.want)
+		}
+	}
+	if _, ok := keyEvent("F13"); ok {
+		t.Error("keyEvent(\"F13\") accepted a key termbox has no name for")
+	}
+}
+
+// TestMouseEvent: typed mouse events map onto termbox's mouse keys.
+func TestMouseEvent(t *testing.T) {
+	got, ok := mouseEvent(keyboard.MouseEvent{Action: keyboard.MouseDrag, Button: keyboard.MouseButtonRight, X: 4, Y: 2})
+	want := Event{Type: EventMouse, Key: MouseRight, Mod: ModMotion, MouseX: 4, MouseY: 2}
+	if !ok || got != want {
+		t.Errorf("mouseEvent(drag) = %+v, want %+v", got, want)
+	}
+	got, ok = mouseEvent(keyboard.MouseEvent{Action: keyboard.MouseScroll, Button: keyboard.MouseWheelDown})
+	if !ok || got.Key != MouseWheelDown {
+		t.Errorf("mouseEvent(scroll) = %+v, want MouseWheelDown", got)
+	}
+}
@@ -0,0 +1,128 @@
+// Package wsbridge feeds a browser terminal (xterm.js and similar) into a
+// keyboard.Handler over a websocket. Input arrives as binary frames, or as
+// text frames as sent by xterm.js's attach addon; a text frame holding a
+// JSON object with cols and rows is a resize instead:
+//
+//	{"type": "resize", "cols": 120, "rows": 40}
+//
+// It depends on no websocket library: the connection just needs
+// ReadMessage/WriteMessage methods shaped like gorilla/websocket's.
+//
+//	b := wsbridge.New(wsConn)
+//	h := keyboard.New(b.Options(keyboard.Options{}))
+//	b.OnResize = h.Resize
+//	h.Start()
+package wsbridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/phroun/direct-key-handler/keyboard"
+)
+
+// Websocket message types, as in RFC 6455 and gorilla/websocket
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+)
+
+// Conn is the websocket connection the bridge reads from and writes to.
+// *websocket.Conn from gorilla/websocket satisfies it.
+type Conn interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+}
+
+// Bridge turns websocket messages into an input stream (io.Reader) and
+// output writes into binary messages (io.Writer).
+type Bridge struct {
+	conn Conn
+
+	// OnResize is called with the size from each resize message, on the
+	// goroutine calling Read. Set it before reading starts.
+	OnResize func(cols, rows int)
+
+	pending []byte     // Input from the last message not yet read
+	wmu     sync.Mutex // Websocket connections allow one writer at a time
+}
+
+// New creates a bridge over conn.
+func New(conn Conn) *Bridge {
+	return &Bridge{conn: conn}
+}
+
+// Options fills in the options for a handler reading from b: the bridge is
+// the input and the ModeWriter, the terminal isn't managed (there is no
+// local tty), and unless opts.Terminal is set the xterm profile is used,
+// as browser terminals follow xterm rather than the server's TERM.
+// HandleSuspend and RestoreOnSignal are turned off, as they act on the
+// server process rather than the connection.
+func (b *Bridge) Options(opts keyboard.Options) keyboard.Options {
+	noManage := false
+	opts.InputReader = b
+	opts.ModeWriter = b
+	opts.ManageTerminal = &noManage
+	if opts.Terminal == nil {
+		profile, _ := keyboard.TerminalProfileByName("xterm")
+		opts.Terminal = &profile
+	}
+	opts.HandleSuspend = false
+	opts.RestoreOnSignal = false
+	return opts
+}
+
+// resizeMessage is the JSON form of a resize
+type resizeMessage struct {
+	Type string `json:"type"`
+	Cols *int   `json:"cols"`
+	Rows *int   `json:"rows"`
+}
+
+// Read returns input from the websocket, handling resize messages along
+// the way.
+func (b *Bridge) Read(p []byte) (int, error) {
+	for len(b.pending) == 0 {
+		mt, data, err := b.conn.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		if mt == TextMessage && b.resize(data) {
+			continue
+		}
+		b.pending = data
+	}
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+// resize handles data if it is a resize message, reporting whether it was
+func (b *Bridge) resize(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return false
+	}
+	var m resizeMessage
+	if err := json.Unmarshal(trimmed, &m); err != nil || m.Cols == nil || m.Rows == nil {
+		return false
+	}
+	if m.Type != "" && m.Type != "resize" {
+		return false
+	}
+	if b.OnResize != nil {
+		b.OnResize(*m.Cols, *m.Rows)
+	}
+	return true
+}
+
+// Write sends p to the browser terminal as a binary message.
+func (b *Bridge) Write(p []byte) (int, error) {
+	b.wmu.Lock()
+	defer b.wmu.Unlock()
+	if err := b.conn.WriteMessage(BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
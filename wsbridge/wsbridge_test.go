@@ -0,0 +1,87 @@
+package wsbridge
+
+import (
+	"io"
+	"testing"
+
+	"github.com/phroun/direct-key-handler/keyboard"
+)
+
+// fakeConn replays a list of messages.
+type fakeConn struct {
+	msgs    []message
+	written [][]byte
+}
+
+type message struct {
+	typ  int
+	data string
+}
+
+func (f *fakeConn) ReadMessage() (int, []byte, error) {
+	if len(f.msgs) == 0 {
+		return 0, nil, io.EOF
+	}
+	m := f.msgs[0]
+	f.msgs = f.msgs[1:]
+	return m.typ, []byte(m.data), nil
+}
+
+func (f *fakeConn) WriteMessage(typ int, data []byte) error {
+	f.written = append(f.written, append([]byte(nil), data...))
+	return nil
+}
+
+// TestRead: binary and text frames are input; resize messages are not.
+func TestRead(t *testing.T) {
+	f := &fakeConn{msgs: []message{
+		{BinaryMessage, "ab"},
+		{TextMessage, `{"type":"resize","cols":120,"rows":40}`},
+		{TextMessage, "c\x1b[A"},
+		{TextMessage, `{"cols":80,"rows":24}`},
+		{TextMessage, "{"},
+	}}
+	b := New(f)
+	var sizes [][2]int
+	b.OnResize = func(cols, rows int) { sizes = append(sizes, [2]int{cols, rows}) }
+
+	got, err := io.ReadAll(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "abc\x1b[A{" {
+		t.Errorf("input = %q, want %q", got, "abc\x1b[A{")
+	}
+	if len(sizes) != 2 || sizes[0] != [2]int{120, 40} || sizes[1] != [2]int{80, 24} {
+		t.Errorf("resizes = %v, want [120x40 80x24]", sizes)
+	}
+}
+
+// TestWrite: output goes out as one binary message per write.
+func TestWrite(t *testing.T) {
+	f := &fakeConn{}
+	b := New(f)
+	if _, err := b.Write([]byte("\x1b[?1000h")); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.written) != 1 || string(f.written[0]) != "\x1b[?1000h" {
+		t.Errorf("written = %q", f.written)
+	}
+}
+
+// TestOptions: the bridge is the input and output, and the xterm profile
+// is used unless another is given.
+func TestOptions(t *testing.T) {
+	b := New(&fakeConn{})
+	opts := b.Options(keyboard.Options{})
+	if opts.InputReader != b || opts.ModeWriter != b {
+		t.Error("bridge not set as input and ModeWriter")
+	}
+	if opts.Terminal == nil || opts.Terminal.Name != "xterm" {
+		t.Errorf("Terminal = %+v, want xterm", opts.Terminal)
+	}
+	tmux, _ := keyboard.TerminalProfileByName("tmux")
+	if opts := b.Options(keyboard.Options{Terminal: &tmux}); opts.Terminal != &tmux {
+		t.Error("given Terminal replaced")
+	}
+}